@@ -1,52 +1,720 @@
 #include "engine.h"
-#include <openssl/sha.h>
+#include <openssl/evp.h>
 #include <vector>
 #include <string>
 #include <cstring>
 #include <cstdlib>
 #include <cstdio>
+#include <cstdint>
+#include <new>
+#include <atomic>
+#include <condition_variable>
+#include <deque>
+#include <functional>
+#include <mutex>
+#include <thread>
 
-int merkle_root(const char** inputs, const size_t* lengths, size_t n, unsigned char** out_root, char* errbuf, int errbuf_len) {
+static const unsigned char LEAF_PREFIX = 0x00;
+static const unsigned char NODE_PREFIX = 0x01;
+
+// Меньше этого числа узлов на уровне считаем в вызывающем потоке
+static const size_t MIN_PARALLEL_NODES = 1024;
+
+// То же для наборов в merkle_roots_buf: набор - это целый батч, а не один хеш
+static const size_t MIN_PARALLEL_SETS = 64;
+
+// Пул потоков на весь процесс. Растет до максимального запрошенного размера
+// и никогда не уничтожается, чтобы не ждать потоки при выходе из процесса.
+class ThreadPool {
+public:
+    void ensure(size_t n) {
+        std::lock_guard<std::mutex> lock(mu_);
+        while (workers_.size() < n) {
+            workers_.emplace_back([this] { run(); });
+        }
+    }
+
+    void submit(std::function<void()> task) {
+        {
+            std::lock_guard<std::mutex> lock(mu_);
+            tasks_.push_back(std::move(task));
+        }
+        cv_.notify_one();
+    }
+
+private:
+    void run() {
+        for (;;) {
+            std::function<void()> task;
+            {
+                std::unique_lock<std::mutex> lock(mu_);
+                cv_.wait(lock, [this] { return !tasks_.empty(); });
+                task = std::move(tasks_.front());
+                tasks_.pop_front();
+            }
+            task();
+        }
+    }
+
+    std::vector<std::thread> workers_;
+    std::deque<std::function<void()>> tasks_;
+    std::mutex mu_;
+    std::condition_variable cv_;
+};
+
+static ThreadPool& pool() {
+    static ThreadPool* p = new ThreadPool();
+    return *p;
+}
+
+// Делит [0, n) на куски и выполняет fn(begin, end) в threads потоках
+// (вызывающий поток обрабатывает первый кусок). Меньше min_parallel элементов
+// считается в вызывающем потоке. Возвращает false, если fn вернула false хотя бы
+// для одного куска.
+static bool parallel_for(size_t n, int threads, const std::function<bool(size_t, size_t)>& fn,
+                         size_t min_parallel = MIN_PARALLEL_NODES) {
+    size_t chunks = threads > 1 ? (size_t)threads : 1;
+    if (n < min_parallel || chunks == 1) {
+        return fn(0, n);
+    }
+    if (chunks > n / (min_parallel / 4)) {
+        chunks = n / (min_parallel / 4);
+    }
+    size_t step = (n + chunks - 1) / chunks;
+
+    std::atomic<bool> ok(true);
+    std::mutex mu;
+    std::condition_variable cv;
+    size_t remaining = chunks - 1;
+
+    pool().ensure(chunks - 1);
+    for (size_t c = 1; c < chunks; ++c) {
+        size_t begin = c * step;
+        size_t end = begin + step < n ? begin + step : n;
+        pool().submit([&, begin, end] {
+            if (begin < end && !fn(begin, end))
+                ok = false;
+            std::lock_guard<std::mutex> lock(mu);
+            if (--remaining == 0)
+                cv.notify_one();
+        });
+    }
+    if (!fn(0, step < n ? step : n))
+        ok = false;
+
+    std::unique_lock<std::mutex> lock(mu);
+    cv.wait(lock, [&] { return remaining == 0; });
+    return ok;
+}
+
+static const EVP_MD* hash_md(int alg) {
+    switch (alg) {
+    case ENGINE_HASH_SHA256:
+        return EVP_sha256();
+    case ENGINE_HASH_SHA512_256:
+        return EVP_sha512_256();
+    case ENGINE_HASH_SHA3_256:
+        return EVP_sha3_256();
+    }
+    return NULL;
+}
+
+// Хешер с переиспользуемым EVP контекстом (один на поток)
+class Hasher {
+public:
+    explicit Hasher(const EVP_MD* md) : md_(md), ctx_(EVP_MD_CTX_new()) {}
+    ~Hasher() { EVP_MD_CTX_free(ctx_); }
+
+    // out = H([prefix] || a || b); prefix < 0 - без префикса
+    bool digest(int prefix, const void* a, size_t alen, const void* b, size_t blen, unsigned char* out) {
+        if (!ctx_ || EVP_DigestInit_ex(ctx_, md_, NULL) != 1)
+            return false;
+        if (prefix >= 0) {
+            unsigned char p = (unsigned char)prefix;
+            if (EVP_DigestUpdate(ctx_, &p, 1) != 1)
+                return false;
+        }
+        if (alen > 0 && EVP_DigestUpdate(ctx_, a, alen) != 1)
+            return false;
+        if (blen > 0 && EVP_DigestUpdate(ctx_, b, blen) != 1)
+            return false;
+        return EVP_DigestFinal_ex(ctx_, out, NULL) == 1;
+    }
+
+private:
+    const EVP_MD* md_;
+    EVP_MD_CTX* ctx_;
+};
+
+// Параметры вызова после проверки
+struct Params {
+    int version;
+    int threads;
+    const EVP_MD* md;
+};
+
+static int read_params(const engine_params* params, Params* out, char* errbuf, int errbuf_len) {
+    out->version = params ? params->tree_version : ENGINE_TREE_LEGACY;
+    out->threads = params ? params->threads : 1;
+    int alg = params && params->hash_alg ? params->hash_alg : ENGINE_HASH_SHA256;
+    if (out->version != ENGINE_TREE_LEGACY && out->version != ENGINE_TREE_RFC6962) {
+        snprintf(errbuf, errbuf_len, "Unknown tree version %d", out->version);
+        return 4;
+    }
+    out->md = hash_md(alg);
+    if (!out->md || EVP_MD_get_size(out->md) != ENGINE_HASH_SIZE) {
+        snprintf(errbuf, errbuf_len, "Unknown hash algorithm %d", alg);
+        return 6;
+    }
+    if (out->threads < 1) {
+        out->threads = 1;
+    }
+    return 0;
+}
+
+// Уровень дерева: count хешей подряд в одном буфере
+struct Level {
+    std::vector<unsigned char> data;
+    size_t count;
+
+    const unsigned char* at(size_t i) const { return data.data() + i * ENGINE_HASH_SIZE; }
+    unsigned char* at(size_t i) { return data.data() + i * ENGINE_HASH_SIZE; }
+};
+
+// Сообщения батча: либо массив указателей + длины, либо один буфер + offsets
+struct Inputs {
+    const char** ptrs;
+    const size_t* lengths;
+    const unsigned char* data;
+    const size_t* offsets;
+
+    static Inputs from_ptrs(const char** ptrs, const size_t* lengths) {
+        return Inputs{ptrs, lengths, NULL, NULL};
+    }
+    static Inputs from_buf(const unsigned char* data, const size_t* offsets) {
+        return Inputs{NULL, NULL, data, offsets};
+    }
+
+    const void* ptr(size_t i) const { return data ? (const void*)(data + offsets[i]) : (const void*)ptrs[i]; }
+    size_t len(size_t i) const { return data ? offsets[i + 1] - offsets[i] : lengths[i]; }
+};
+
+// Хеши листьев
+static bool leaf_hashes(const Inputs& in, size_t n, const Params& p, Level* out) {
+    out->count = n;
+    out->data.resize(n * ENGINE_HASH_SIZE);
+    int prefix = p.version == ENGINE_TREE_RFC6962 ? LEAF_PREFIX : -1;
+
+    return parallel_for(n, p.threads, [&](size_t begin, size_t end) {
+        Hasher h(p.md);
+        for (size_t i = begin; i < end; ++i) {
+            if (!h.digest(prefix, in.ptr(i), in.len(i), NULL, 0, out->at(i)))
+                return false;
+        }
+        return true;
+    });
+}
+
+// Следующий уровень дерева.
+// LEGACY: последний узел нечетного уровня дублируется, RFC6962: поднимается как есть.
+static bool next_level(const Level& cur, const Params& p, Level* out) {
+    size_t pairs = (cur.count + 1) / 2;
+    out->count = pairs;
+    out->data.resize(pairs * ENGINE_HASH_SIZE);
+    int prefix = p.version == ENGINE_TREE_RFC6962 ? NODE_PREFIX : -1;
+
+    return parallel_for(pairs, p.threads, [&](size_t begin, size_t end) {
+        Hasher h(p.md);
+        for (size_t i = begin; i < end; ++i) {
+            const unsigned char* left = cur.at(2 * i);
+            bool paired = 2 * i + 1 < cur.count;
+            if (!paired && p.version == ENGINE_TREE_RFC6962) {
+                memcpy(out->at(i), left, ENGINE_HASH_SIZE);
+                continue;
+            }
+            const unsigned char* right = paired ? cur.at(2 * i + 1) : left;
+            if (!h.digest(prefix, left, ENGINE_HASH_SIZE, right, ENGINE_HASH_SIZE, out->at(i)))
+                return false;
+        }
+        return true;
+    });
+}
+
+// Считает root в out (ENGINE_HASH_SIZE байт)
+static int compute_root(const Inputs& in, size_t n, const engine_params* params,
+                        unsigned char* out, char* errbuf, int errbuf_len) {
     if (n == 0) {
         snprintf(errbuf, errbuf_len, "Empty input");
         return 1;
     }
+    Params p;
+    if (int rc = read_params(params, &p, errbuf, errbuf_len)) {
+        return rc;
+    }
 
-    std::vector<std::vector<unsigned char>> hashes;
-    hashes.reserve(n);
-
-    for (size_t i = 0; i < n; ++i) {
-        unsigned char hash[SHA256_SIZE];
-        SHA256(reinterpret_cast<const unsigned char*>(inputs[i]), lengths[i], hash);
-        hashes.push_back(std::vector<unsigned char>(hash, hash + SHA256_SIZE));
+    Level cur, next;
+    if (!leaf_hashes(in, n, p, &cur)) {
+        snprintf(errbuf, errbuf_len, "digest failed");
+        return 5;
     }
 
     // Построение дерева
-    while (hashes.size() > 1) {
-        std::vector<std::vector<unsigned char>> next;
-        for (size_t i = 0; i < hashes.size(); i += 2) {
-            std::vector<unsigned char> concat;
-            concat.insert(concat.end(), hashes[i].begin(), hashes[i].end());
-            if (i + 1 < hashes.size())
-                concat.insert(concat.end(), hashes[i+1].begin(), hashes[i+1].end());
-            else
-                concat.insert(concat.end(), hashes[i].begin(), hashes[i].end());
-            unsigned char hash[SHA256_SIZE];
-            SHA256(concat.data(), concat.size(), hash);
-            next.push_back(std::vector<unsigned char>(hash, hash + SHA256_SIZE));
-        }
-        hashes = next;
-    }
-
-    *out_root = (unsigned char*)malloc(SHA256_SIZE);
+    while (cur.count > 1) {
+        if (!next_level(cur, p, &next)) {
+            snprintf(errbuf, errbuf_len, "digest failed");
+            return 5;
+        }
+        std::swap(cur, next);
+    }
+
+    memcpy(out, cur.at(0), ENGINE_HASH_SIZE);
+    return 0;
+}
+
+// Строит путь включения сообщения index: siblings по ENGINE_HASH_SIZE байт, left - флаги
+static int compute_proof(const Inputs& in, size_t n, size_t index, const engine_params* params,
+                         std::vector<unsigned char>* siblings, std::vector<unsigned char>* left,
+                         char* errbuf, int errbuf_len) {
+    if (n == 0) {
+        snprintf(errbuf, errbuf_len, "Empty input");
+        return 1;
+    }
+    if (index >= n) {
+        snprintf(errbuf, errbuf_len, "Index %zu out of range [0, %zu)", index, n);
+        return 3;
+    }
+    Params p;
+    if (int rc = read_params(params, &p, errbuf, errbuf_len)) {
+        return rc;
+    }
+
+    Level cur, next;
+    if (!leaf_hashes(in, n, p, &cur)) {
+        snprintf(errbuf, errbuf_len, "digest failed");
+        return 5;
+    }
+
+    // Поднимаемся от листа к корню, запоминая соседа на каждом уровне
+    size_t pos = index;
+    while (cur.count > 1) {
+        if (pos % 2 == 1) {
+            siblings->insert(siblings->end(), cur.at(pos - 1), cur.at(pos));
+            left->push_back(1);
+        } else if (pos + 1 < cur.count) {
+            siblings->insert(siblings->end(), cur.at(pos + 1), cur.at(pos + 2));
+            left->push_back(0);
+        } else if (p.version == ENGINE_TREE_LEGACY) {
+            // нечетный уровень: узел в паре сам с собой
+            siblings->insert(siblings->end(), cur.at(pos), cur.at(pos + 1));
+            left->push_back(0);
+        }
+        if (!next_level(cur, p, &next)) {
+            snprintf(errbuf, errbuf_len, "digest failed");
+            return 5;
+        }
+        std::swap(cur, next);
+        pos /= 2;
+    }
+    return 0;
+}
+
+int merkle_root(const char** inputs, const size_t* lengths, size_t n, unsigned char** out_root, char* errbuf, int errbuf_len) {
+    return merkle_root_ex(inputs, lengths, n, NULL, out_root, errbuf, errbuf_len);
+}
+
+int merkle_root_ex(const char** inputs, const size_t* lengths, size_t n, const engine_params* params,
+                   unsigned char** out_root, char* errbuf, int errbuf_len) {
+    unsigned char root[ENGINE_HASH_SIZE];
+    if (int rc = compute_root(Inputs::from_ptrs(inputs, lengths), n, params, root, errbuf, errbuf_len)) {
+        return rc;
+    }
+
+    *out_root = (unsigned char*)malloc(ENGINE_HASH_SIZE);
     if (!*out_root) {
         snprintf(errbuf, errbuf_len, "malloc failed");
         return 2;
     }
-    memcpy(*out_root, hashes[0].data(), SHA256_SIZE);
+    memcpy(*out_root, root, ENGINE_HASH_SIZE);
     return 0;
 }
 
 void free_root(unsigned char* root) {
     free(root);
 }
+
+int merkle_proof(const char** inputs, const size_t* lengths, size_t n, size_t index,
+                 unsigned char** out_siblings, unsigned char** out_left, size_t* out_len,
+                 char* errbuf, int errbuf_len) {
+    return merkle_proof_ex(inputs, lengths, n, index, NULL, out_siblings, out_left, out_len, errbuf, errbuf_len);
+}
+
+int merkle_proof_ex(const char** inputs, const size_t* lengths, size_t n, size_t index, const engine_params* params,
+                    unsigned char** out_siblings, unsigned char** out_left, size_t* out_len,
+                    char* errbuf, int errbuf_len) {
+    std::vector<unsigned char> siblings;
+    std::vector<unsigned char> left;
+    if (int rc = compute_proof(Inputs::from_ptrs(inputs, lengths), n, index, params, &siblings, &left, errbuf, errbuf_len)) {
+        return rc;
+    }
+
+    size_t len = left.size();
+    // +1 чтобы malloc не вернул NULL для пустого пути (n == 1)
+    *out_siblings = (unsigned char*)malloc(len * ENGINE_HASH_SIZE + 1);
+    *out_left = (unsigned char*)malloc(len + 1);
+    if (!*out_siblings || !*out_left) {
+        free(*out_siblings);
+        free(*out_left);
+        snprintf(errbuf, errbuf_len, "malloc failed");
+        return 2;
+    }
+    memcpy(*out_siblings, siblings.data(), len * ENGINE_HASH_SIZE);
+    memcpy(*out_left, left.data(), len);
+    *out_len = len;
+    return 0;
+}
+
+void free_proof(unsigned char* siblings, unsigned char* left) {
+    free(siblings);
+    free(left);
+}
+
+int merkle_root_buf(const unsigned char* data, const size_t* offsets, size_t n, const engine_params* params,
+                    unsigned char* out_root, char* errbuf, int errbuf_len) {
+    return compute_root(Inputs::from_buf(data, offsets), n, params, out_root, errbuf, errbuf_len);
+}
+
+int merkle_proof_buf(const unsigned char* data, const size_t* offsets, size_t n, size_t index, const engine_params* params,
+                     unsigned char* out_siblings, unsigned char* out_left, size_t* out_len,
+                     char* errbuf, int errbuf_len) {
+    std::vector<unsigned char> siblings;
+    std::vector<unsigned char> left;
+    if (int rc = compute_proof(Inputs::from_buf(data, offsets), n, index, params, &siblings, &left, errbuf, errbuf_len)) {
+        return rc;
+    }
+    if (left.size() > ENGINE_MAX_DEPTH) {
+        snprintf(errbuf, errbuf_len, "Proof longer than %d", ENGINE_MAX_DEPTH);
+        return 7;
+    }
+
+    memcpy(out_siblings, siblings.data(), siblings.size());
+    memcpy(out_left, left.data(), left.size());
+    *out_len = left.size();
+    return 0;
+}
+
+int merkle_roots_buf(const unsigned char* data, const size_t* offsets, const size_t* set_offsets, size_t sets,
+                     const engine_params* params, int threads, unsigned char* out_roots, int* out_codes,
+                     char* errbuf, int errbuf_len) {
+    std::mutex mu;
+    size_t first_failed = sets;
+
+    // Наборы маленькие, поэтому параллелим по наборам, а каждый считаем в одном потоке:
+    // вложенный parallel_for из потока пула ждал бы сам пул
+    parallel_for(sets, threads, [&](size_t begin, size_t end) {
+        char local_err[ENGINE_ERRBUF_SIZE];
+        for (size_t i = begin; i < end; ++i) {
+            engine_params set_params = params ? params[i] : engine_params{ENGINE_TREE_LEGACY, 1, ENGINE_HASH_SHA256};
+            set_params.threads = 1;
+            size_t from = set_offsets[i];
+            size_t n = set_offsets[i + 1] - from;
+            int rc = compute_root(Inputs::from_buf(data, offsets + from), n, &set_params,
+                                  out_roots + i * ENGINE_HASH_SIZE, local_err, sizeof(local_err));
+            out_codes[i] = rc;
+            if (rc) {
+                std::lock_guard<std::mutex> lock(mu);
+                if (i < first_failed) {
+                    first_failed = i;
+                    snprintf(errbuf, errbuf_len, "set %zu: %s", i, local_err);
+                }
+            }
+        }
+        return true;
+    }, MIN_PARALLEL_SETS);
+    return first_failed < sets ? out_codes[first_failed] : 0;
+}
+
+int merkle_multiproof_buf(const unsigned char* data, const size_t* offsets, size_t n,
+                          const size_t* indices, size_t k, const engine_params* params,
+                          unsigned char** out_nodes, size_t* out_count, char* errbuf, int errbuf_len) {
+    if (n == 0 || k == 0) {
+        snprintf(errbuf, errbuf_len, "Empty input");
+        return 1;
+    }
+    for (size_t i = 0; i < k; ++i) {
+        if (indices[i] >= n || (i > 0 && indices[i] <= indices[i - 1])) {
+            snprintf(errbuf, errbuf_len, "Indices must be increasing and below %zu", n);
+            return 3;
+        }
+    }
+    Params p;
+    if (int rc = read_params(params, &p, errbuf, errbuf_len)) {
+        return rc;
+    }
+
+    Level cur, next;
+    if (!leaf_hashes(Inputs::from_buf(data, offsets), n, p, &cur)) {
+        snprintf(errbuf, errbuf_len, "digest failed");
+        return 5;
+    }
+
+    std::vector<size_t> known(indices, indices + k), next_known;
+    std::vector<unsigned char> nodes;
+    while (cur.count > 1) {
+        next_known.clear();
+        for (size_t i = 0; i < known.size(); ++i) {
+            size_t pos = known[i];
+            if (pos % 2 == 1) {
+                // левый сосед неизвестен, иначе пара обработана бы на нем
+                nodes.insert(nodes.end(), cur.at(pos - 1), cur.at(pos));
+            } else if (pos + 1 < cur.count && i + 1 < known.size() && known[i + 1] == pos + 1) {
+                ++i; // оба узла пары известны
+            } else if (pos + 1 < cur.count) {
+                nodes.insert(nodes.end(), cur.at(pos + 1), cur.at(pos + 2));
+            }
+            // последний узел нечетного уровня: пара сам с собой (LEGACY) или подъем (RFC6962)
+            next_known.push_back(pos / 2);
+        }
+        std::swap(known, next_known);
+        if (!next_level(cur, p, &next)) {
+            snprintf(errbuf, errbuf_len, "digest failed");
+            return 5;
+        }
+        std::swap(cur, next);
+    }
+
+    // +1 чтобы malloc не вернул NULL для пустого proof
+    *out_nodes = (unsigned char*)malloc(nodes.size() + 1);
+    if (!*out_nodes) {
+        snprintf(errbuf, errbuf_len, "malloc failed");
+        return 2;
+    }
+    memcpy(*out_nodes, nodes.data(), nodes.size());
+    *out_count = nodes.size() / ENGINE_HASH_SIZE;
+    return 0;
+}
+
+void free_multiproof(unsigned char* nodes) {
+    free(nodes);
+}
+
+// Пики хранятся по высоте: peaks[h] - корень полного поддерева из 2^h листьев,
+// он есть, когда установлен бит h в count. Append сливает пики как двоичный
+// счетчик, finalize сворачивает их снизу вверх по правилам версии дерева.
+// С keep_levels построитель дополнительно складывает в levels[h] все узлы
+// полных поддеревьев уровня h - этого хватает, чтобы отдать дерево целиком.
+struct merkle_builder {
+    Params p;
+    Hasher hasher;
+    uint64_t count;
+    unsigned char peaks[ENGINE_MAX_DEPTH][ENGINE_HASH_SIZE];
+    bool keep_levels;
+    std::vector<unsigned char> levels[ENGINE_MAX_DEPTH];
+
+    merkle_builder(const Params& params, bool keep)
+        : p(params), hasher(params.md), count(0), keep_levels(keep) {}
+
+    void record(int h, const unsigned char* node) {
+        if (keep_levels)
+            levels[h].insert(levels[h].end(), node, node + ENGINE_HASH_SIZE);
+    }
+};
+
+static merkle_builder* builder_new(const engine_params* params, bool keep, char* errbuf, int errbuf_len) {
+    Params p;
+    if (read_params(params, &p, errbuf, errbuf_len)) {
+        return NULL;
+    }
+    merkle_builder* b = new (std::nothrow) merkle_builder(p, keep);
+    if (!b) {
+        snprintf(errbuf, errbuf_len, "malloc failed");
+    }
+    return b;
+}
+
+merkle_builder* merkle_builder_new(const engine_params* params, char* errbuf, int errbuf_len) {
+    return builder_new(params, false, errbuf, errbuf_len);
+}
+
+merkle_builder* merkle_builder_new_tree(const engine_params* params, char* errbuf, int errbuf_len) {
+    return builder_new(params, true, errbuf, errbuf_len);
+}
+
+int merkle_builder_append(merkle_builder* b, const unsigned char* data, size_t len, char* errbuf, int errbuf_len) {
+    if (b->count == UINT64_MAX) {
+        snprintf(errbuf, errbuf_len, "Builder is full");
+        return 3;
+    }
+    int leaf_prefix = b->p.version == ENGINE_TREE_RFC6962 ? LEAF_PREFIX : -1;
+    int node_prefix = b->p.version == ENGINE_TREE_RFC6962 ? NODE_PREFIX : -1;
+
+    unsigned char node[ENGINE_HASH_SIZE];
+    if (!b->hasher.digest(leaf_prefix, data, len, NULL, 0, node)) {
+        snprintf(errbuf, errbuf_len, "digest failed");
+        return 5;
+    }
+    b->record(0, node);
+    // каждый установленный младший бит count - пик той же высоты, что и node
+    int h = 0;
+    for (uint64_t c = b->count; c & 1; c >>= 1) {
+        if (!b->hasher.digest(node_prefix, b->peaks[h], ENGINE_HASH_SIZE, node, ENGINE_HASH_SIZE, node)) {
+            snprintf(errbuf, errbuf_len, "digest failed");
+            return 5;
+        }
+        ++h;
+        b->record(h, node);
+    }
+    memcpy(b->peaks[h], node, ENGINE_HASH_SIZE);
+    b->count++;
+    return 0;
+}
+
+size_t merkle_builder_count(const merkle_builder* b) {
+    return (size_t)b->count;
+}
+
+// Сворачивает пики снизу вверх в out_root. Если out_nodes != NULL, пишет туда
+// все уровни дерева (нужен keep_levels): узлы полных поддеревьев уровня и его
+// неполный последний узел.
+static int builder_fold(merkle_builder* b, unsigned char* out_root, unsigned char* out_nodes,
+                        char* errbuf, int errbuf_len) {
+    if (b->count == 0) {
+        snprintf(errbuf, errbuf_len, "Empty input");
+        return 1;
+    }
+    bool legacy = b->p.version == ENGINE_TREE_LEGACY;
+    int node_prefix = legacy ? -1 : NODE_PREFIX;
+
+    // carry - неполный правый узел уровня h (есть, если has_carry)
+    unsigned char carry[ENGINE_HASH_SIZE];
+    bool has_carry = false;
+    for (int h = 0; h < ENGINE_MAX_DEPTH; ++h) {
+        if (out_nodes) {
+            memcpy(out_nodes, b->levels[h].data(), b->levels[h].size());
+            out_nodes += b->levels[h].size();
+            if (has_carry) {
+                memcpy(out_nodes, carry, ENGINE_HASH_SIZE);
+                out_nodes += ENGINE_HASH_SIZE;
+            }
+        }
+        uint64_t full = b->count >> h;
+        if (full + (has_carry ? 1 : 0) == 1) {
+            memcpy(out_root, has_carry ? carry : b->peaks[h], ENGINE_HASH_SIZE);
+            return 0;
+        }
+        bool ok = true;
+        if (full & 1) {
+            if (has_carry) {
+                ok = b->hasher.digest(node_prefix, b->peaks[h], ENGINE_HASH_SIZE, carry, ENGINE_HASH_SIZE, carry);
+            } else if (legacy) {
+                ok = b->hasher.digest(node_prefix, b->peaks[h], ENGINE_HASH_SIZE, b->peaks[h], ENGINE_HASH_SIZE, carry);
+            } else {
+                memcpy(carry, b->peaks[h], ENGINE_HASH_SIZE);
+            }
+            has_carry = true;
+        } else if (has_carry && legacy) {
+            // нечетный уровень: неполный узел в паре сам с собой
+            ok = b->hasher.digest(node_prefix, carry, ENGINE_HASH_SIZE, carry, ENGINE_HASH_SIZE, carry);
+        }
+        if (!ok) {
+            snprintf(errbuf, errbuf_len, "digest failed");
+            return 5;
+        }
+    }
+    if (out_nodes) {
+        memcpy(out_nodes, carry, ENGINE_HASH_SIZE);
+    }
+    memcpy(out_root, carry, ENGINE_HASH_SIZE);
+    return 0;
+}
+
+int merkle_builder_finalize(merkle_builder* b, unsigned char* out_root, char* errbuf, int errbuf_len) {
+    return builder_fold(b, out_root, NULL, errbuf, errbuf_len);
+}
+
+int merkle_builder_tree(merkle_builder* b, unsigned char* out_nodes, char* errbuf, int errbuf_len) {
+    if (!b->keep_levels) {
+        snprintf(errbuf, errbuf_len, "Builder does not keep levels");
+        return 8;
+    }
+    unsigned char root[ENGINE_HASH_SIZE];
+    return builder_fold(b, root, out_nodes, errbuf, errbuf_len);
+}
+
+void merkle_builder_free(merkle_builder* b) {
+    delete b;
+}
+
+size_t merkle_tree_nodes(size_t n) {
+    if (n == 0)
+        return 0;
+    size_t total = n;
+    while (n > 1) {
+        n = (n + 1) / 2;
+        total += n;
+    }
+    return total;
+}
+
+int merkle_tree_buf(const unsigned char* data, const size_t* offsets, size_t n, const engine_params* params,
+                    unsigned char* out_nodes, char* errbuf, int errbuf_len) {
+    if (n == 0) {
+        snprintf(errbuf, errbuf_len, "Empty input");
+        return 1;
+    }
+    Params p;
+    if (int rc = read_params(params, &p, errbuf, errbuf_len)) {
+        return rc;
+    }
+
+    Level cur, next;
+    if (!leaf_hashes(Inputs::from_buf(data, offsets), n, p, &cur)) {
+        snprintf(errbuf, errbuf_len, "digest failed");
+        return 5;
+    }
+    for (;;) {
+        memcpy(out_nodes, cur.data.data(), cur.count * ENGINE_HASH_SIZE);
+        out_nodes += cur.count * ENGINE_HASH_SIZE;
+        if (cur.count == 1)
+            return 0;
+        if (!next_level(cur, p, &next)) {
+            snprintf(errbuf, errbuf_len, "digest failed");
+            return 5;
+        }
+        std::swap(cur, next);
+    }
+}
+
+int merkle_trees_buf(const unsigned char* data, const size_t* offsets, const size_t* set_offsets, size_t sets,
+                     const engine_params* params, int threads, unsigned char* out_nodes, int* out_codes,
+                     char* errbuf, int errbuf_len) {
+    // дерево набора i начинается после деревьев всех предыдущих наборов
+    std::vector<size_t> node_offsets(sets + 1, 0);
+    for (size_t i = 0; i < sets; ++i) {
+        node_offsets[i + 1] = node_offsets[i] + merkle_tree_nodes(set_offsets[i + 1] - set_offsets[i]);
+    }
+
+    std::mutex mu;
+    size_t first_failed = sets;
+    // как в merkle_roots_buf: параллелим по наборам, каждый набор - в одном потоке
+    parallel_for(sets, threads, [&](size_t begin, size_t end) {
+        char local_err[ENGINE_ERRBUF_SIZE];
+        for (size_t i = begin; i < end; ++i) {
+            engine_params set_params = params ? params[i] : engine_params{ENGINE_TREE_LEGACY, 1, ENGINE_HASH_SHA256};
+            set_params.threads = 1;
+            size_t from = set_offsets[i];
+            size_t n = set_offsets[i + 1] - from;
+            int rc = merkle_tree_buf(data, offsets + from, n, &set_params,
+                                     out_nodes + node_offsets[i] * ENGINE_HASH_SIZE, local_err, sizeof(local_err));
+            out_codes[i] = rc;
+            if (rc) {
+                std::lock_guard<std::mutex> lock(mu);
+                if (i < first_failed) {
+                    first_failed = i;
+                    snprintf(errbuf, errbuf_len, "set %zu: %s", i, local_err);
+                }
+            }
+        }
+        return true;
+    }, MIN_PARALLEL_SETS);
+    return first_failed < sets ? out_codes[first_failed] : 0;
+}
@@ -7,14 +7,36 @@ extern "C" {
 
 #define ENGINE_ERRBUF_SIZE 256
 
-// Размер SHA256 хеша
-#define SHA256_SIZE 32
+// Размер хеша узла. Все поддерживаемые алгоритмы дают 32 байта.
+#define ENGINE_HASH_SIZE 32
+
+// Максимальная длина пути в дереве (n < 2^64)
+#define ENGINE_MAX_DEPTH 64
+
+// Алгоритмы хеширования (OpenSSL EVP)
+#define ENGINE_HASH_SHA256 1
+#define ENGINE_HASH_SHA512_256 2
+#define ENGINE_HASH_SHA3_256 3
+
+// Версии дерева
+// LEGACY: лист = H(m), узел = H(l || r), последний узел нечетного уровня дублируется
+// RFC6962: лист = H(0x00 || m), узел = H(0x01 || l || r), последний узел нечетного
+// уровня поднимается без изменений (дерево как в RFC 6962)
+#define ENGINE_TREE_LEGACY 1
+#define ENGINE_TREE_RFC6962 2
+
+// Параметры построения дерева
+typedef struct {
+    int tree_version; // ENGINE_TREE_*
+    int threads;      // число потоков для хеширования листьев и уровней (<= 1 - в вызывающем потоке)
+    int hash_alg;     // ENGINE_HASH_* (0 - SHA256)
+} engine_params;
 
 // Выделяет Merkle-root из массива сообщений
 // inputs: массив указателей на байтовые строки
 // lengths: длины каждой строки
 // n: количество сообщений
-// out_root: malloc'ed 32 байта root (нужно free_root)
+// out_root: malloc'ed ENGINE_HASH_SIZE байт root (нужно free_root)
 // errbuf: buffer для ошибок (ENGINE_ERRBUF_SIZE)
 // Возвращает 0 если success, иначе !=0
 int merkle_root(const char** inputs, const size_t* lengths, size_t n, unsigned char** out_root, char* errbuf, int errbuf_len);
@@ -22,6 +44,128 @@ int merkle_root(const char** inputs, const size_t* lengths, size_t n, unsigned c
 // Освобождение root
 void free_root(unsigned char* root);
 
+// Строит inclusion proof для сообщения с номером index.
+// Дерево то же, что в merkle_root: последний узел нечетного уровня дублируется.
+// out_siblings: malloc'ed out_len*ENGINE_HASH_SIZE байт - хеши соседей снизу вверх
+// out_left: malloc'ed out_len флагов, 1 если сосед слева от узла на пути
+// out_len: длина пути (0 для одного сообщения)
+// Память освобождается через free_proof
+// Возвращает 0 если success, иначе !=0
+int merkle_proof(const char** inputs, const size_t* lengths, size_t n, size_t index,
+                 unsigned char** out_siblings, unsigned char** out_left, size_t* out_len,
+                 char* errbuf, int errbuf_len);
+
+// Освобождение proof
+void free_proof(unsigned char* siblings, unsigned char* left);
+
+// merkle_root с параметрами дерева (params == NULL -> LEGACY, SHA256, один поток).
+// При threads > 1 листья и уровни хешируются в общем пуле потоков процесса.
+int merkle_root_ex(const char** inputs, const size_t* lengths, size_t n, const engine_params* params,
+                   unsigned char** out_root, char* errbuf, int errbuf_len);
+
+// merkle_proof с параметрами дерева (params == NULL -> LEGACY).
+// Для RFC6962 уровни, где узел поднимается без пары, в путь не попадают.
+int merkle_proof_ex(const char** inputs, const size_t* lengths, size_t n, size_t index, const engine_params* params,
+                    unsigned char** out_siblings, unsigned char** out_left, size_t* out_len,
+                    char* errbuf, int errbuf_len);
+
+// Вариант merkle_root_ex без аллокаций на сообщение: все сообщения лежат
+// подряд в data, сообщение i занимает [offsets[i], offsets[i+1]).
+// offsets: n+1 элементов, offsets[0] == 0
+// out_root: буфер вызывающего на ENGINE_HASH_SIZE байт
+// Возвращает 0 если success, иначе !=0
+int merkle_root_buf(const unsigned char* data, const size_t* offsets, size_t n, const engine_params* params,
+                    unsigned char* out_root, char* errbuf, int errbuf_len);
+
+// Вариант merkle_proof_ex над тем же буфером.
+// out_siblings: буфер вызывающего на ENGINE_MAX_DEPTH*ENGINE_HASH_SIZE байт
+// out_left: буфер вызывающего на ENGINE_MAX_DEPTH флагов
+int merkle_proof_buf(const unsigned char* data, const size_t* offsets, size_t n, size_t index, const engine_params* params,
+                     unsigned char* out_siblings, unsigned char* out_left, size_t* out_len,
+                     char* errbuf, int errbuf_len);
+
+// Roots многих независимых наборов сообщений за один вызов (например, батчи разных чатов).
+// Все сообщения лежат подряд в data как в merkle_root_buf (offsets: всего сообщений + 1),
+// набор i - сообщения [set_offsets[i], set_offsets[i+1]), set_offsets: sets+1 элементов.
+// params: массив из sets элементов, у каждого набора своя версия дерева и алгоритм
+// (params == NULL -> LEGACY, SHA256 для всех; поле threads не используется).
+// threads: потоки на весь вызов, наборы делятся между ними.
+// out_roots: буфер вызывающего на sets*ENGINE_HASH_SIZE байт
+// out_codes: буфер на sets кодов, 0 - root набора посчитан
+// Возвращает 0, если посчитаны все наборы, иначе код первого неудачного (причина в errbuf)
+int merkle_roots_buf(const unsigned char* data, const size_t* offsets, const size_t* set_offsets, size_t sets,
+                     const engine_params* params, int threads, unsigned char* out_roots, int* out_codes,
+                     char* errbuf, int errbuf_len);
+
+// Multiproof для нескольких сообщений одного дерева: узлы, нужные для подъема от всех
+// листьев indices к корню, без повторов и без узлов, вычислимых из самих листьев.
+// Сообщения в data/offsets как в merkle_root_buf.
+// indices: k позиций строго по возрастанию, каждая < n
+// out_nodes: malloc'ed out_count*ENGINE_HASH_SIZE байт - уровни снизу вверх, на уровне
+// слева направо (нужно free_multiproof)
+// Возвращает 0 если success, иначе !=0
+int merkle_multiproof_buf(const unsigned char* data, const size_t* offsets, size_t n,
+                          const size_t* indices, size_t k, const engine_params* params,
+                          unsigned char** out_nodes, size_t* out_count, char* errbuf, int errbuf_len);
+
+// Освобождение multiproof
+void free_multiproof(unsigned char* nodes);
+
+// Потоковый построитель root: сообщения подаются по одному, в памяти только
+// пики полных поддеревьев (не больше ENGINE_MAX_DEPTH хешей).
+// Root совпадает с merkle_root_ex для тех же сообщений и params.
+// Один построитель нельзя использовать из нескольких потоков одновременно.
+typedef struct merkle_builder merkle_builder;
+
+// Создает построитель (params == NULL -> LEGACY, SHA256; threads не используется).
+// Возвращает NULL при ошибке, причина в errbuf.
+merkle_builder* merkle_builder_new(const engine_params* params, char* errbuf, int errbuf_len);
+
+// Добавляет очередное сообщение
+// Возвращает 0 если success, иначе !=0
+int merkle_builder_append(merkle_builder* b, const unsigned char* data, size_t len, char* errbuf, int errbuf_len);
+
+// Число добавленных сообщений
+size_t merkle_builder_count(const merkle_builder* b);
+
+// Записывает root всех добавленных сообщений в out_root (ENGINE_HASH_SIZE байт).
+// Состояние не меняется: можно продолжить append и снова вызвать finalize.
+// Возвращает 0 если success, иначе !=0 (1 - ни одного сообщения)
+int merkle_builder_finalize(merkle_builder* b, unsigned char* out_root, char* errbuf, int errbuf_len);
+
+// Освобождение построителя
+void merkle_builder_free(merkle_builder* b);
+
+// Число узлов дерева из n листьев на всех уровнях. Уровень k содержит
+// ceil(n/2^k) узлов, поднятый без пары узел RFC6962 тоже хранится на своем уровне.
+size_t merkle_tree_nodes(size_t n);
+
+// Все уровни дерева подряд, от листьев к корню (корень - последний узел).
+// Сообщения в data/offsets как в merkle_root_buf.
+// out_nodes: буфер вызывающего на merkle_tree_nodes(n)*ENGINE_HASH_SIZE байт
+// Возвращает 0 если success, иначе !=0
+int merkle_tree_buf(const unsigned char* data, const size_t* offsets, size_t n, const engine_params* params,
+                    unsigned char* out_nodes, char* errbuf, int errbuf_len);
+
+// Построитель, который дополнительно хранит все узлы (ENGINE_HASH_SIZE байт на узел,
+// сами сообщения не хранятся), чтобы отдать дерево через merkle_builder_tree
+merkle_builder* merkle_builder_new_tree(const engine_params* params, char* errbuf, int errbuf_len);
+
+// Все уровни дерева добавленных сообщений в той же раскладке, что merkle_tree_buf.
+// out_nodes: merkle_tree_nodes(merkle_builder_count(b))*ENGINE_HASH_SIZE байт
+// Возвращает 0 если success, иначе !=0 (8 - построитель создан без хранения узлов)
+int merkle_builder_tree(merkle_builder* b, unsigned char* out_nodes, char* errbuf, int errbuf_len);
+
+// Деревья многих независимых наборов за один вызов: merkle_tree_buf для каждого набора,
+// наборы и params как в merkle_roots_buf. Дерево набора i лежит в out_nodes сразу после
+// деревьев наборов 0..i-1 и занимает merkle_tree_nodes(n_i) узлов.
+// out_nodes: буфер вызывающего на сумму merkle_tree_nodes(n_i)*ENGINE_HASH_SIZE байт
+// out_codes: буфер на sets кодов, 0 - дерево набора построено
+// Возвращает 0, если построены все наборы, иначе код первого неудачного (причина в errbuf)
+int merkle_trees_buf(const unsigned char* data, const size_t* offsets, const size_t* set_offsets, size_t sets,
+                     const engine_params* params, int threads, unsigned char* out_nodes, int* out_codes,
+                     char* errbuf, int errbuf_len);
+
 #ifdef __cplusplus
 }
 #endif
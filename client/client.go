@@ -15,7 +15,7 @@ import (
 
 var (
 	addr     = flag.String("addr", "http://localhost:8080", "base URL of API (include http:// and port)")
-	scenario = flag.String("scenario", "all", "scenario to run: one | idempotent | concurrency | bigpayload | merkle | all")
+	scenario = flag.String("scenario", "all", "scenario to run: one | idempotent | concurrency | bigpayload | verify | all")
 	conns    = flag.Int("conns", 20, "number of concurrent workers for concurrency scenario")
 	reqs     = flag.Int("reqs", 100, "total requests to send in concurrency scenario")
 	timeout  = flag.Duration("timeout", 10*time.Second, "request timeout per HTTP call")
@@ -29,9 +29,21 @@ type MessagePayload struct {
 	BatchID     int64  `json:"batch_id,omitempty"`
 }
 
-type MerklePayload struct {
-	ChatID int64  `json:"chat_id"`
-	Root   string `json:"root"`
+// ProofPayload - inclusion proof из GET /messages/{id}
+type ProofPayload struct {
+	BatchID  int64    `json:"batch_id"`
+	Root     string   `json:"root"`
+	Index    int      `json:"index"`
+	Siblings []string `json:"siblings"`
+	Left     []bool   `json:"left"`
+}
+
+type VerifyPayload struct {
+	Payload string        `json:"payload"`
+	Proof   *ProofPayload `json:"proof"`
+	Root    string        `json:"root,omitempty"`
+	BatchID int64         `json:"batch_id,omitempty"`
+	ChatID  int64         `json:"chat_id,omitempty"`
 }
 
 func main() {
@@ -46,8 +58,8 @@ func main() {
 		runConcurrency(*conns, *reqs)
 	case "bigpayload":
 		runBigPayload()
-	case "merkle":
-		runMerkle()
+	case "verify":
+		runVerify()
 	case "all":
 		runAll(*conns, *reqs)
 	default:
@@ -102,7 +114,7 @@ func runOne() {
 }
 
 func runIdempotent() {
-	fmt.Println("Scenario: idempotent — send same Idempotency-Key twice")
+	fmt.Println("Scenario: idempotent — send same Idempotency-Key twice, then with a different body")
 	key := fmt.Sprintf("idem-%d", time.Now().UnixNano())
 	msg := MessagePayload{
 		ChatID:  1,
@@ -123,6 +135,18 @@ func runIdempotent() {
 		}
 		time.Sleep(300 * time.Millisecond)
 	}
+
+	// тот же ключ с другим телом сервер отклоняет с 422
+	msg.Payload = "Different payload under the same key"
+	data, _ = json.Marshal(msg)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	status, body, dur, err := doRequest(ctx, "POST", url, data, map[string]string{"Idempotency-Key": key})
+	if err != nil {
+		fmt.Printf("Mismatch attempt error: %v\n", err)
+		return
+	}
+	fmt.Printf("Mismatch attempt: status=%d (want 422) time=%v body=%s\n", status, dur, string(body))
 }
 
 func runConcurrency(workers, total int) {
@@ -225,17 +249,63 @@ func runBigPayload() {
 	fmt.Printf("Status: %d, time: %v, body len: %d\n", status, dur, len(body))
 }
 
-func runMerkle() {
-	fmt.Println("Scenario: merkle — call /merkle endpoint")
-	pl := MerklePayload{
-		ChatID: 1,
-		Root:   "deadbeefcafebabedeadbeef", // example root
+func runVerify() {
+	fmt.Println("Scenario: verify — send a message, wait for its batch and check the proof via /verify")
+	msg := MessagePayload{
+		ChatID:  1,
+		UserID:  42,
+		Payload: fmt.Sprintf("verify test payload %d", time.Now().UnixNano()),
 	}
-	data, _ := json.Marshal(pl)
-	url := *addr + "/merkle"
+	data, _ := json.Marshal(msg)
 	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	status, body, _, err := doRequest(ctx, "POST", *addr+"/messages", data, nil)
+	cancel()
+	if err != nil || status != http.StatusOK {
+		fmt.Printf("Submit error: status=%d err=%v body=%s\n", status, err, string(body))
+		return
+	}
+	var accepted struct {
+		MessageID int64 `json:"message_id"`
+	}
+	if err := json.Unmarshal(body, &accepted); err != nil {
+		fmt.Printf("Submit response error: %v\n", err)
+		return
+	}
+
+	// ждем flush: до коммита батча сообщение в статусе pending и без proof
+	var lookup struct {
+		Status string        `json:"status"`
+		Proof  *ProofPayload `json:"proof"`
+	}
+	deadline := time.Now().Add(*timeout)
+	for lookup.Proof == nil {
+		if time.Now().After(deadline) {
+			fmt.Printf("Message %d is still %s after %v\n", accepted.MessageID, lookup.Status, *timeout)
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		status, body, _, err = doRequest(ctx, "GET", fmt.Sprintf("%s/messages/%d", *addr, accepted.MessageID), nil, nil)
+		cancel()
+		if err != nil || status != http.StatusOK {
+			fmt.Printf("Lookup error: status=%d err=%v body=%s\n", status, err, string(body))
+			return
+		}
+		if err := json.Unmarshal(body, &lookup); err != nil {
+			fmt.Printf("Lookup response error: %v\n", err)
+			return
+		}
+	}
+
+	data, _ = json.Marshal(VerifyPayload{
+		Payload: msg.Payload,
+		Proof:   lookup.Proof,
+		Root:    lookup.Proof.Root,
+		ChatID:  msg.ChatID,
+	})
+	ctx, cancel = context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
-	status, body, dur, err := doRequest(ctx, "POST", url, data, nil)
+	status, body, dur, err := doRequest(ctx, "POST", *addr+"/verify", data, nil)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
@@ -251,7 +321,7 @@ func runAll(workers, total int) {
 	time.Sleep(200 * time.Millisecond)
 	runBigPayload()
 	time.Sleep(200 * time.Millisecond)
-	runMerkle()
+	runVerify()
 	time.Sleep(200 * time.Millisecond)
 	runConcurrency(workers, total)
 }
@@ -2,42 +2,124 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/hex"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"google.golang.org/grpc"
+
+	"veriChat/go/internal/anchor"
 	"veriChat/go/internal/api"
+	"veriChat/go/internal/cgobridge"
 	"veriChat/go/internal/db"
+	"veriChat/go/internal/grpcapi"
+	"veriChat/go/internal/keyfile"
 	"veriChat/go/internal/metrics"
 	"veriChat/go/internal/service"
+	"veriChat/go/internal/tsa"
 )
 
 func main() {
 	if err := db.Init("user:pass@tcp(localhost:3306)/verichat?parseTime=true"); err != nil {
 		log.Fatal(err)
 	}
-	
+
 	metrics.Init("verichat")
+	log.Printf("merkle backend: %s\n", cgobridge.Backend)
 
 	db.InitRedis("localhost:6379", "", 0)
 
+	// ключ подписи tree head; смена файла - ротация, старый ключ остается в signing_keys
+	var signingKey ed25519.PrivateKey
+	if path := os.Getenv("VERICHAT_SIGNING_KEY"); path != "" {
+		key, err := keyfile.LoadEd25519Key(path, "signing key")
+		if err != nil {
+			log.Fatal(err)
+		}
+		signingKey = key
+	} else {
+		log.Println("VERICHAT_SIGNING_KEY is not set, tree heads are not signed")
+	}
+
+	// собственный ключ локальной TSA: метка времени не должна зависеть от ключа tree head
+	var timestamps tsa.Authority
+	if path := os.Getenv("VERICHAT_TSA_KEY"); path != "" {
+		key, err := keyfile.LoadEd25519Key(path, "tsa key")
+		if err != nil {
+			log.Fatal(err)
+		}
+		timestamps = tsa.NewLocal(key)
+	} else {
+		log.Println("VERICHAT_TSA_KEY is not set, batch roots are not timestamped")
+	}
+
+	// публикация батчей вне MySQL/Redis
+	var anchors []anchor.Anchor
+	if path := os.Getenv("VERICHAT_ANCHOR_FILE"); path != "" {
+		fileAnchor, err := anchor.NewFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer fileAnchor.Close()
+		anchors = append(anchors, fileAnchor)
+	}
+	if url := os.Getenv("VERICHAT_ANCHOR_URL"); url != "" {
+		anchors = append(anchors, anchor.NewHTTP(url))
+	}
+
+	// свидетели, чьи подписи под tree head принимаются (публичные ключи в hex через запятую)
+	var witnesses []ed25519.PublicKey
+	for _, h := range strings.Split(os.Getenv("VERICHAT_WITNESS_KEYS"), ",") {
+		if h = strings.TrimSpace(h); h == "" {
+			continue
+		}
+		pub, err := hex.DecodeString(h)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			log.Fatalf("VERICHAT_WITNESS_KEYS: invalid key %q", h)
+		}
+		witnesses = append(witnesses, pub)
+	}
+
 	svc := service.NewMessageService(service.Config{
-		BatchSize:    64,
-		BatchTimeout: 300 * time.Millisecond,
-		LockTTL:      5 * time.Second,
-		RedisClient:  db.RedisClient,
+		BatchSize:     64,
+		BatchTimeout:  300 * time.Millisecond,
+		LockTTL:       5 * time.Second,
+		RedisClient:   db.RedisClient,
+		TreeVersion:   cgobridge.TreeRFC6962,
+		MerkleWorkers: runtime.NumCPU(),
+		HashAlg:       cgobridge.HashSHA3_256, // новые чаты на SHA3, старые остаются на SHA-256
+		SigningKey:    signingKey,
+		TSA:           timestamps,
+		Anchors:       anchors,
+		Witnesses:     witnesses,
 	})
+	if err := svc.RegisterSigningKey(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := svc.RegisterTSAKey(context.Background()); err != nil {
+		log.Fatal(err)
+	}
 
 	server := api.NewServer(":8080", svc)
+	grpcServer := grpcapi.NewServer(":9090", svc)
 
 	go func() {
 		if err := server.Start(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen: %s\n", err)
 		}
 	}()
+	go func() {
+		if err := grpcServer.Start(); err != nil && err != grpc.ErrServerStopped {
+			log.Fatalf("grpc listen: %s\n", err)
+		}
+	}()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -47,6 +129,7 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	_ = server.Shutdown(ctx)
+	_ = grpcServer.Shutdown(ctx)
 	svc.Shutdown(ctx)
 	log.Println("Server exiting")
 }
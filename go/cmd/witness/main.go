@@ -0,0 +1,107 @@
+// witness - свидетель для tree head veriChat. Периодически забирает head чатов,
+// проверяет подпись сервиса и consistency proof от ранее виденного head и
+// отправляет свою подпись. Несогласованный head не подписывается.
+//
+//	witness -genkey witness.pem                     # новый ключ, печатает публичный ключ для VERICHAT_WITNESS_KEYS
+//	witness -key witness.pem -chats 1,2 -state witness.json -interval 30s
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"veriChat/go/internal/keyfile"
+	"veriChat/go/internal/witness"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "адрес API veriChat")
+	keyPath := flag.String("key", "witness.pem", "ключ свидетеля (PEM PKCS#8 Ed25519)")
+	genKey := flag.String("genkey", "", "создать ключ в этом файле, напечатать публичный ключ и выйти")
+	statePath := flag.String("state", "witness.json", "состояние: ключи сервиса и последние подписанные head")
+	chats := flag.String("chats", "", "чаты через запятую")
+	interval := flag.Duration("interval", 0, "пауза между проверками, 0 - проверить один раз")
+	trustNewKeys := flag.Bool("trust-new-keys", false, "принимать новые ключи сервиса из GET /keys (ротация); без флага - только ключи из -state")
+	flag.Parse()
+
+	if *genKey != "" {
+		key, err := witness.GenerateKey(*genKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.WriteString(hex.EncodeToString(key.Public().(ed25519.PublicKey)) + "\n")
+		return
+	}
+
+	var chatIDs []int64
+	for _, s := range strings.Split(*chats, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			log.Fatalf("invalid chat id %q", s)
+		}
+		chatIDs = append(chatIDs, id)
+	}
+	if len(chatIDs) == 0 {
+		log.Fatal("no chats to witness, use -chats")
+	}
+
+	key, err := keyfile.LoadEd25519Key(*keyPath, "witness key")
+	if err != nil {
+		log.Fatal(err)
+	}
+	state, err := witness.LoadState(*statePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := witness.New(*server, key)
+	w.State = state
+	w.TrustNewKeys = *trustNewKeys
+	log.Printf("witness %s for %s", hex.EncodeToString(w.PublicKey()), *server)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	for {
+		inconsistent := false
+		for _, chatID := range chatIDs {
+			sth, err := w.Check(ctx, chatID)
+			switch {
+			case errors.Is(err, witness.ErrInconsistent):
+				inconsistent = true
+				log.Printf("chat %d: SPLIT VIEW OR REWRITE: %v", chatID, err)
+			case err != nil:
+				log.Printf("chat %d: %v", chatID, err)
+			case sth != nil:
+				log.Printf("chat %d: cosigned batch %d, size %d, root %x", chatID, sth.BatchID, sth.Size, sth.Root)
+			}
+		}
+		if err := w.State.Save(*statePath); err != nil {
+			log.Fatal(err)
+		}
+
+		if *interval == 0 {
+			if inconsistent {
+				os.Exit(2)
+			}
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*interval):
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Package anchor - публикация root батчей вне MySQL/Redis, чтобы оператор с доступом
+// к базе не мог незаметно их переписать.
+package anchor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"veriChat/go/pkg/verify"
+)
+
+// Anchor публикует запись батча во внешнее хранилище
+type Anchor interface {
+	// Name имя anchor, ключ квитанций в batch_anchors
+	Name() string
+	// Anchor публикует батч и возвращает квитанцию
+	Anchor(ctx context.Context, batch verify.Batch) (*Receipt, error)
+}
+
+// Receipt - подтверждение публикации
+type Receipt struct {
+	Reference string // где искать запись: позиция в файле, id во внешнем сервисе
+	Data      []byte // ответ хранилища как есть
+}
+
+// Entry - опубликованная запись батча. BatchHash связывает ее с цепочкой батчей
+// (verify.BatchHash), так что переписать один батч незаметно нельзя.
+type Entry struct {
+	ChatID        int64  `json:"chat_id"`
+	BatchID       int64  `json:"batch_id"`
+	FromMessageID int64  `json:"from_message_id"`
+	ToMessageID   int64  `json:"to_message_id"`
+	TreeVersion   string `json:"tree_version"`
+	HashAlg       string `json:"hash_alg"`
+	Root          string `json:"root"`
+	ChatSize      uint64 `json:"chat_size"`
+	ChatRoot      string `json:"chat_root"`
+	PrevBatchHash string `json:"prev_batch_hash"`
+	BatchHash     string `json:"batch_hash"`
+}
+
+// NewEntry запись для публикации батча
+func NewEntry(b verify.Batch) Entry {
+	return Entry{
+		ChatID:        b.ChatID,
+		BatchID:       b.BatchID,
+		FromMessageID: b.FromMessageID,
+		ToMessageID:   b.ToMessageID,
+		TreeVersion:   b.Params().Version.String(),
+		HashAlg:       b.HashAlg.String(),
+		Root:          fmt.Sprintf("%x", b.RootHash),
+		ChatSize:      b.ChatSize,
+		ChatRoot:      fmt.Sprintf("%x", b.ChatRoot),
+		PrevBatchHash: fmt.Sprintf("%x", b.PrevBatchHash),
+		BatchHash:     fmt.Sprintf("%x", verify.BatchHash(b)),
+	}
+}
+
+func (e Entry) marshal() ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("anchor entry: %w", err)
+	}
+	return data, nil
+}
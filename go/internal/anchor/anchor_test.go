@@ -0,0 +1,132 @@
+package anchor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBatch(id int64) verify.Batch {
+	return verify.Batch{
+		BatchID:       id,
+		ChatID:        3,
+		RootHash:      verify.HashSHA256.Sum([]byte(fmt.Sprintf("root %d", id))),
+		FromMessageID: id * 10,
+		ToMessageID:   id*10 + 9,
+		TreeVersion:   verify.TreeRFC6962,
+		HashAlg:       verify.HashSHA256,
+		ChatSize:      uint64(id * 10),
+		ChatRoot:      verify.HashSHA256.Sum([]byte(fmt.Sprintf("chat root %d", id))),
+		PrevBatchHash: verify.GenesisBatchHash(),
+	}
+}
+
+func TestFileAnchorAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anchors.jsonl")
+	a, err := NewFile(path)
+	require.NoError(t, err)
+
+	var receipts []*Receipt
+	for id := int64(1); id <= 3; id++ {
+		r, err := a.Anchor(context.Background(), testBatch(id))
+		require.NoError(t, err)
+		receipts = append(receipts, r)
+	}
+	require.NoError(t, a.Close())
+	assert.Equal(t, path+"@0", receipts[0].Reference)
+
+	// повторное открытие дописывает, а не перезаписывает
+	a, err = NewFile(path)
+	require.NoError(t, err)
+	_, err = a.Anchor(context.Background(), testBatch(4))
+	require.NoError(t, err)
+	require.NoError(t, a.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e Entry
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &e))
+		entries = append(entries, e)
+	}
+	require.Len(t, entries, 4)
+	for i, e := range entries {
+		b := testBatch(int64(i + 1))
+		assert.Equal(t, b.BatchID, e.BatchID)
+		assert.Equal(t, fmt.Sprintf("%x", b.RootHash), e.Root)
+		assert.Equal(t, fmt.Sprintf("%x", verify.BatchHash(b)), e.BatchHash)
+	}
+
+	// Reference указывает на начало своей строки
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var offset int
+	_, err = fmt.Sscanf(receipts[1].Reference[len(path)+1:], "%d", &offset)
+	require.NoError(t, err)
+	assert.Equal(t, string(receipts[1].Data), string(data[offset:offset+len(receipts[1].Data)]))
+}
+
+func TestHTTPAnchor(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []Entry
+		fail     = true
+	)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if fail {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var e Entry
+		if !assert.NoError(t, json.Unmarshal(body, &e)) {
+			return
+		}
+		assert.Equal(t, fmt.Sprintf("batch-%d", e.BatchID), r.Header.Get("Idempotency-Key"))
+		received = append(received, e)
+		w.Header().Set("Location", fmt.Sprintf("/entries/%d", len(received)))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"seq":%d}`, len(received))
+	}))
+	defer stub.Close()
+
+	a := NewHTTP(stub.URL)
+	_, err := a.Anchor(context.Background(), testBatch(7))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "503")
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	r, err := a.Anchor(context.Background(), testBatch(7))
+	require.NoError(t, err)
+	assert.Equal(t, "/entries/1", r.Reference)
+	assert.JSONEq(t, `{"seq":1}`, string(r.Data))
+	require.Len(t, received, 1)
+	assert.Equal(t, int64(7), received[0].BatchID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = a.Anchor(ctx, testBatch(8))
+	assert.ErrorIs(t, err, context.Canceled)
+}
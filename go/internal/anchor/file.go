@@ -0,0 +1,64 @@
+package anchor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"veriChat/go/pkg/verify"
+)
+
+// File - append-only журнал: одна JSON строка Entry на батч. Файл открывается
+// с O_APPEND и синхронизируется после каждой записи. Держать его стоит на
+// носителе, к которому у оператора базы нет доступа на запись.
+type File struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFile открывает (или создает) журнал path
+func NewFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("anchor file: %w", err)
+	}
+	return &File{path: path, f: f}, nil
+}
+
+// Name возвращает "file:<path>"
+func (a *File) Name() string {
+	return "file:" + a.path
+}
+
+// Anchor дописывает строку в журнал. Reference - смещение строки в файле.
+func (a *File) Anchor(_ context.Context, batch verify.Batch) (*Receipt, error) {
+	line, err := NewEntry(batch).marshal()
+	if err != nil {
+		return nil, err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st, err := a.f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("anchor file %s: %w", a.path, err)
+	}
+	if _, err := a.f.Write(line); err != nil {
+		return nil, fmt.Errorf("anchor file %s: %w", a.path, err)
+	}
+	if err := a.f.Sync(); err != nil {
+		return nil, fmt.Errorf("anchor file %s: %w", a.path, err)
+	}
+	return &Receipt{Reference: fmt.Sprintf("%s@%d", a.path, st.Size()), Data: line}, nil
+}
+
+// Close закрывает журнал
+func (a *File) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.f.Close()
+}
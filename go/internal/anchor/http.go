@@ -0,0 +1,66 @@
+package anchor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"veriChat/go/pkg/verify"
+)
+
+// maxReceiptSize сколько байт ответа сохраняется как квитанция
+const maxReceiptSize = 64 << 10
+
+// HTTP отправляет Entry POST запросом с JSON телом. Успех - любой 2xx,
+// квитанция - тело ответа, Reference - заголовок Location, если он есть.
+type HTTP struct {
+	URL    string
+	Client *http.Client // nil - клиент с таймаутом 10s
+}
+
+// NewHTTP anchor для url
+func NewHTTP(url string) *HTTP {
+	return &HTTP{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Name возвращает "http:<url>"
+func (a *HTTP) Name() string {
+	return "http:" + a.URL
+}
+
+// Anchor публикует батч
+func (a *HTTP) Anchor(ctx context.Context, batch verify.Batch) (*Receipt, error) {
+	body, err := NewEntry(batch).marshal()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("anchor %s: %w", a.URL, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// повтор после сбоя отправляет ту же запись, по ключу хранилище может отбросить дубликат
+	req.Header.Set("Idempotency-Key", fmt.Sprintf("batch-%d", batch.BatchID))
+
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("anchor %s: %w", a.URL, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxReceiptSize))
+	if err != nil {
+		return nil, fmt.Errorf("anchor %s: %w", a.URL, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("anchor %s: status %d: %s", a.URL, resp.StatusCode, bytes.TrimSpace(data))
+	}
+	return &Receipt{Reference: resp.Header.Get("Location"), Data: data}, nil
+}
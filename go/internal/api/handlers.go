@@ -1,43 +1,99 @@
 package api
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 	"veriChat/go/internal/cgobridge"
+	"veriChat/go/internal/db"
 	"veriChat/go/internal/service"
+	"veriChat/go/pkg/verify"
 )
 
-// PostMerkleHandler обрабатывает POST /merkle
-func PostMerkleHandler(w http.ResponseWriter, r *http.Request) {
-    var msgs []string
-    if err := json.NewDecoder(r.Body).Decode(&msgs); err != nil {
-        w.WriteHeader(http.StatusBadRequest)
-        fmt.Fprintf(w, "invalid input: %v", err)
-        return
-    }
-
-	// TODO: вынести лишнюю логику в service
-	
-    // Преобразуем в [][]byte
-    byteMsgs := make([][]byte, len(msgs))
-    for i, m := range msgs {
-        byteMsgs[i] = []byte(m)
-    }
-
-    // Вызов C++ модуля через bridge
-    root, err := cgobridge.MerkleRoot(byteMsgs)
-    if err != nil {
-        w.WriteHeader(http.StatusInternalServerError)
-        fmt.Fprintf(w, "error: %v", err)
-        return
-    }
-
-    // Ответ JSON
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]string{
-        "merkle_root": fmt.Sprintf("%x", root),
-    })
+type inclusionProofJSON struct {
+	Index    int      `json:"index"`
+	Siblings []string `json:"siblings"`
+	Left     []bool   `json:"left"`
+}
+
+type verifyProofRequest struct {
+	Payload string              `json:"payload"`
+	Proof   *inclusionProofJSON `json:"proof"`
+	Root    string              `json:"root,omitempty"`
+	BatchID int64               `json:"batch_id,omitempty"`
+	ChatID  int64               `json:"chat_id,omitempty"`
+}
+
+type verifyProofResponse struct {
+	Valid        bool   `json:"valid"`
+	Reason       string `json:"reason,omitempty"`
+	ComputedRoot string `json:"computed_root,omitempty"`
+	StoredRoot   string `json:"stored_root,omitempty"`
+	BatchID      int64  `json:"batch_id,omitempty"`
+	ChatID       int64  `json:"chat_id,omitempty"`
+	TreeVersion  string `json:"tree_version,omitempty"`
+	HashAlg      string `json:"hash_alg,omitempty"`
+}
+
+// makeVerifyHandler обрабатывает POST /verify: проверка inclusion proof на стороне сервера
+func makeVerifyHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req verifyProofRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid input: %v", err))
+			return
+		}
+
+		check := service.ProofCheck{Payload: []byte(req.Payload), BatchID: req.BatchID, ChatID: req.ChatID}
+		if req.Root != "" {
+			root, err := hex.DecodeString(req.Root)
+			if err != nil {
+				writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid root: %v", err))
+				return
+			}
+			check.Root = root
+		}
+		if req.Proof != nil {
+			check.Proof = &verify.Proof{
+				Index:    req.Proof.Index,
+				Siblings: make([][]byte, len(req.Proof.Siblings)),
+				Left:     req.Proof.Left,
+			}
+			for i, h := range req.Proof.Siblings {
+				sib, err := hex.DecodeString(h)
+				if err != nil {
+					writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid proof sibling %d: %v", i, err))
+					return
+				}
+				check.Proof.Siblings[i] = sib
+			}
+		}
+
+		res, err := svc.VerifyMessageProof(r.Context(), check)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := verifyProofResponse{Valid: res.Valid, Reason: res.Reason}
+		if res.ComputedRoot != nil {
+			resp.ComputedRoot = fmt.Sprintf("%x", res.ComputedRoot)
+		}
+		if b := res.Batch; b != nil {
+			resp.StoredRoot = fmt.Sprintf("%x", b.RootHash)
+			resp.BatchID = b.BatchID
+			resp.ChatID = b.ChatID
+			resp.TreeVersion = cgobridge.TreeVersion(b.TreeVersion).String()
+			resp.HashAlg = cgobridge.HashAlg(b.HashAlg).String()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
 }
 
 type postMessageRequest struct {
@@ -47,6 +103,9 @@ type postMessageRequest struct {
 	IdempKey  string `json:"idempotency_key,omitempty"`
 }
 
+// maxIdempotencyKeyLen предел длины Idempotency-Key
+const maxIdempotencyKeyLen = 255
+
 type postMessageResponse struct {
 	MessageID int64  `json:"message_id"`
 	Status    string `json:"status"`
@@ -55,19 +114,32 @@ type postMessageResponse struct {
 func makePostMessageHandler(svc *service.MessageService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			writeProblem(w, http.StatusMethodNotAllowed, "method not allowed")
 			return
 		}
 
 		var req postMessageRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, fmt.Sprintf("invalid input: %v", err), http.StatusBadRequest)
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid input: %v", err))
 			return
 		}
 
-		id, err := svc.SubmitMessage(r.Context(), req.ChatID, req.UserID, []byte(req.Payload), req.IdempKey)
+		// ключ из заголовка Idempotency-Key, idempotency_key в теле - для старых клиентов
+		idempKey := r.Header.Get("Idempotency-Key")
+		if idempKey == "" {
+			idempKey = req.IdempKey
+		} else if req.IdempKey != "" && req.IdempKey != idempKey {
+			writeProblem(w, http.StatusBadRequest, "Idempotency-Key header and idempotency_key differ")
+			return
+		}
+		if len(idempKey) > maxIdempotencyKeyLen {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key longer than %d bytes", maxIdempotencyKeyLen))
+			return
+		}
+
+		id, err := svc.SubmitMessage(r.Context(), req.ChatID, req.UserID, []byte(req.Payload), idempKey)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("failed: %v", err), http.StatusInternalServerError)
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
 			return
 		}
 
@@ -79,3 +151,749 @@ func makePostMessageHandler(svc *service.MessageService) http.HandlerFunc {
 		json.NewEncoder(w).Encode(resp)
 	}
 }
+
+type consistencyResponse struct {
+	ChatID   int64    `json:"chat_id"`
+	FromSize int64    `json:"from_size"`
+	ToSize   int64    `json:"to_size"`
+	FromRoot string   `json:"from_root"`
+	ToRoot   string   `json:"to_root"`
+	HashAlg  string   `json:"hash_alg"`
+	Proof    []string `json:"proof"`
+}
+
+// makeConsistencyHandler обрабатывает GET /chats/{id}/consistency?from=N&to=M
+func makeConsistencyHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid chat id: %v", err))
+			return
+		}
+		from, err := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid from: %v", err))
+			return
+		}
+		to, err := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid to: %v", err))
+			return
+		}
+
+		proof, err := svc.GetConsistencyProof(r.Context(), chatID, from, to)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := consistencyResponse{
+			ChatID:   proof.ChatID,
+			FromSize: proof.FromSize,
+			ToSize:   proof.ToSize,
+			FromRoot: fmt.Sprintf("%x", proof.FromRoot),
+			ToRoot:   fmt.Sprintf("%x", proof.ToRoot),
+			HashAlg:  proof.HashAlg.String(),
+			Proof:    make([]string, len(proof.Proof)),
+		}
+		for i, h := range proof.Proof {
+			resp.Proof[i] = fmt.Sprintf("%x", h)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type chainResponse struct {
+	ChatID        int64  `json:"chat_id"`
+	Batches       int    `json:"batches"`
+	OK            bool   `json:"ok"`
+	BrokenBatchID int64  `json:"broken_batch_id,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// makeChainHandler обрабатывает GET /chats/{id}/chain
+func makeChainHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid chat id: %v", err))
+			return
+		}
+
+		status, err := svc.VerifyBatchChain(r.Context(), chatID)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(chainResponse{
+			ChatID:        status.ChatID,
+			Batches:       status.Batches,
+			OK:            status.OK,
+			BrokenBatchID: status.BrokenBatchID,
+			Reason:        status.Reason,
+		})
+	}
+}
+
+type treeHeadResponse struct {
+	ChatID      int64  `json:"chat_id"`
+	BatchID     int64  `json:"batch_id"`
+	TreeSize    uint64 `json:"tree_size"`
+	Root        string `json:"root"`
+	BatchRoot   string `json:"batch_root"`
+	TimestampMs int64  `json:"timestamp_ms"`
+	KeyID       string `json:"key_id"`
+	Signature   string `json:"signature"`
+}
+
+func newTreeHeadResponse(sth *verify.SignedTreeHead) treeHeadResponse {
+	return treeHeadResponse{
+		ChatID:      sth.ChatID,
+		BatchID:     sth.BatchID,
+		TreeSize:    sth.Size,
+		Root:        fmt.Sprintf("%x", sth.Root),
+		BatchRoot:   fmt.Sprintf("%x", sth.BatchRoot),
+		TimestampMs: sth.Timestamp.UnixMilli(),
+		KeyID:       sth.KeyID,
+		Signature:   fmt.Sprintf("%x", sth.Signature),
+	}
+}
+
+// makeTreeHeadHandler обрабатывает GET /chats/{id}/head
+func makeTreeHeadHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid chat id: %v", err))
+			return
+		}
+
+		sth, err := svc.GetSignedTreeHead(r.Context(), chatID)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newTreeHeadResponse(sth))
+	}
+}
+
+type signingKeyResponse struct {
+	KeyID       string     `json:"key_id"`
+	Algorithm   string     `json:"algorithm"`
+	PublicKey   string     `json:"public_key"`
+	ActivatedAt time.Time  `json:"activated_at"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+}
+
+type keysResponse struct {
+	Keys    []signingKeyResponse `json:"keys"`
+	TSAKeys []signingKeyResponse `json:"tsa_keys"`
+}
+
+func newSigningKeyResponses(keys []*db.SigningKey) []signingKeyResponse {
+	resp := make([]signingKeyResponse, len(keys))
+	for i, k := range keys {
+		resp[i] = signingKeyResponse{
+			KeyID:       k.KeyID,
+			Algorithm:   "ed25519",
+			PublicKey:   fmt.Sprintf("%x", k.PublicKey),
+			ActivatedAt: k.ActivatedAt,
+			RetiredAt:   k.RetiredAt,
+		}
+	}
+	return resp
+}
+
+// makeKeysHandler обрабатывает GET /keys: публичные ключи подписи tree head и ключи
+// локальной TSA, включая выведенные из ротации
+func makeKeysHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := svc.ListSigningKeys(r.Context())
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		tsaKeys, err := svc.ListTSAKeys(r.Context())
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := keysResponse{Keys: newSigningKeyResponses(keys), TSAKeys: newSigningKeyResponses(tsaKeys)}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type timestampResponse struct {
+	BatchID   int64     `json:"batch_id"`
+	Root      string    `json:"root"`
+	HashAlg   string    `json:"hash_alg"`
+	Authority string    `json:"authority"`
+	GenTime   time.Time `json:"gen_time"`
+	Token     []byte    `json:"token"` // DER, в JSON - base64
+}
+
+// makeTimestampHandler обрабатывает GET /batches/{id}/timestamp: проверяет метку времени
+// батча и отдает токен для проверки на стороне клиента
+func makeTimestampHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batchID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid batch id: %v", err))
+			return
+		}
+
+		ts, err := svc.VerifyBatchTimestamp(r.Context(), batchID)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(timestampResponse{
+			BatchID:   ts.BatchID,
+			Root:      fmt.Sprintf("%x", ts.Root),
+			HashAlg:   ts.HashAlg.String(),
+			Authority: ts.Authority,
+			GenTime:   ts.GenTime,
+			Token:     ts.Token,
+		})
+	}
+}
+
+type anchorResponse struct {
+	Anchor     string     `json:"anchor"`
+	Status     string     `json:"status"`
+	Attempts   int        `json:"attempts"`
+	LastError  string     `json:"last_error,omitempty"`
+	Reference  string     `json:"reference,omitempty"`
+	Receipt    []byte     `json:"receipt,omitempty"` // в JSON - base64
+	AnchoredAt *time.Time `json:"anchored_at,omitempty"`
+}
+
+// makeAnchorsHandler обрабатывает GET /batches/{id}/anchors: квитанции публикаций батча
+func makeAnchorsHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batchID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid batch id: %v", err))
+			return
+		}
+
+		anchors, err := svc.GetBatchAnchors(r.Context(), batchID)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := anchorsResponse{BatchID: batchID, Anchors: make([]anchorResponse, len(anchors))}
+		for i, a := range anchors {
+			resp.Anchors[i] = anchorResponse{
+				Anchor:     a.Anchor,
+				Status:     a.Status,
+				Attempts:   a.Attempts,
+				LastError:  a.LastError,
+				Reference:  a.Reference,
+				Receipt:    a.Receipt,
+				AnchoredAt: a.AnchoredAt,
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type anchorsResponse struct {
+	BatchID int64            `json:"batch_id"`
+	Anchors []anchorResponse `json:"anchors"`
+}
+
+type witnessHeadResponse struct {
+	treeHeadResponse
+	HashAlg     string   `json:"hash_alg"`
+	FromSize    int64    `json:"from_size,omitempty"`
+	Consistency []string `json:"consistency"`
+}
+
+// makeWitnessHeadHandler обрабатывает GET /witness/chats/{id}/head?from=N: последний
+// подписанный head и consistency proof от размера N, который свидетель видел раньше
+func makeWitnessHeadHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid chat id: %v", err))
+			return
+		}
+		var from int64
+		if v := r.URL.Query().Get("from"); v != "" {
+			if from, err = strconv.ParseInt(v, 10, 64); err != nil || from < 0 {
+				writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid from: %q", v))
+				return
+			}
+		}
+
+		wh, err := svc.GetWitnessHead(r.Context(), chatID, from)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := witnessHeadResponse{
+			treeHeadResponse: newTreeHeadResponse(wh.Head),
+			HashAlg:          wh.HashAlg.String(),
+			FromSize:         wh.FromSize,
+			Consistency:      make([]string, len(wh.Proof)),
+		}
+		for i, h := range wh.Proof {
+			resp.Consistency[i] = fmt.Sprintf("%x", h)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type cosignatureJSON struct {
+	KeyID       string `json:"key_id"`
+	TimestampMs int64  `json:"timestamp_ms"`
+	Signature   string `json:"signature"`
+}
+
+// makeCosignHandler обрабатывает POST /witness/batches/{id}/cosignatures
+func makeCosignHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batchID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid batch id: %v", err))
+			return
+		}
+		var req cosignatureJSON
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid input: %v", err))
+			return
+		}
+		sig, err := hex.DecodeString(req.Signature)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid signature: %v", err))
+			return
+		}
+
+		err = svc.AddCosignature(r.Context(), batchID, &verify.Cosignature{
+			KeyID:     req.KeyID,
+			Timestamp: time.UnixMilli(req.TimestampMs).UTC(),
+			Signature: sig,
+		})
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type cosignaturesResponse struct {
+	Head         treeHeadResponse  `json:"head"`
+	Cosignatures []cosignatureJSON `json:"cosignatures"`
+}
+
+// makeCosignaturesHandler обрабатывает GET /batches/{id}/cosignatures: head батча
+// и подписи свидетелей под ним
+func makeCosignaturesHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batchID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid batch id: %v", err))
+			return
+		}
+
+		head, cosigs, err := svc.GetCosignatures(r.Context(), batchID)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := cosignaturesResponse{Head: newTreeHeadResponse(head), Cosignatures: make([]cosignatureJSON, len(cosigs))}
+		for i, c := range cosigs {
+			resp.Cosignatures[i] = cosignatureJSON{
+				KeyID:       c.KeyID,
+				TimestampMs: c.Timestamp.UnixMilli(),
+				Signature:   fmt.Sprintf("%x", c.Signature),
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type messageResponse struct {
+	MessageID   int64     `json:"message_id"`
+	ChatID      int64     `json:"chat_id"`
+	UserID      int64     `json:"user_id"`
+	Payload     string    `json:"payload"`
+	PayloadHash string    `json:"payload_hash"`
+	CreatedAt   time.Time `json:"created_at"`
+	BatchID     *int64    `json:"batch_id,omitempty"`
+	Status      string    `json:"status"`
+}
+
+func newMessageResponse(m *db.Message) messageResponse {
+	return messageResponse{
+		MessageID:   m.MessageID,
+		ChatID:      m.ChatID,
+		UserID:      m.UserID,
+		Payload:     string(m.Payload),
+		PayloadHash: fmt.Sprintf("%x", m.PayloadHash),
+		CreatedAt:   m.CreatedAt,
+		BatchID:     m.BatchID,
+		Status:      service.MessageStatus(m),
+	}
+}
+
+type messagesResponse struct {
+	ChatID     int64             `json:"chat_id"`
+	Messages   []messageResponse `json:"messages"`
+	NextCursor int64             `json:"next_cursor,omitempty"`
+}
+
+// parseMessageFilter разбирает параметры GET /chats/{id}/messages
+func parseMessageFilter(q url.Values) (service.MessageFilter, error) {
+	var f service.MessageFilter
+	var err error
+	if v := q.Get("user_id"); v != "" {
+		if f.UserID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return f, fmt.Errorf("invalid user_id: %q", v)
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid from: %q, want RFC 3339", v)
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid to: %q, want RFC 3339", v)
+		}
+	}
+	f.Cursor, f.Limit, f.Desc, err = parsePageParams(q)
+	return f, err
+}
+
+// parsePageParams разбирает общие параметры страниц: cursor, limit и order (по умолчанию desc)
+func parsePageParams(q url.Values) (cursor int64, limit int, desc bool, err error) {
+	if v := q.Get("cursor"); v != "" {
+		if cursor, err = strconv.ParseInt(v, 10, 64); err != nil || cursor <= 0 {
+			return 0, 0, false, fmt.Errorf("invalid cursor: %q", v)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			return 0, 0, false, fmt.Errorf("invalid limit: %q", v)
+		}
+	}
+	switch v := q.Get("order"); v {
+	case "", "desc":
+		desc = true
+	case "asc":
+	default:
+		return 0, 0, false, fmt.Errorf("invalid order: %q, want asc or desc", v)
+	}
+	return cursor, limit, desc, nil
+}
+
+// makeChatMessagesHandler обрабатывает GET /chats/{id}/messages
+// ?cursor=&limit=&order=asc|desc&user_id=&from=&to=
+func makeChatMessagesHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid chat id: %v", err))
+			return
+		}
+		filter, err := parseMessageFilter(r.URL.Query())
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		page, err := svc.ListChatMessages(r.Context(), chatID, filter)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := messagesResponse{
+			ChatID:     chatID,
+			Messages:   make([]messageResponse, len(page.Messages)),
+			NextCursor: page.NextCursor,
+		}
+		for i, m := range page.Messages {
+			resp.Messages[i] = newMessageResponse(m)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type messageProofResponse struct {
+	BatchID     int64    `json:"batch_id"`
+	Root        string   `json:"root"`
+	TreeVersion string   `json:"tree_version"`
+	HashAlg     string   `json:"hash_alg"`
+	Index       int      `json:"index"`
+	Siblings    []string `json:"siblings"`
+	Left        []bool   `json:"left"`
+}
+
+type messageLookupResponse struct {
+	messageResponse
+	Proof *messageProofResponse `json:"proof,omitempty"`
+}
+
+// makeGetMessageHandler обрабатывает GET /messages/{id}
+func makeGetMessageHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		messageID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid message id: %v", err))
+			return
+		}
+
+		info, err := svc.GetMessage(r.Context(), messageID)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := messageLookupResponse{messageResponse: newMessageResponse(info.Message)}
+		if p := info.Proof; p != nil {
+			resp.Proof = &messageProofResponse{
+				BatchID:     p.BatchID,
+				Root:        fmt.Sprintf("%x", p.Root),
+				TreeVersion: p.TreeVersion.String(),
+				HashAlg:     p.HashAlg.String(),
+				Index:       p.Proof.Index,
+				Siblings:    make([]string, len(p.Proof.Siblings)),
+				Left:        p.Proof.Left,
+			}
+			for i, h := range p.Proof.Siblings {
+				resp.Proof.Siblings[i] = fmt.Sprintf("%x", h)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type latestRootResponse struct {
+	ChatID   int64  `json:"chat_id"`
+	BatchID  int64  `json:"batch_id"`
+	Root     string `json:"root"`
+	ChatSize *int64 `json:"chat_size,omitempty"`
+	ChatRoot string `json:"chat_root,omitempty"`
+}
+
+// makeLatestRootHandler обрабатывает GET /chats/{id}/root
+func makeLatestRootHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid chat id: %v", err))
+			return
+		}
+
+		latest, err := svc.GetLatestRoot(r.Context(), chatID)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := latestRootResponse{
+			ChatID:   chatID,
+			BatchID:  latest.BatchID,
+			Root:     fmt.Sprintf("%x", latest.Root),
+			ChatSize: latest.ChatSize,
+		}
+		if latest.ChatRoot != nil {
+			resp.ChatRoot = fmt.Sprintf("%x", latest.ChatRoot)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type batchResponse struct {
+	BatchID       int64             `json:"batch_id"`
+	ChatID        int64             `json:"chat_id"`
+	Root          string            `json:"root"`
+	FromMessageID int64             `json:"from_message_id"`
+	ToMessageID   int64             `json:"to_message_id"`
+	TreeVersion   string            `json:"tree_version"`
+	HashAlg       string            `json:"hash_alg"`
+	ChatSize      *int64            `json:"chat_size,omitempty"`
+	ChatRoot      string            `json:"chat_root,omitempty"`
+	PrevBatchHash string            `json:"prev_batch_hash,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Messages      []messageResponse `json:"messages,omitempty"`
+}
+
+func newBatchResponse(b *db.MerkleBatch) batchResponse {
+	resp := batchResponse{
+		BatchID:       b.BatchID,
+		ChatID:        b.ChatID,
+		Root:          fmt.Sprintf("%x", b.RootHash),
+		FromMessageID: b.FromMessageID,
+		ToMessageID:   b.ToMessageID,
+		TreeVersion:   cgobridge.TreeVersion(b.TreeVersion).String(),
+		HashAlg:       cgobridge.HashAlg(b.HashAlg).String(),
+		ChatSize:      b.ChatSize,
+		CreatedAt:     b.CreatedAt,
+	}
+	if b.ChatRoot != nil {
+		resp.ChatRoot = fmt.Sprintf("%x", b.ChatRoot)
+	}
+	if b.PrevBatchHash != nil {
+		resp.PrevBatchHash = fmt.Sprintf("%x", b.PrevBatchHash)
+	}
+	return resp
+}
+
+type batchesResponse struct {
+	ChatID     int64           `json:"chat_id"`
+	Batches    []batchResponse `json:"batches"`
+	NextCursor int64           `json:"next_cursor,omitempty"`
+}
+
+// makeChatBatchesHandler обрабатывает GET /chats/{id}/batches?cursor=&limit=&order=asc|desc
+func makeChatBatchesHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid chat id: %v", err))
+			return
+		}
+		var filter service.BatchFilter
+		filter.Cursor, filter.Limit, filter.Desc, err = parsePageParams(r.URL.Query())
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		page, err := svc.ListChatBatches(r.Context(), chatID, filter)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := batchesResponse{
+			ChatID:     chatID,
+			Batches:    make([]batchResponse, len(page.Batches)),
+			NextCursor: page.NextCursor,
+		}
+		for i, b := range page.Batches {
+			resp.Batches[i] = newBatchResponse(b)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// makeGetBatchHandler обрабатывает GET /batches/{id}
+func makeGetBatchHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batchID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid batch id: %v", err))
+			return
+		}
+
+		info, err := svc.GetBatch(r.Context(), batchID)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := newBatchResponse(info.Batch)
+		resp.Messages = make([]messageResponse, len(info.Messages))
+		for i, m := range info.Messages {
+			resp.Messages[i] = newMessageResponse(m)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// serviceErrorStatus HTTP статус для ошибки сервиса
+func serviceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidArgument):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrUnknownWitness):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrIdempotencyMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrIdempotencyInFlight):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+type multiProofRequest struct {
+	MessageIDs []int64 `json:"message_ids"`
+}
+
+type multiProofResponse struct {
+	BatchID     int64    `json:"batch_id"`
+	Root        string   `json:"root"`
+	TreeVersion string   `json:"tree_version"`
+	HashAlg     string   `json:"hash_alg"`
+	LeafCount   int      `json:"leaf_count"`
+	MessageIDs  []int64  `json:"message_ids"`
+	Indices     []int    `json:"indices"`
+	Nodes       []string `json:"nodes"`
+}
+
+// makeMultiProofHandler обрабатывает POST /batches/{id}/multiproof
+func makeMultiProofHandler(svc *service.MessageService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batchID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid batch id: %v", err))
+			return
+		}
+		var req multiProofRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeProblem(w, http.StatusBadRequest, fmt.Sprintf("invalid input: %v", err))
+			return
+		}
+
+		mp, err := svc.GetBatchMultiProof(r.Context(), batchID, req.MessageIDs)
+		if err != nil {
+			writeProblem(w, serviceErrorStatus(err), fmt.Sprintf("failed: %v", err))
+			return
+		}
+
+		resp := multiProofResponse{
+			BatchID:     mp.BatchID,
+			Root:        fmt.Sprintf("%x", mp.Root),
+			TreeVersion: mp.TreeVersion.String(),
+			HashAlg:     mp.HashAlg.String(),
+			LeafCount:   mp.Proof.N,
+			MessageIDs:  mp.MessageIDs,
+			Indices:     mp.Proof.Indices,
+			Nodes:       make([]string, len(mp.Proof.Nodes)),
+		}
+		for i, node := range mp.Proof.Nodes {
+			resp.Nodes[i] = fmt.Sprintf("%x", node)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
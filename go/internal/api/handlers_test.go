@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"veriChat/go/internal/service"
+)
+
+// TestPostMessageIdempotencyKey - ключ в заголовке и в теле должен совпадать;
+// запросы отклоняются до вызова сервиса, поэтому svc не нужен
+func TestPostMessageIdempotencyKey(t *testing.T) {
+	handler := makePostMessageHandler(nil)
+	cases := []struct {
+		name   string
+		header string
+		body   string
+		detail string
+	}{
+		{"header and body differ", "a", `{"chat_id":1,"user_id":2,"payload":"hi","idempotency_key":"b"}`, "differ"},
+		{"header too long", strings.Repeat("k", maxIdempotencyKeyLen+1), `{"chat_id":1,"user_id":2,"payload":"hi"}`, "longer than"},
+		{"body too long", "", `{"chat_id":1,"user_id":2,"payload":"hi","idempotency_key":"` + strings.Repeat("k", maxIdempotencyKeyLen+1) + `"}`, "longer than"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(tc.body))
+			if tc.header != "" {
+				req.Header.Set("Idempotency-Key", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), tc.detail)
+		})
+	}
+}
+
+func TestParsePageParams(t *testing.T) {
+	cases := []struct {
+		query  string
+		cursor int64
+		limit  int
+		desc   bool
+		err    string
+	}{
+		{"", 0, 0, true, ""},
+		{"order=desc", 0, 0, true, ""},
+		{"order=asc", 0, 0, false, ""},
+		// курсор работает в обоих порядках
+		{"cursor=42&order=asc", 42, 0, false, ""},
+		{"cursor=42&order=desc&limit=10", 42, 10, true, ""},
+		{"limit=1", 0, 1, true, ""},
+		// верхний предел limit проверяет сервис
+		{"limit=100000", 0, 100000, true, ""},
+		{"limit=0", 0, 0, false, "invalid limit"},
+		{"limit=-1", 0, 0, false, "invalid limit"},
+		{"limit=ten", 0, 0, false, "invalid limit"},
+		{"cursor=0", 0, 0, false, "invalid cursor"},
+		{"cursor=-5", 0, 0, false, "invalid cursor"},
+		{"cursor=abc", 0, 0, false, "invalid cursor"},
+		{"order=newest", 0, 0, false, "invalid order"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			cursor, limit, desc, err := parsePageParams(q)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.cursor, cursor)
+			assert.Equal(t, tc.limit, limit)
+			assert.Equal(t, tc.desc, desc)
+		})
+	}
+}
+
+func TestParseMessageFilter(t *testing.T) {
+	from := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	to := time.Date(2024, 1, 3, 0, 0, 0, 0, time.FixedZone("", 3*3600))
+	cases := []struct {
+		query string
+		want  service.MessageFilter
+		err   string
+	}{
+		{"", service.MessageFilter{Desc: true}, ""},
+		{"user_id=7&cursor=3&order=asc&limit=20", service.MessageFilter{UserID: 7, Cursor: 3, Limit: 20}, ""},
+		{"from=2024-01-02T03:04:05Z&to=2024-01-03T00:00:00%2B03:00", service.MessageFilter{From: from, To: to, Desc: true}, ""},
+		{"to=2024-01-03T00:00:00%2B03:00&cursor=9", service.MessageFilter{To: to, Cursor: 9, Desc: true}, ""},
+		{"user_id=x", service.MessageFilter{}, "invalid user_id"},
+		{"from=2024-01-02", service.MessageFilter{}, "invalid from"},
+		{"from=yesterday", service.MessageFilter{}, "invalid from"},
+		{"to=1700000000", service.MessageFilter{}, "invalid to"},
+		{"from=2024-01-02T03:04:05Z&limit=0", service.MessageFilter{}, "invalid limit"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := url.ParseQuery(tc.query)
+			require.NoError(t, err)
+			f, err := parseMessageFilter(q)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tc.want.From.Equal(f.From), "from %s", f.From)
+			assert.True(t, tc.want.To.Equal(f.To), "to %s", f.To)
+			f.From, f.To = tc.want.From, tc.want.To
+			assert.Equal(t, tc.want, f)
+		})
+	}
+}
@@ -0,0 +1,394 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPIJSON - OpenAPI 3 документ всех маршрутов NewServer (GET /openapi.json).
+// По нему же проверяются параметры и JSON тело запросов.
+//
+//go:embed openapi.json
+var openAPIJSON []byte
+
+// openAPI - разобранный openAPIJSON
+var openAPI = mustLoadOpenAPI()
+
+func mustLoadOpenAPI() *openAPIDoc {
+	doc, err := loadOpenAPI(openAPIJSON)
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// maxRequestBody предел тела запроса, которое читается для проверки
+const maxRequestBody = 8 << 20
+
+// openAPIDoc - часть OpenAPI 3, которая нужна для проверки запросов
+type openAPIDoc struct {
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas    map[string]*jsonSchema       `json:"schemas"`
+		Parameters map[string]*openAPIParameter `json:"parameters"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	OperationID string              `json:"operationId"`
+	Parameters  []*openAPIParameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *jsonSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type openAPIParameter struct {
+	Ref      string      `json:"$ref"`
+	Name     string      `json:"name"`
+	In       string      `json:"in"` // path, query или header
+	Required bool        `json:"required"`
+	Schema   *jsonSchema `json:"schema"`
+}
+
+// jsonSchema - поддерживаемое подмножество JSON Schema из OpenAPI 3.0
+type jsonSchema struct {
+	Ref        string                 `json:"$ref"`
+	Type       string                 `json:"type"`
+	Format     string                 `json:"format"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	Enum       []any                  `json:"enum"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+	MinLength  *int                   `json:"minLength"`
+	MaxLength  *int                   `json:"maxLength"`
+	MinItems   *int                   `json:"minItems"`
+	Pattern    string                 `json:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+// loadOpenAPI разбирает документ и разрешает $ref параметров и схем
+func loadOpenAPI(data []byte) (*openAPIDoc, error) {
+	var doc openAPIDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			for i, p := range op.Parameters {
+				if p.Ref == "" {
+					continue
+				}
+				name := strings.TrimPrefix(p.Ref, "#/components/parameters/")
+				if op.Parameters[i] = doc.Components.Parameters[name]; op.Parameters[i] == nil {
+					return nil, fmt.Errorf("openapi: %s %s: unknown parameter %s", method, path, p.Ref)
+				}
+			}
+			for _, p := range op.Parameters {
+				if err := doc.resolve(p.Schema); err != nil {
+					return nil, fmt.Errorf("openapi: %s %s parameter %s: %w", method, path, p.Name, err)
+				}
+			}
+			if s := op.requestSchema(); s != nil {
+				if err := doc.resolve(s); err != nil {
+					return nil, fmt.Errorf("openapi: %s %s body: %w", method, path, err)
+				}
+			}
+		}
+	}
+	return &doc, nil
+}
+
+// resolve компилирует pattern в схеме s и во всех схемах, на которые она ссылается
+func (doc *openAPIDoc) resolve(s *jsonSchema) error {
+	if s == nil || s.pattern != nil {
+		return nil
+	}
+	if s.Ref != "" {
+		target := doc.schema(s)
+		if target == nil {
+			return fmt.Errorf("unknown schema %s", s.Ref)
+		}
+		return doc.resolve(target)
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = re
+	}
+	for _, p := range s.Properties {
+		if err := doc.resolve(p); err != nil {
+			return err
+		}
+	}
+	return doc.resolve(s.Items)
+}
+
+// schema схема s с учетом $ref
+func (doc *openAPIDoc) schema(s *jsonSchema) *jsonSchema {
+	if s == nil || s.Ref == "" {
+		return s
+	}
+	return doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+}
+
+func (op *openAPIOperation) requestSchema() *jsonSchema {
+	if op.RequestBody == nil {
+		return nil
+	}
+	return op.RequestBody.Content["application/json"].Schema
+}
+
+// operation операция для шаблона маршрута ServeMux, например "GET /chats/{id}/root"
+func (doc *openAPIDoc) operation(pattern string) *openAPIOperation {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return nil
+	}
+	return doc.Paths[path][strings.ToLower(method)]
+}
+
+// problemDetails - ошибка в формате RFC 9457 (application/problem+json)
+type problemDetails struct {
+	Type   string         `json:"type"`
+	Title  string         `json:"title"`
+	Status int            `json:"status"`
+	Detail string         `json:"detail,omitempty"`
+	Errors []problemError `json:"errors,omitempty"`
+}
+
+// problemError - нарушение схемы: JSON pointer поля тела или имя параметра
+type problemError struct {
+	Pointer string `json:"pointer"`
+	Detail  string `json:"detail"`
+}
+
+func writeProblemDetails(w http.ResponseWriter, p problemDetails) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// writeProblem отвечает ошибкой application/problem+json
+func writeProblem(w http.ResponseWriter, status int, detail string) {
+	writeProblemDetails(w, problemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+}
+
+// validateRequest проверяет параметры и JSON тело запроса по операции op.
+// Нарушения возвращаются одним ответом 400 со списком errors.
+func (doc *openAPIDoc) validateRequest(op *openAPIOperation, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var errs []problemError
+		for _, p := range op.Parameters {
+			var v string
+			switch p.In {
+			case "path":
+				v = r.PathValue(p.Name)
+			case "query":
+				v = r.URL.Query().Get(p.Name)
+			case "header":
+				v = r.Header.Get(p.Name)
+			}
+			if v == "" {
+				if p.Required {
+					errs = append(errs, problemError{Pointer: p.Name, Detail: "is required"})
+				}
+				continue
+			}
+			if msg := doc.checkParam(p.Schema, v); msg != "" {
+				errs = append(errs, problemError{Pointer: p.Name, Detail: msg})
+			}
+		}
+
+		if schema := op.requestSchema(); schema != nil {
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+			if err != nil {
+				status := http.StatusBadRequest
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				writeProblem(w, status, fmt.Sprintf("read body: %v", err))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(data))
+
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.UseNumber()
+			var body any
+			if err := dec.Decode(&body); err != nil {
+				errs = append(errs, problemError{Pointer: "", Detail: fmt.Sprintf("invalid JSON: %v", err)})
+			} else {
+				errs = append(errs, doc.checkValue(schema, body, "")...)
+			}
+		}
+
+		if len(errs) > 0 {
+			writeProblemDetails(w, problemDetails{
+				Type:   "about:blank",
+				Title:  http.StatusText(http.StatusBadRequest),
+				Status: http.StatusBadRequest,
+				Detail: "request does not match the API schema",
+				Errors: errs,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkParam проверяет строковое значение параметра, "" - значение подходит
+func (doc *openAPIDoc) checkParam(s *jsonSchema, v string) string {
+	s = doc.schema(s)
+	if s == nil {
+		return ""
+	}
+	var val any = v
+	switch s.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return "must be an integer"
+		}
+		val = json.Number(v)
+	case "boolean":
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return "must be a boolean"
+		}
+		val = b
+	}
+	if errs := doc.checkValue(s, val, ""); len(errs) > 0 {
+		return errs[0].Detail
+	}
+	return ""
+}
+
+// checkValue проверяет значение JSON (числа - json.Number) по схеме s
+func (doc *openAPIDoc) checkValue(s *jsonSchema, v any, pointer string) []problemError {
+	s = doc.schema(s)
+	if s == nil {
+		return nil
+	}
+	fail := func(format string, args ...any) []problemError {
+		return []problemError{{Pointer: pointer, Detail: fmt.Sprintf(format, args...)}}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		var errs []problemError
+		for _, name := range s.Required {
+			if val, ok := obj[name]; !ok || val == nil {
+				errs = append(errs, problemError{Pointer: pointer + "/" + name, Detail: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			// null у необязательного поля - то же, что его отсутствие
+			if p, ok := s.Properties[name]; ok && obj[name] != nil {
+				errs = append(errs, doc.checkValue(p, obj[name], pointer+"/"+name)...)
+			}
+		}
+		return errs
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fail("must be an array")
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			return fail("must have at least %d items", *s.MinItems)
+		}
+		var errs []problemError
+		for i, item := range arr {
+			errs = append(errs, doc.checkValue(s.Items, item, pointer+"/"+strconv.Itoa(i))...)
+		}
+		return errs
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		if s.MinLength != nil && len(str) < *s.MinLength {
+			return fail("must be at least %d bytes", *s.MinLength)
+		}
+		if s.MaxLength != nil && len(str) > *s.MaxLength {
+			return fail("must be at most %d bytes", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			return fail("must match %s", s.Pattern)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fail("must be an RFC 3339 date-time")
+			}
+		}
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fail("must be a %s", s.Type)
+		}
+		f, err := n.Float64()
+		if s.Type == "integer" {
+			_, err = n.Int64()
+		}
+		if err != nil {
+			return fail("must be a %s", s.Type)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fail("must be <= %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				return nil
+			}
+		}
+		return fail("must be one of %v", s.Enum)
+	}
+	return nil
+}
+
+// serveOpenAPI обрабатывает GET /openapi.json
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIJSON)
+}
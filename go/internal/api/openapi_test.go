@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRoutesMatchOpenAPI - у каждого маршрута NewServer есть операция в openapi.json и наоборот
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	var registered []string
+	for _, rt := range routes(nil) {
+		registered = append(registered, rt.pattern)
+	}
+	var documented []string
+	for path, ops := range openAPI.Paths {
+		for method := range ops {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(registered)
+	sort.Strings(documented)
+	assert.Equal(t, registered, documented)
+
+	for _, rt := range routes(nil) {
+		op := openAPI.operation(rt.pattern)
+		require.NotNil(t, op, rt.pattern)
+		if strings.Contains(rt.pattern, "{id}") {
+			var hasID bool
+			for _, p := range op.Parameters {
+				hasID = hasID || (p.In == "path" && p.Name == "id" && p.Required)
+			}
+			assert.True(t, hasID, "%s: path parameter id is not documented", rt.pattern)
+		}
+	}
+}
+
+// schemaTypes - Go типы, которые пишут или читают JSON по схемам components.schemas
+var schemaTypes = map[string]any{
+	"Problem":             problemDetails{},
+	"ProblemError":        problemError{},
+	"PostMessageRequest":  postMessageRequest{},
+	"PostMessageResponse": postMessageResponse{},
+	"Message":             messageResponse{},
+	"MessageProof":        messageProofResponse{},
+	"MessageLookup":       messageLookupResponse{},
+	"MessagePage":         messagesResponse{},
+	"LatestRoot":          latestRootResponse{},
+	"Batch":               batchResponse{},
+	"BatchPage":           batchesResponse{},
+	"InclusionProof":      inclusionProofJSON{},
+	"VerifyRequest":       verifyProofRequest{},
+	"VerifyResponse":      verifyProofResponse{},
+	"ConsistencyProof":    consistencyResponse{},
+	"ChainStatus":         chainResponse{},
+	"TreeHead":            treeHeadResponse{},
+	"SigningKey":          signingKeyResponse{},
+	"SigningKeys":         keysResponse{},
+	"Timestamp":           timestampResponse{},
+	"Anchor":              anchorResponse{},
+	"BatchAnchors":        anchorsResponse{},
+	"WitnessHead":         witnessHeadResponse{},
+	"Cosignature":         cosignatureJSON{},
+	"Cosignatures":        cosignaturesResponse{},
+	"MultiProofRequest":   multiProofRequest{},
+	"MultiProofResponse":  multiProofResponse{},
+}
+
+type jsonField struct {
+	typ       reflect.Type
+	omitempty bool
+}
+
+// jsonFields поля JSON структуры t, включая встроенные структуры
+func jsonFields(t reflect.Type) map[string]jsonField {
+	fields := map[string]jsonField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" {
+			for name, ef := range jsonFields(f.Type) {
+				fields[name] = ef
+			}
+			continue
+		}
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = jsonField{typ: f.Type, omitempty: strings.Contains(opts, "omitempty")}
+	}
+	return fields
+}
+
+// jsonType тип JSON Schema, которым кодируется Go тип
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(time.Time{}), t.Kind() == reflect.String,
+		t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return "string"
+	case t.Kind() == reflect.Bool:
+		return "boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return "integer"
+	case t.Kind() == reflect.Slice:
+		return "array"
+	case t.Kind() == reflect.Struct:
+		return "object"
+	}
+	return t.Kind().String()
+}
+
+// TestSchemasMatchHandlerTypes - схемы openapi.json совпадают с полями структур обработчиков
+func TestSchemasMatchHandlerTypes(t *testing.T) {
+	for name := range openAPI.Components.Schemas {
+		assert.Contains(t, schemaTypes, name, "schema %s has no Go type in schemaTypes", name)
+	}
+	for name, v := range schemaTypes {
+		schema := openAPI.Components.Schemas[name]
+		if !assert.NotNil(t, schema, "schema %s is missing in openapi.json", name) {
+			continue
+		}
+		fields := jsonFields(reflect.TypeOf(v))
+
+		var goNames, specNames []string
+		for f := range fields {
+			goNames = append(goNames, f)
+		}
+		for p := range schema.Properties {
+			specNames = append(specNames, p)
+		}
+		sort.Strings(goNames)
+		sort.Strings(specNames)
+		if !assert.Equal(t, goNames, specNames, "schema %s properties", name) {
+			continue
+		}
+
+		for p, ps := range schema.Properties {
+			ps = openAPI.schema(ps)
+			require.NotNil(t, ps, "schema %s.%s: unresolved $ref", name, p)
+			assert.Equal(t, ps.Type, jsonType(fields[p].typ), "schema %s.%s type", name, p)
+		}
+		for _, req := range schema.Required {
+			assert.False(t, fields[req].omitempty, "schema %s.%s is required but omitempty in Go", name, req)
+		}
+	}
+}
+
+// serveStubbed выполняет запрос через маршруты routes с проверкой по openapi.json и
+// заглушками вместо обработчиков. Возвращает ответ и дошел ли запрос до обработчика.
+func serveStubbed(t *testing.T, method, target, body string) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+	var reached bool
+	mux := http.NewServeMux()
+	for _, rt := range routes(nil) {
+		op := openAPI.operation(rt.pattern)
+		require.NotNil(t, op, rt.pattern)
+		mux.Handle(rt.pattern, openAPI.validateRequest(op, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reached = true
+			// тело должно остаться доступным обработчику после проверки
+			data, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(data))
+			w.WriteHeader(http.StatusNoContent)
+		})))
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec, reached
+}
+
+func TestValidateRequest(t *testing.T) {
+	cases := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		pointers []string // nil - запрос должен дойти до обработчика
+	}{
+		{name: "valid message", method: "POST", target: "/messages", body: `{"chat_id": 1, "user_id": 2, "payload": "hi"}`},
+		{name: "null optional field", method: "POST", target: "/messages", body: `{"chat_id": 1, "user_id": 2, "payload": "hi", "idempotency_key": null}`},
+		{name: "message types", method: "POST", target: "/messages", body: `{"chat_id": "1", "user_id": 0, "payload": 5}`,
+			pointers: []string{"/chat_id", "/payload", "/user_id"}},
+		{name: "message required", method: "POST", target: "/messages", body: `{}`,
+			pointers: []string{"/chat_id", "/user_id", "/payload"}},
+		{name: "message not json", method: "POST", target: "/messages", body: `chat_id=1`, pointers: []string{""}},
+		{name: "path id", method: "GET", target: "/messages/abc", pointers: []string{"id"}},
+		{name: "history params", method: "GET", target: "/chats/1/messages?limit=500&order=up&from=yesterday",
+			pointers: []string{"limit", "order", "from"}},
+		{name: "valid history", method: "GET", target: "/chats/1/messages?limit=10&order=asc&from=2024-01-02T03:04:05Z"},
+		{name: "consistency required", method: "GET", target: "/chats/1/consistency", pointers: []string{"from", "to"}},
+		{name: "verify proof", method: "POST", target: "/verify",
+			body:     `{"payload": "hi", "proof": {"siblings": ["zz"], "left": [true, "no"]}, "root": "00"}`,
+			pointers: []string{"/proof/left/1", "/proof/siblings/0", "/root"}},
+		{name: "multiproof empty", method: "POST", target: "/batches/1/multiproof", body: `{"message_ids": []}`,
+			pointers: []string{"/message_ids"}},
+		{name: "cosignature", method: "POST", target: "/witness/batches/1/cosignatures",
+			body: `{"key_id": "k", "timestamp_ms": 1, "signature": "` + strings.Repeat("ab", 64) + `"}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec, reached := serveStubbed(t, tc.method, tc.target, tc.body)
+			if tc.pointers == nil {
+				assert.True(t, reached, rec.Body.String())
+				return
+			}
+			assert.False(t, reached)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+
+			var p problemDetails
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+			assert.Equal(t, http.StatusBadRequest, p.Status)
+			var pointers []string
+			for _, e := range p.Errors {
+				pointers = append(pointers, e.Pointer)
+			}
+			assert.ElementsMatch(t, tc.pointers, pointers)
+		})
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	serveOpenAPI(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var doc struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.True(t, strings.HasPrefix(doc.OpenAPI, "3."))
+	assert.Contains(t, doc.Paths, "/messages")
+}
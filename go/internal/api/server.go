@@ -14,13 +14,56 @@ type Server struct {
 	service    *service.MessageService
 }
 
-func NewServer(addr string, svc *service.MessageService) *Server {
+// route - маршрут API: шаблон ServeMux и обработчик
+type route struct {
+	pattern    string
+	handler    http.Handler
+	instrument bool // считать запросы в метриках HTTP
+}
+
+// routes все маршруты API. Для каждого есть операция в openapi.json (проверяет TestRoutesMatchOpenAPI).
+func routes(svc *service.MessageService) []route {
+	return []route{
+		{"GET /metrics", metrics.MetricsHandler(), false},
+		{"GET /openapi.json", http.HandlerFunc(serveOpenAPI), true},
+		{"POST /messages", makePostMessageHandler(svc), true},
+		{"POST /verify", makeVerifyHandler(svc), true},
+		{"GET /messages/{id}", makeGetMessageHandler(svc), true},
+		{"GET /chats/{id}/messages", makeChatMessagesHandler(svc), true},
+		{"GET /chats/{id}/root", makeLatestRootHandler(svc), true},
+		{"GET /chats/{id}/batches", makeChatBatchesHandler(svc), true},
+		{"GET /batches/{id}", makeGetBatchHandler(svc), true},
+		{"GET /chats/{id}/consistency", makeConsistencyHandler(svc), true},
+		{"GET /chats/{id}/head", makeTreeHeadHandler(svc), true},
+		{"GET /keys", makeKeysHandler(svc), true},
+		{"GET /chats/{id}/chain", makeChainHandler(svc), true},
+		{"POST /batches/{id}/multiproof", makeMultiProofHandler(svc), true},
+		{"GET /batches/{id}/timestamp", makeTimestampHandler(svc), true},
+		{"GET /batches/{id}/anchors", makeAnchorsHandler(svc), true},
+		{"GET /batches/{id}/cosignatures", makeCosignaturesHandler(svc), true},
+		{"GET /witness/chats/{id}/head", makeWitnessHeadHandler(svc), true},
+		{"POST /witness/batches/{id}/cosignatures", makeCosignHandler(svc), true},
+	}
+}
+
+// newMux регистрирует routes: запрос сначала проверяется по openapi.json
+func newMux(svc *service.MessageService) *http.ServeMux {
 	mux := http.NewServeMux()
+	for _, rt := range routes(svc) {
+		h := rt.handler
+		if op := openAPI.operation(rt.pattern); op != nil {
+			h = openAPI.validateRequest(op, h)
+		}
+		if rt.instrument {
+			h = metrics.InstrumentHandler(h)
+		}
+		mux.Handle(rt.pattern, h)
+	}
+	return mux
+}
 
-	// Handlers
-	mux.Handle("/metrics", metrics.MetricsHandler())
-	mux.Handle("/messages", metrics.InstrumentHandler(makePostMessageHandler(svc)))
-	mux.Handle("/merkle", metrics.InstrumentHandler(http.HandlerFunc(PostMerkleHandler)))
+func NewServer(addr string, svc *service.MessageService) *Server {
+	mux := newMux(svc)
 	srv := &http.Server{
 		Addr:    addr,
 		Handler: mux,
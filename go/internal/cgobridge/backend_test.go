@@ -0,0 +1,181 @@
+//go:build cgo && !purego
+
+package cgobridge
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Оба backend должны давать одинаковый результат байт в байт
+
+var backendOptions = []Options{
+	{},
+	{TreeVersion: TreeLegacy},
+	{TreeVersion: TreeRFC6962},
+	{TreeVersion: TreeLegacy, Hash: HashSHA512_256},
+	{TreeVersion: TreeRFC6962, Hash: HashSHA512_256},
+	{TreeVersion: TreeLegacy, Hash: HashSHA3_256},
+	{TreeVersion: TreeRFC6962, Hash: HashSHA3_256},
+}
+
+func TestBackendsMerkleRootIdentical(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for _, opts := range backendOptions {
+		for n := 1; n <= 200; n++ {
+			messages := make([][]byte, n)
+			for i := range messages {
+				messages[i] = make([]byte, rng.Intn(100))
+				rng.Read(messages[i])
+			}
+
+			engineRoot, err := MerkleRootWithOptions(messages, opts)
+			require.NoError(t, err)
+			goRoot, err := goMerkleRoot(messages, opts)
+			require.NoError(t, err)
+
+			assert.Equal(t, engineRoot, goRoot, "opts=%+v n=%d", opts, n)
+		}
+	}
+}
+
+func TestBackendsMerkleProofIdentical(t *testing.T) {
+	messages := [][]byte{{}, []byte("a"), []byte("bb"), {0x00}, []byte("cccc"), []byte("d"), []byte("e")}
+	for _, opts := range backendOptions {
+		for i := range messages {
+			engineProof, err := MerkleProofWithOptions(messages, i, opts)
+			require.NoError(t, err)
+			goProof, err := goMerkleProof(messages, i, opts)
+			require.NoError(t, err)
+
+			assert.Equal(t, engineProof, goProof, "opts=%+v index=%d", opts, i)
+		}
+	}
+}
+
+func TestBackendsSameErrors(t *testing.T) {
+	_, engineErr := MerkleRoot(nil)
+	_, goErr := goMerkleRoot(nil, Options{})
+	require.Error(t, engineErr)
+	require.Error(t, goErr)
+	assert.Equal(t, engineErr.Error(), goErr.Error())
+
+	for _, opts := range []Options{{TreeVersion: 42}, {Hash: 42}} {
+		_, engineErr = MerkleRootWithOptions([][]byte{[]byte("a")}, opts)
+		_, goErr = goMerkleRoot([][]byte{[]byte("a")}, opts)
+		assert.Error(t, engineErr, "opts=%+v", opts)
+		assert.Error(t, goErr, "opts=%+v", opts)
+	}
+}
+
+func BenchmarkGoMerkleRoot(b *testing.B) {
+	messages := [][]byte{
+		[]byte("message 1"),
+		[]byte("message 2"),
+		[]byte("message 3"),
+		[]byte("message 4"),
+		[]byte("message 5"),
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := goMerkleRoot(messages, Options{}); err != nil {
+			b.Fatalf("goMerkleRoot failed: %v", err)
+		}
+	}
+}
+
+func TestBufferPathMatchesPerMessage(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	// размеры идут вразнобой, чтобы буферы из пула и росли, и переиспользовались
+	for _, n := range []int{1, 5000, 3, 70000, 2, 1025, 1} {
+		messages := make([][]byte, n)
+		for i := range messages {
+			messages[i] = make([]byte, rng.Intn(40))
+			rng.Read(messages[i])
+		}
+		for _, opts := range backendOptions {
+			want, err := merkleRootPerMessage(messages, opts)
+			require.NoError(t, err)
+			got, err := MerkleRootWithOptions(messages, opts)
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "n=%d opts=%+v", n, opts)
+		}
+	}
+}
+
+func TestBufferPathAllEmptyMessages(t *testing.T) {
+	messages := [][]byte{{}, {}, {}, nil}
+	want, err := merkleRootPerMessage(messages, Options{})
+	require.NoError(t, err)
+	got, err := MerkleRoot(messages)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+// BenchmarkFlushSmallMessages сравнивает прежний путь (C.CBytes на каждое сообщение)
+// с одним буфером из пула на батчах коротких сообщений чата.
+// go test -bench FlushSmallMessages ./go/internal/cgobridge
+func BenchmarkFlushSmallMessages(b *testing.B) {
+	for _, n := range []int{64, 1024, 16384} {
+		messages := make([][]byte, n)
+		for i := range messages {
+			messages[i] = []byte(fmt.Sprintf("msg %d: ok", i))
+		}
+		opts := Options{TreeVersion: TreeRFC6962}
+
+		b.Run(fmt.Sprintf("per-message/n=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := merkleRootPerMessage(messages, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("buffer/n=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := MerkleRootWithOptions(messages, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFlushManyQuietChats - flush тысяч тихих чатов: вызов engine на каждый чат
+// против одного MerkleRoots на всех.
+// go test -bench FlushManyQuietChats ./go/internal/cgobridge
+func BenchmarkFlushManyQuietChats(b *testing.B) {
+	sets := make([]RootSet, 4096)
+	for i := range sets {
+		messages := make([][]byte, 1+i%5)
+		for j := range messages {
+			messages[j] = []byte(fmt.Sprintf("chat %d msg %d", i, j))
+		}
+		sets[i] = RootSet{Messages: messages, Options: Options{TreeVersion: TreeRFC6962}}
+	}
+
+	b.Run("per-chat", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, set := range sets {
+				if _, err := MerkleRootWithOptions(set.Messages, set.Options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, r := range MerkleRoots(sets, 1) {
+				if r.Err != nil {
+					b.Fatal(r.Err)
+				}
+			}
+		}
+	})
+}
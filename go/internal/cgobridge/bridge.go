@@ -0,0 +1,123 @@
+// Package cgobridge строит Merkle root и proof для батчей сообщений.
+//
+// По умолчанию вызывается C++ engine из clib через cgo. Без cgo
+// (CGO_ENABLED=0) или с тегом purego используется реализация на чистом Go
+// из pkg/verify с теми же правилами дерева.
+package cgobridge
+
+import "veriChat/go/pkg/verify"
+
+// TreeVersion версия правил дерева (см. ENGINE_TREE_* в engine.h)
+type TreeVersion = verify.TreeVersion
+
+const (
+	TreeLegacy  = verify.TreeLegacy
+	TreeRFC6962 = verify.TreeRFC6962
+)
+
+// HashAlg алгоритм хеширования (см. ENGINE_HASH_* в engine.h)
+type HashAlg = verify.HashAlg
+
+const (
+	HashSHA256     = verify.HashSHA256
+	HashSHA512_256 = verify.HashSHA512_256
+	HashSHA3_256   = verify.HashSHA3_256
+)
+
+// Options параметры построения дерева. Нулевое значение - TreeLegacy с SHA-256 в один поток.
+type Options struct {
+	TreeVersion TreeVersion
+	Hash        HashAlg
+	// Workers число потоков engine для хеширования листьев и уровней.
+	// 0 и 1 - все считается в вызывающем потоке. Go backend это поле игнорирует.
+	Workers int
+}
+
+func (o Options) params() verify.Params {
+	return verify.Params{Version: o.TreeVersion, Hash: o.Hash}
+}
+
+// Proof - путь от листа до корня Merkle tree
+type Proof struct {
+	Index    int      // позиция сообщения в батче
+	Siblings [][]byte // хеши соседей снизу вверх
+	Left     []bool   // true если сосед стоит слева от узла на пути
+}
+
+// Tree - все уровни дерева батча (Root, Proof(i), Level(k)), см. verify.Tree.
+// Дерево можно сохранить (MarshalBinary) и потом отдавать proof без пересчета хешей.
+type Tree = verify.Tree
+
+// MultiProof - один proof для нескольких сообщений батча, см. verify.MultiProof
+type MultiProof = verify.MultiProof
+
+// RootSet - независимый набор сообщений для MerkleRoots (например, батч одного чата)
+type RootSet struct {
+	Messages [][]byte
+	Options  Options // поле Workers не используется, потоки задаются на весь вызов
+}
+
+// RootResult - root набора или ошибка, если его посчитать не удалось
+type RootResult struct {
+	Root []byte
+	Err  error
+}
+
+// TreeResult - дерево набора для MerkleTrees или ошибка, если его построить не удалось
+type TreeResult struct {
+	Tree *Tree
+	Err  error
+}
+
+// MerkleRoot возвращает root по правилам TreeLegacy с SHA-256
+func MerkleRoot(messages [][]byte) ([]byte, error) {
+	return MerkleRootWithOptions(messages, Options{})
+}
+
+// MerkleProof возвращает путь включения messages[index] по правилам TreeLegacy с SHA-256
+func MerkleProof(messages [][]byte, index int) (*Proof, error) {
+	return MerkleProofWithOptions(messages, index, Options{})
+}
+
+// goMerkleRoot - Go backend для MerkleRootWithOptions
+func goMerkleRoot(messages [][]byte, opts Options) ([]byte, error) {
+	return opts.params().Root(messages)
+}
+
+// goMerkleProof - Go backend для MerkleProofWithOptions
+func goMerkleProof(messages [][]byte, index int, opts Options) (*Proof, error) {
+	p, err := opts.params().BuildProof(messages, index)
+	if err != nil {
+		return nil, err
+	}
+	proof := Proof(*p)
+	return &proof, nil
+}
+
+// goMerkleRoots - Go backend для MerkleRoots
+func goMerkleRoots(sets []RootSet) []RootResult {
+	results := make([]RootResult, len(sets))
+	for i, set := range sets {
+		results[i].Root, results[i].Err = goMerkleRoot(set.Messages, set.Options)
+	}
+	return results
+}
+
+// goMerkleTrees - Go backend для MerkleTrees
+func goMerkleTrees(sets []RootSet) []TreeResult {
+	results := make([]TreeResult, len(sets))
+	for i, set := range sets {
+		results[i].Tree, results[i].Err = goMerkleTree(set.Messages, set.Options)
+	}
+	return results
+}
+
+// goMerkleTree - Go backend для MerkleTree
+func goMerkleTree(messages [][]byte, opts Options) (*Tree, error) {
+	return opts.params().Tree(messages)
+}
+
+// goMerkleMultiProof - Go backend для MerkleMultiProof
+func goMerkleMultiProof(messages [][]byte, indices []int, opts Options) (*MultiProof, error) {
+	return opts.params().BuildMultiProof(messages, indices)
+}
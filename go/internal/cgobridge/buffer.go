@@ -0,0 +1,92 @@
+//go:build cgo && !purego
+
+package cgobridge
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+const (
+	minBufferData    = 64 << 10 // байт
+	minBufferOffsets = 1024     // элементов
+)
+
+// cBuffer - C память под батч: все сообщения подряд в data и n+1 offsets.
+// Буферы переиспользуются между вызовами через bufferPool, поэтому на батч
+// приходится одно копирование и ноль malloc в установившемся режиме.
+type cBuffer struct {
+	data    *C.uchar
+	dataCap int
+	offsets *C.size_t
+	offCap  int
+}
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		b := &cBuffer{}
+		// Буфер, выброшенный из пула сборщиком мусора, освобождает C память
+		runtime.SetFinalizer(b, (*cBuffer).release)
+		return b
+	},
+}
+
+// getBuffer берет буфер из пула и копирует в него сообщения
+func getBuffer(messages [][]byte) *cBuffer {
+	b := bufferPool.Get().(*cBuffer)
+	b.fill(messages)
+	return b
+}
+
+// putBuffer возвращает буфер в пул
+func putBuffer(b *cBuffer) {
+	bufferPool.Put(b)
+}
+
+// reserve гарантирует место под dataLen байт и n+1 offsets
+func (b *cBuffer) reserve(dataLen, n int) {
+	if dataLen > b.dataCap || b.data == nil {
+		size := max(dataLen, 2*b.dataCap, minBufferData)
+		C.free(unsafe.Pointer(b.data))
+		b.data = (*C.uchar)(C.malloc(C.size_t(size)))
+		b.dataCap = size
+	}
+	if n+1 > b.offCap {
+		size := max(n+1, 2*b.offCap, minBufferOffsets)
+		C.free(unsafe.Pointer(b.offsets))
+		b.offsets = (*C.size_t)(C.malloc(C.size_t(size) * C.size_t(unsafe.Sizeof(C.size_t(0)))))
+		b.offCap = size
+	}
+}
+
+// fill копирует сообщения подряд и заполняет offsets
+func (b *cBuffer) fill(messages [][]byte) {
+	total := 0
+	for _, m := range messages {
+		total += len(m)
+	}
+	b.reserve(total, len(messages))
+
+	data := unsafe.Slice((*byte)(unsafe.Pointer(b.data)), b.dataCap)
+	offsets := unsafe.Slice(b.offsets, b.offCap)
+	pos := 0
+	offsets[0] = 0
+	for i, m := range messages {
+		pos += copy(data[pos:], m)
+		offsets[i+1] = C.size_t(pos)
+	}
+}
+
+// release освобождает C память
+func (b *cBuffer) release() {
+	C.free(unsafe.Pointer(b.data))
+	C.free(unsafe.Pointer(b.offsets))
+	b.data, b.offsets = nil, nil
+	b.dataCap, b.offCap = 0, 0
+}
@@ -0,0 +1,128 @@
+//go:build cgo && !purego
+
+package cgobridge
+
+/*
+#include "engine.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"runtime"
+	"unsafe"
+)
+
+// Builder - потоковый построитель root (merkle_builder в engine).
+// Сообщения подаются по одному, поэтому батч можно читать курсором из MySQL,
+// не загружая все payload сразу. Построитель из NewBuilder держит в памяти
+// O(log n) хешей, из NewTreeBuilder - все O(n) узлов дерева.
+// Builder нельзя использовать из нескольких горутин одновременно.
+type Builder struct {
+	b    *C.merkle_builder
+	opts Options
+}
+
+// NewBuilder создает построитель. Поле opts.Workers не используется.
+// После работы нужно вызвать Free.
+func NewBuilder(opts Options) (*Builder, error) {
+	return newBuilder(opts, false)
+}
+
+// NewTreeBuilder создает построитель, который дополнительно хранит все узлы
+// (32 байта на узел, около 2n узлов на n сообщений, сами сообщения не хранятся)
+// и умеет отдать Tree. Память - O(n) хешей, а не O(log n), как у NewBuilder.
+func NewTreeBuilder(opts Options) (*Builder, error) {
+	return newBuilder(opts, true)
+}
+
+func newBuilder(opts Options, keepTree bool) (*Builder, error) {
+	params := engineParams(opts)
+	errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
+	var b *C.merkle_builder
+	if keepTree {
+		b = C.merkle_builder_new_tree(&params, &errbuf[0], C.int(C.ENGINE_ERRBUF_SIZE))
+	} else {
+		b = C.merkle_builder_new(&params, &errbuf[0], C.int(C.ENGINE_ERRBUF_SIZE))
+	}
+	if b == nil {
+		return nil, errors.New(C.GoString(&errbuf[0]))
+	}
+	builder := &Builder{b: b, opts: opts}
+	// Забытый построитель освобождается сборщиком мусора
+	runtime.SetFinalizer(builder, (*Builder).Free)
+	return builder, nil
+}
+
+// Append добавляет сообщение. msg не копируется: engine хеширует его во время вызова.
+func (b *Builder) Append(msg []byte) error {
+	errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
+	res := C.merkle_builder_append(
+		b.b,
+		(*C.uchar)(unsafe.Pointer(unsafe.SliceData(msg))),
+		C.size_t(len(msg)),
+		&errbuf[0],
+		C.int(C.ENGINE_ERRBUF_SIZE),
+	)
+	runtime.KeepAlive(b)
+	if res != 0 {
+		return errors.New(C.GoString(&errbuf[0]))
+	}
+	return nil
+}
+
+// Len число добавленных сообщений
+func (b *Builder) Len() int {
+	n := int(C.merkle_builder_count(b.b))
+	runtime.KeepAlive(b)
+	return n
+}
+
+// Finalize возвращает root всех добавленных сообщений. Он совпадает с
+// MerkleRootWithOptions для тех же сообщений; после вызова можно продолжать Append.
+func (b *Builder) Finalize() ([]byte, error) {
+	root := make([]byte, C.ENGINE_HASH_SIZE)
+	errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
+	res := C.merkle_builder_finalize(
+		b.b,
+		(*C.uchar)(unsafe.Pointer(&root[0])),
+		&errbuf[0],
+		C.int(C.ENGINE_ERRBUF_SIZE),
+	)
+	runtime.KeepAlive(b)
+	if res != 0 {
+		return nil, errors.New(C.GoString(&errbuf[0]))
+	}
+	return root, nil
+}
+
+// Tree возвращает все уровни дерева добавленных сообщений (только для NewTreeBuilder)
+func (b *Builder) Tree() (*Tree, error) {
+	n := b.Len()
+	if n == 0 {
+		return nil, errors.New("empty messages")
+	}
+	nodes := make([]byte, int(C.merkle_tree_nodes(C.size_t(n)))*C.ENGINE_HASH_SIZE)
+	errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
+	res := C.merkle_builder_tree(
+		b.b,
+		(*C.uchar)(unsafe.Pointer(&nodes[0])),
+		&errbuf[0],
+		C.int(C.ENGINE_ERRBUF_SIZE),
+	)
+	runtime.KeepAlive(b)
+	if res != 0 {
+		return nil, errors.New(C.GoString(&errbuf[0]))
+	}
+	return &Tree{Params: b.opts.params(), N: n, Nodes: nodes}, nil
+}
+
+// Free освобождает построитель в engine. Повторный вызов ничего не делает.
+func (b *Builder) Free() {
+	if b.b == nil {
+		return
+	}
+	C.merkle_builder_free(b.b)
+	b.b = nil
+	runtime.SetFinalizer(b, nil)
+}
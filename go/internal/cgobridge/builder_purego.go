@@ -0,0 +1,52 @@
+//go:build !cgo || purego
+
+package cgobridge
+
+import "veriChat/go/pkg/verify"
+
+// Builder - потоковый построитель root (Go backend, см. verify.Builder)
+type Builder struct {
+	b *verify.Builder
+}
+
+// NewBuilder создает построитель. Поле opts.Workers не используется.
+func NewBuilder(opts Options) (*Builder, error) {
+	b, err := opts.params().NewBuilder()
+	if err != nil {
+		return nil, err
+	}
+	return &Builder{b: b}, nil
+}
+
+// NewTreeBuilder создает построитель, который хранит все узлы (O(n) хешей) и умеет отдать Tree
+func NewTreeBuilder(opts Options) (*Builder, error) {
+	b, err := opts.params().NewTreeBuilder()
+	if err != nil {
+		return nil, err
+	}
+	return &Builder{b: b}, nil
+}
+
+// Append добавляет сообщение
+func (b *Builder) Append(msg []byte) error {
+	b.b.Append(msg)
+	return nil
+}
+
+// Len число добавленных сообщений
+func (b *Builder) Len() int {
+	return int(b.b.Len())
+}
+
+// Finalize возвращает root всех добавленных сообщений
+func (b *Builder) Finalize() ([]byte, error) {
+	return b.b.Root()
+}
+
+// Tree возвращает все уровни дерева добавленных сообщений (только для NewTreeBuilder)
+func (b *Builder) Tree() (*Tree, error) {
+	return b.b.Tree()
+}
+
+// Free ничего не делает: у Go backend нет памяти вне Go
+func (b *Builder) Free() {}
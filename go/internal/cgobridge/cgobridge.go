@@ -1,7 +1,9 @@
+//go:build cgo && !purego
+
 package cgobridge
 
 /*
-#cgo LDFLAGS: -L../../clib/build -lengine -lcrypto
+#cgo LDFLAGS: -L${SRCDIR}/../../../clib/build -lengine -lcrypto
 #cgo CFLAGS: -I${SRCDIR}/../../../clib
 #include <stdlib.h>
 #include "engine.h"
@@ -10,63 +12,388 @@ import "C"
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 	"unsafe"
 )
 
-// MerkleRoot вызывает C++ функцию merkle_root и возвращает SHA256 root
-func MerkleRoot(messages [][]byte) ([]byte, error) {
-    n := len(messages)
-    if n == 0 {
-        return nil, errors.New("empty messages")
-    }
-
-    // Подготовка массивов C - ВСЕ данные должны быть в C памяти
-    cInputs := make([]*C.char, n)
-    cLengths := make([]C.size_t, n)
-    
-    // Слайс для отслеживания памяти, которую нужно освободить
-    allocated := make([]unsafe.Pointer, 0, n)
-
-    defer func() {
-        // Освобождаем всю выделенную C память
-        for _, ptr := range allocated {
-            C.free(ptr)
-        }
-    }()
-
-    for i, msg := range messages {
-        if len(msg) == 0 {
-            // Для пустого сообщения создаем нулевой указатель
-            cInputs[i] = nil
-            cLengths[i] = 0
-        } else {
-            // Копируем данные в C память
-            cData := C.CBytes(msg)
-            cInputs[i] = (*C.char)(cData)
-            cLengths[i] = C.size_t(len(msg))
-            allocated = append(allocated, cData)
-        }
-    }
-
-    var outRoot *C.uchar
-    errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
-
-    res := C.merkle_root(
-        &cInputs[0],
-        &cLengths[0],
-        C.size_t(n),
-        &outRoot,
-        &errbuf[0],
-        C.int(C.ENGINE_ERRBUF_SIZE),
-    )
-
-    if res != 0 {
-        return nil, errors.New(C.GoString(&errbuf[0]))
-    }
-
-    // Копируем результат в Go
-    root := C.GoBytes(unsafe.Pointer(outRoot), C.SHA256_SIZE)
-    C.free_root(outRoot)
-
-    return root, nil
-}
\ No newline at end of file
+// Backend имя реализации, с которой собран пакет
+const Backend = "engine"
+
+// cInputs - сообщения, скопированные в C память по одному
+type cInputs struct {
+	ptrs      []*C.char
+	lengths   []C.size_t
+	allocated []unsafe.Pointer
+}
+
+// newCInputs копирует сообщения в C память - ВСЕ данные должны быть в C памяти
+func newCInputs(messages [][]byte) *cInputs {
+	n := len(messages)
+	in := &cInputs{
+		ptrs:      make([]*C.char, n),
+		lengths:   make([]C.size_t, n),
+		allocated: make([]unsafe.Pointer, 0, n),
+	}
+	for i, msg := range messages {
+		if len(msg) == 0 {
+			// Для пустого сообщения создаем нулевой указатель
+			in.ptrs[i] = nil
+			in.lengths[i] = 0
+		} else {
+			// Копируем данные в C память
+			cData := C.CBytes(msg)
+			in.ptrs[i] = (*C.char)(cData)
+			in.lengths[i] = C.size_t(len(msg))
+			in.allocated = append(in.allocated, cData)
+		}
+	}
+	return in
+}
+
+// free освобождает всю выделенную C память
+func (in *cInputs) free() {
+	for _, ptr := range in.allocated {
+		C.free(ptr)
+	}
+}
+
+// engineParams переводит Options в engine_params
+func engineParams(opts Options) C.engine_params {
+	version := opts.TreeVersion
+	if version == 0 {
+		version = TreeLegacy
+	}
+	return C.engine_params{
+		tree_version: C.int(version),
+		threads:      C.int(opts.Workers),
+		hash_alg:     C.int(opts.Hash),
+	}
+}
+
+// MerkleRootWithOptions вызывает C++ функцию merkle_root_buf и возвращает Merkle root.
+// Сообщения копируются одним куском в переиспользуемую C память (см. cBuffer).
+func MerkleRootWithOptions(messages [][]byte, opts Options) ([]byte, error) {
+	n := len(messages)
+	if n == 0 {
+		return nil, errors.New("empty messages")
+	}
+
+	buf := getBuffer(messages)
+	defer putBuffer(buf)
+
+	params := engineParams(opts)
+	root := make([]byte, C.ENGINE_HASH_SIZE)
+	errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
+
+	res := C.merkle_root_buf(
+		buf.data,
+		buf.offsets,
+		C.size_t(n),
+		&params,
+		(*C.uchar)(unsafe.Pointer(&root[0])),
+		&errbuf[0],
+		C.int(C.ENGINE_ERRBUF_SIZE),
+	)
+
+	if res != 0 {
+		return nil, errors.New(C.GoString(&errbuf[0]))
+	}
+	return root, nil
+}
+
+// MerkleTree вызывает C++ функцию merkle_tree_buf и возвращает все уровни дерева.
+// Root() дерева совпадает с MerkleRootWithOptions, Proof(i) - с MerkleProofWithOptions.
+func MerkleTree(messages [][]byte, opts Options) (*Tree, error) {
+	n := len(messages)
+	if n == 0 {
+		return nil, errors.New("empty messages")
+	}
+
+	buf := getBuffer(messages)
+	defer putBuffer(buf)
+
+	params := engineParams(opts)
+	nodes := make([]byte, int(C.merkle_tree_nodes(C.size_t(n)))*C.ENGINE_HASH_SIZE)
+	errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
+
+	res := C.merkle_tree_buf(
+		buf.data,
+		buf.offsets,
+		C.size_t(n),
+		&params,
+		(*C.uchar)(unsafe.Pointer(&nodes[0])),
+		&errbuf[0],
+		C.int(C.ENGINE_ERRBUF_SIZE),
+	)
+
+	if res != 0 {
+		return nil, errors.New(C.GoString(&errbuf[0]))
+	}
+	return &Tree{Params: opts.params(), N: n, Nodes: nodes}, nil
+}
+
+// MerkleMultiProof вызывает C++ функцию merkle_multiproof_buf и возвращает один
+// proof для сообщений messages[indices]. Позиции сортируются, повторы убираются.
+func MerkleMultiProof(messages [][]byte, indices []int, opts Options) (*MultiProof, error) {
+	n := len(messages)
+	if n == 0 || len(indices) == 0 {
+		return nil, errors.New("empty messages")
+	}
+	sorted := slices.Clone(indices)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	if sorted[0] < 0 || sorted[len(sorted)-1] >= n {
+		return nil, errors.New("index out of range")
+	}
+	cIndices := make([]C.size_t, len(sorted))
+	for i, idx := range sorted {
+		cIndices[i] = C.size_t(idx)
+	}
+
+	buf := getBuffer(messages)
+	defer putBuffer(buf)
+
+	params := engineParams(opts)
+	var outNodes *C.uchar
+	var outCount C.size_t
+	errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
+
+	res := C.merkle_multiproof_buf(
+		buf.data,
+		buf.offsets,
+		C.size_t(n),
+		&cIndices[0],
+		C.size_t(len(cIndices)),
+		&params,
+		&outNodes,
+		&outCount,
+		&errbuf[0],
+		C.int(C.ENGINE_ERRBUF_SIZE),
+	)
+
+	if res != 0 {
+		return nil, errors.New(C.GoString(&errbuf[0]))
+	}
+	defer C.free_multiproof(outNodes)
+
+	count := int(outCount)
+	flat := C.GoBytes(unsafe.Pointer(outNodes), C.int(count*C.ENGINE_HASH_SIZE))
+	mp := &MultiProof{N: n, Indices: sorted, Nodes: make([][]byte, count)}
+	for i := range mp.Nodes {
+		mp.Nodes[i] = flat[i*C.ENGINE_HASH_SIZE : (i+1)*C.ENGINE_HASH_SIZE : (i+1)*C.ENGINE_HASH_SIZE]
+	}
+	return mp, nil
+}
+
+// MerkleRoots считает roots многих наборов за один вызов merkle_roots_buf, чтобы
+// не платить за переход в cgo на каждый набор. Наборы делятся между workers
+// потоками engine. Ошибка одного набора не мешает остальным.
+func MerkleRoots(sets []RootSet, workers int) []RootResult {
+	results := make([]RootResult, len(sets))
+	if len(sets) == 0 {
+		return results
+	}
+
+	total := 0
+	for _, set := range sets {
+		total += len(set.Messages)
+	}
+	messages := make([][]byte, 0, total)
+	setOffsets := make([]C.size_t, len(sets)+1)
+	params := make([]C.engine_params, len(sets))
+	for i, set := range sets {
+		messages = append(messages, set.Messages...)
+		setOffsets[i+1] = C.size_t(len(messages))
+		params[i] = engineParams(set.Options)
+	}
+
+	buf := getBuffer(messages)
+	defer putBuffer(buf)
+
+	roots := make([]byte, len(sets)*C.ENGINE_HASH_SIZE)
+	codes := make([]C.int, len(sets))
+	errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
+
+	res := C.merkle_roots_buf(
+		buf.data,
+		buf.offsets,
+		&setOffsets[0],
+		C.size_t(len(sets)),
+		&params[0],
+		C.int(workers),
+		(*C.uchar)(unsafe.Pointer(&roots[0])),
+		&codes[0],
+		&errbuf[0],
+		C.int(C.ENGINE_ERRBUF_SIZE),
+	)
+
+	var firstErr string
+	if res != 0 {
+		firstErr = C.GoString(&errbuf[0])
+	}
+	for i := range sets {
+		switch {
+		case codes[i] == 0:
+			results[i].Root = roots[i*C.ENGINE_HASH_SIZE : (i+1)*C.ENGINE_HASH_SIZE : (i+1)*C.ENGINE_HASH_SIZE]
+		case len(sets[i].Messages) == 0:
+			results[i].Err = errors.New("empty messages")
+		default:
+			// engine пишет текст только для первого неудачного набора
+			results[i].Err = fmt.Errorf("engine error %d (first failure: %s)", int(codes[i]), firstErr)
+		}
+	}
+	return results
+}
+
+// MerkleTrees - MerkleRoots, который возвращает все уровни дерева каждого набора
+// (merkle_trees_buf): для группового flush, чтобы деревья батчей сразу попали в кеш proof.
+func MerkleTrees(sets []RootSet, workers int) []TreeResult {
+	results := make([]TreeResult, len(sets))
+	if len(sets) == 0 {
+		return results
+	}
+
+	total, totalNodes := 0, 0
+	for _, set := range sets {
+		total += len(set.Messages)
+		totalNodes += int(C.merkle_tree_nodes(C.size_t(len(set.Messages))))
+	}
+	messages := make([][]byte, 0, total)
+	setOffsets := make([]C.size_t, len(sets)+1)
+	params := make([]C.engine_params, len(sets))
+	for i, set := range sets {
+		messages = append(messages, set.Messages...)
+		setOffsets[i+1] = C.size_t(len(messages))
+		params[i] = engineParams(set.Options)
+	}
+
+	buf := getBuffer(messages)
+	defer putBuffer(buf)
+
+	// хотя бы один узел, чтобы взять адрес, даже если все наборы пустые
+	nodes := make([]byte, max(totalNodes, 1)*C.ENGINE_HASH_SIZE)
+	codes := make([]C.int, len(sets))
+	errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
+
+	res := C.merkle_trees_buf(
+		buf.data,
+		buf.offsets,
+		&setOffsets[0],
+		C.size_t(len(sets)),
+		&params[0],
+		C.int(workers),
+		(*C.uchar)(unsafe.Pointer(&nodes[0])),
+		&codes[0],
+		&errbuf[0],
+		C.int(C.ENGINE_ERRBUF_SIZE),
+	)
+
+	var firstErr string
+	if res != 0 {
+		firstErr = C.GoString(&errbuf[0])
+	}
+	pos := 0
+	for i, set := range sets {
+		n := len(set.Messages)
+		size := int(C.merkle_tree_nodes(C.size_t(n))) * C.ENGINE_HASH_SIZE
+		switch {
+		case codes[i] == 0:
+			results[i].Tree = &Tree{Params: set.Options.params(), N: n, Nodes: nodes[pos : pos+size : pos+size]}
+		case n == 0:
+			results[i].Err = errors.New("empty messages")
+		default:
+			// engine пишет текст только для первого неудачного набора
+			results[i].Err = fmt.Errorf("engine error %d (first failure: %s)", int(codes[i]), firstErr)
+		}
+		pos += size
+	}
+	return results
+}
+
+// merkleRootPerMessage - прежний путь через merkle_root_ex: C.CBytes и C.free
+// на каждое сообщение. Оставлен для сравнения в тестах и бенчмарках.
+func merkleRootPerMessage(messages [][]byte, opts Options) ([]byte, error) {
+	n := len(messages)
+	if n == 0 {
+		return nil, errors.New("empty messages")
+	}
+
+	in := newCInputs(messages)
+	defer in.free()
+
+	params := engineParams(opts)
+	var outRoot *C.uchar
+	errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
+
+	res := C.merkle_root_ex(
+		&in.ptrs[0],
+		&in.lengths[0],
+		C.size_t(n),
+		&params,
+		&outRoot,
+		&errbuf[0],
+		C.int(C.ENGINE_ERRBUF_SIZE),
+	)
+
+	if res != 0 {
+		return nil, errors.New(C.GoString(&errbuf[0]))
+	}
+
+	// Копируем результат в Go
+	root := C.GoBytes(unsafe.Pointer(outRoot), C.ENGINE_HASH_SIZE)
+	C.free_root(outRoot)
+
+	return root, nil
+}
+
+// MerkleProofWithOptions вызывает C++ функцию merkle_proof_buf и возвращает путь
+// включения сообщения messages[index] в дерево, root которого возвращает
+// MerkleRootWithOptions с теми же opts
+func MerkleProofWithOptions(messages [][]byte, index int, opts Options) (*Proof, error) {
+	n := len(messages)
+	if n == 0 {
+		return nil, errors.New("empty messages")
+	}
+	if index < 0 || index >= n {
+		return nil, errors.New("index out of range")
+	}
+
+	buf := getBuffer(messages)
+	defer putBuffer(buf)
+
+	params := engineParams(opts)
+	siblings := make([]byte, C.ENGINE_MAX_DEPTH*C.ENGINE_HASH_SIZE)
+	left := make([]byte, C.ENGINE_MAX_DEPTH)
+	var outLen C.size_t
+	errbuf := make([]C.char, C.ENGINE_ERRBUF_SIZE)
+
+	res := C.merkle_proof_buf(
+		buf.data,
+		buf.offsets,
+		C.size_t(n),
+		C.size_t(index),
+		&params,
+		(*C.uchar)(unsafe.Pointer(&siblings[0])),
+		(*C.uchar)(unsafe.Pointer(&left[0])),
+		&outLen,
+		&errbuf[0],
+		C.int(C.ENGINE_ERRBUF_SIZE),
+	)
+
+	if res != 0 {
+		return nil, errors.New(C.GoString(&errbuf[0]))
+	}
+
+	pathLen := int(outLen)
+	proof := &Proof{
+		Index:    index,
+		Siblings: make([][]byte, pathLen),
+		Left:     make([]bool, pathLen),
+	}
+	for i := 0; i < pathLen; i++ {
+		proof.Siblings[i] = siblings[i*C.ENGINE_HASH_SIZE : (i+1)*C.ENGINE_HASH_SIZE : (i+1)*C.ENGINE_HASH_SIZE]
+		proof.Left[i] = left[i] != 0
+	}
+	return proof, nil
+}
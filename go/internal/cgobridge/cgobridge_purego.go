@@ -0,0 +1,36 @@
+//go:build !cgo || purego
+
+package cgobridge
+
+// Backend имя реализации, с которой собран пакет
+const Backend = "go"
+
+// MerkleRootWithOptions возвращает Merkle root (Go backend)
+func MerkleRootWithOptions(messages [][]byte, opts Options) ([]byte, error) {
+	return goMerkleRoot(messages, opts)
+}
+
+// MerkleProofWithOptions возвращает путь включения messages[index] (Go backend)
+func MerkleProofWithOptions(messages [][]byte, index int, opts Options) (*Proof, error) {
+	return goMerkleProof(messages, index, opts)
+}
+
+// MerkleTree возвращает все уровни дерева (Go backend)
+func MerkleTree(messages [][]byte, opts Options) (*Tree, error) {
+	return goMerkleTree(messages, opts)
+}
+
+// MerkleMultiProof возвращает multiproof для messages[indices] (Go backend)
+func MerkleMultiProof(messages [][]byte, indices []int, opts Options) (*MultiProof, error) {
+	return goMerkleMultiProof(messages, indices, opts)
+}
+
+// MerkleRoots считает roots наборов по очереди (Go backend)
+func MerkleRoots(sets []RootSet, workers int) []RootResult {
+	return goMerkleRoots(sets)
+}
+
+// MerkleTrees строит деревья наборов по очереди (Go backend)
+func MerkleTrees(sets []RootSet, workers int) []TreeResult {
+	return goMerkleTrees(sets)
+}
@@ -3,6 +3,9 @@ package cgobridge
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"runtime"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -212,4 +215,426 @@ func ExampleMerkleRoot() {
 
 	hexRoot := hex.EncodeToString(root)
 	println("Merkle Root:", hexRoot)
-}
\ No newline at end of file
+}
+// rootFromProof поднимается от листа к корню по правилам engine
+func rootFromProof(message []byte, proof *Proof) []byte {
+	h := sha256.Sum256(message)
+	cur := h[:]
+	for i, sib := range proof.Siblings {
+		var concat []byte
+		if proof.Left[i] {
+			concat = append(append(concat, sib...), cur...)
+		} else {
+			concat = append(append(concat, cur...), sib...)
+		}
+		next := sha256.Sum256(concat)
+		cur = next[:]
+	}
+	return cur
+}
+
+func TestMerkleProof(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		messages := make([][]byte, n)
+		for i := range messages {
+			messages[i] = []byte(fmt.Sprintf("message %d", i))
+		}
+		root, err := MerkleRoot(messages)
+		require.NoError(t, err)
+
+		for i := 0; i < n; i++ {
+			proof, err := MerkleProof(messages, i)
+			require.NoError(t, err, "n=%d index=%d", n, i)
+			assert.Equal(t, i, proof.Index)
+			assert.Len(t, proof.Left, len(proof.Siblings))
+			assert.Equal(t, root, rootFromProof(messages[i], proof), "n=%d index=%d", n, i)
+		}
+	}
+}
+
+func TestMerkleProofOddLevelDuplicatesLastNode(t *testing.T) {
+	messages := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+
+	proof, err := MerkleProof(messages, 2)
+	require.NoError(t, err)
+	require.Len(t, proof.Siblings, 2)
+
+	leaf := sha256.Sum256([]byte("c"))
+	assert.Equal(t, leaf[:], proof.Siblings[0], "last node of odd level is paired with itself")
+	assert.False(t, proof.Left[0])
+	assert.True(t, proof.Left[1])
+}
+
+func TestMerkleProofErrors(t *testing.T) {
+	_, err := MerkleProof([][]byte{}, 0)
+	assert.Error(t, err)
+
+	_, err = MerkleProof([][]byte{[]byte("a")}, 1)
+	assert.Error(t, err)
+
+	_, err = MerkleProof([][]byte{[]byte("a")}, -1)
+	assert.Error(t, err)
+
+	proof, err := MerkleProof([][]byte{[]byte("a")}, 0)
+	require.NoError(t, err)
+	assert.Empty(t, proof.Siblings)
+}
+
+func TestMerkleRootRFC6962(t *testing.T) {
+	rfc := Options{TreeVersion: TreeRFC6962}
+	leaf := func(m string) []byte {
+		h := sha256.Sum256(append([]byte{0x00}, m...))
+		return h[:]
+	}
+	node := func(l, r []byte) []byte {
+		h := sha256.Sum256(append(append([]byte{0x01}, l...), r...))
+		return h[:]
+	}
+
+	root, err := MerkleRootWithOptions([][]byte{[]byte("a")}, rfc)
+	require.NoError(t, err)
+	assert.Equal(t, leaf("a"), root)
+
+	// последний узел нечетного уровня поднимается без дублирования
+	root, err = MerkleRootWithOptions([][]byte{[]byte("a"), []byte("b"), []byte("c")}, rfc)
+	require.NoError(t, err)
+	assert.Equal(t, node(node(leaf("a"), leaf("b")), leaf("c")), root)
+}
+
+func TestMerkleRootRFC6962NoDuplicateCollision(t *testing.T) {
+	abc := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	abcc := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("c")}
+
+	legacy1, err := MerkleRoot(abc)
+	require.NoError(t, err)
+	legacy2, err := MerkleRoot(abcc)
+	require.NoError(t, err)
+	assert.Equal(t, legacy1, legacy2, "legacy tree collides on duplicated last message")
+
+	rfc := Options{TreeVersion: TreeRFC6962}
+	rfc1, err := MerkleRootWithOptions(abc, rfc)
+	require.NoError(t, err)
+	rfc2, err := MerkleRootWithOptions(abcc, rfc)
+	require.NoError(t, err)
+	assert.NotEqual(t, rfc1, rfc2)
+	assert.NotEqual(t, legacy1, rfc1)
+}
+
+func TestMerkleProofRFC6962(t *testing.T) {
+	rfc := Options{TreeVersion: TreeRFC6962}
+	for n := 1; n <= 9; n++ {
+		messages := make([][]byte, n)
+		for i := range messages {
+			messages[i] = []byte(fmt.Sprintf("message %d", i))
+		}
+		root, err := MerkleRootWithOptions(messages, rfc)
+		require.NoError(t, err)
+
+		for i := 0; i < n; i++ {
+			proof, err := MerkleProofWithOptions(messages, i, rfc)
+			require.NoError(t, err, "n=%d index=%d", n, i)
+
+			cur := sha256.Sum256(append([]byte{0x00}, messages[i]...))
+			h := cur[:]
+			for k, sib := range proof.Siblings {
+				var next [32]byte
+				if proof.Left[k] {
+					next = sha256.Sum256(append(append([]byte{0x01}, sib...), h...))
+				} else {
+					next = sha256.Sum256(append(append([]byte{0x01}, h...), sib...))
+				}
+				h = next[:]
+			}
+			assert.Equal(t, root, h, "n=%d index=%d", n, i)
+		}
+	}
+}
+
+func TestMerkleRootUnknownTreeVersion(t *testing.T) {
+	_, err := MerkleRootWithOptions([][]byte{[]byte("a")}, Options{TreeVersion: 42})
+	assert.Error(t, err)
+
+	_, err = MerkleProofWithOptions([][]byte{[]byte("a")}, 0, Options{TreeVersion: 42})
+	assert.Error(t, err)
+}
+
+func flushMessages(n int) [][]byte {
+	messages := make([][]byte, n)
+	for i := range messages {
+		messages[i] = []byte(fmt.Sprintf("chat message #%d with some typical text payload", i))
+	}
+	return messages
+}
+
+func TestMerkleRootWorkersIdentical(t *testing.T) {
+	for _, n := range []int{1, 3, 1023, 1024, 1025, 4097, 65536} {
+		messages := flushMessages(n)
+		for _, version := range []TreeVersion{TreeLegacy, TreeRFC6962} {
+			want, err := MerkleRootWithOptions(messages, Options{TreeVersion: version, Workers: 1})
+			require.NoError(t, err)
+
+			for _, workers := range []int{0, 2, 3, 8} {
+				got, err := MerkleRootWithOptions(messages, Options{TreeVersion: version, Workers: workers})
+				require.NoError(t, err)
+				assert.Equal(t, want, got, "n=%d version=%v workers=%d", n, version, workers)
+			}
+
+			proof, err := MerkleProofWithOptions(messages, n/2, Options{TreeVersion: version, Workers: 4})
+			require.NoError(t, err)
+			single, err := MerkleProofWithOptions(messages, n/2, Options{TreeVersion: version, Workers: 1})
+			require.NoError(t, err)
+			assert.Equal(t, single, proof, "n=%d version=%v", n, version)
+		}
+	}
+}
+
+func TestMerkleRootWorkersConcurrentCalls(t *testing.T) {
+	messages := flushMessages(20000)
+	want, err := MerkleRootWithOptions(messages, Options{TreeVersion: TreeRFC6962})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(workers int) {
+			defer wg.Done()
+			got, err := MerkleRootWithOptions(messages, Options{TreeVersion: TreeRFC6962, Workers: workers})
+			assert.NoError(t, err)
+			assert.Equal(t, want, got, "workers=%d", workers)
+		}(g + 1)
+	}
+	wg.Wait()
+}
+
+// BenchmarkMerkleRoot64k - flush на 64k сообщений в зависимости от числа потоков engine.
+// go test -bench MerkleRoot64k ./go/internal/cgobridge
+func BenchmarkMerkleRoot64k(b *testing.B) {
+	messages := flushMessages(64 * 1024)
+	workerCounts := []int{1, 2, 4, 8}
+	if n := runtime.NumCPU(); n > 8 {
+		workerCounts = append(workerCounts, n)
+	}
+
+	for _, workers := range workerCounts {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			opts := Options{TreeVersion: TreeRFC6962, Workers: workers}
+			for i := 0; i < b.N; i++ {
+				if _, err := MerkleRootWithOptions(messages, opts); err != nil {
+					b.Fatalf("MerkleRootWithOptions failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestMerkleRootHashAlgorithms(t *testing.T) {
+	messages := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	roots := make(map[string]HashAlg)
+
+	for _, alg := range []HashAlg{HashSHA256, HashSHA512_256, HashSHA3_256} {
+		single, err := MerkleRootWithOptions([][]byte{[]byte("hello")}, Options{Hash: alg})
+		require.NoError(t, err)
+		assert.Equal(t, alg.Sum([]byte("hello")), single, "legacy single leaf is the plain digest, alg=%v", alg)
+
+		root, err := MerkleRootWithOptions(messages, Options{TreeVersion: TreeRFC6962, Hash: alg})
+		require.NoError(t, err)
+		assert.Len(t, root, 32)
+		_, dup := roots[string(root)]
+		assert.False(t, dup, "alg=%v produced the same root as another algorithm", alg)
+		roots[string(root)] = alg
+	}
+
+	defaultRoot, err := MerkleRootWithOptions(messages, Options{TreeVersion: TreeRFC6962})
+	require.NoError(t, err)
+	assert.Equal(t, HashSHA256, roots[string(defaultRoot)], "zero Hash means SHA-256")
+}
+
+func TestMerkleRootUnknownHashAlg(t *testing.T) {
+	_, err := MerkleRootWithOptions([][]byte{[]byte("a")}, Options{Hash: 42})
+	assert.Error(t, err)
+
+	_, err = MerkleProofWithOptions([][]byte{[]byte("a")}, 0, Options{Hash: 42})
+	assert.Error(t, err)
+}
+
+func TestBuilderMatchesMerkleRoot(t *testing.T) {
+	messages := flushMessages(300)
+	messages[7] = nil
+	for _, version := range []TreeVersion{TreeLegacy, TreeRFC6962} {
+		for _, alg := range []HashAlg{HashSHA256, HashSHA3_256} {
+			opts := Options{TreeVersion: version, Hash: alg}
+			b, err := NewBuilder(opts)
+			require.NoError(t, err)
+
+			for n := 1; n <= len(messages); n++ {
+				require.NoError(t, b.Append(messages[n-1]))
+				assert.Equal(t, n, b.Len())
+
+				got, err := b.Finalize()
+				require.NoError(t, err)
+				want, err := MerkleRootWithOptions(messages[:n], opts)
+				require.NoError(t, err)
+				assert.Equal(t, want, got, "version=%v alg=%v n=%d", version, alg, n)
+			}
+			b.Free()
+			b.Free()
+		}
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	b, err := NewBuilder(Options{TreeVersion: TreeRFC6962})
+	require.NoError(t, err)
+	defer b.Free()
+	_, err = b.Finalize()
+	assert.Error(t, err)
+
+	_, err = NewBuilder(Options{TreeVersion: 42})
+	assert.Error(t, err)
+	_, err = NewBuilder(Options{Hash: 42})
+	assert.Error(t, err)
+}
+
+func TestMerkleRootsMatchesSingleCalls(t *testing.T) {
+	options := []Options{
+		{},
+		{TreeVersion: TreeRFC6962},
+		{TreeVersion: TreeRFC6962, Hash: HashSHA3_256},
+		{TreeVersion: TreeLegacy, Hash: HashSHA512_256},
+	}
+	sets := make([]RootSet, 3000)
+	for i := range sets {
+		sets[i] = RootSet{Messages: flushMessages(1 + i%17), Options: options[i%len(options)]}
+	}
+
+	for _, workers := range []int{1, 4} {
+		results := MerkleRoots(sets, workers)
+		require.Len(t, results, len(sets))
+		for i, set := range sets {
+			want, err := MerkleRootWithOptions(set.Messages, set.Options)
+			require.NoError(t, err)
+			require.NoError(t, results[i].Err, "set %d", i)
+			assert.Equal(t, want, results[i].Root, "set %d workers=%d", i, workers)
+		}
+	}
+}
+
+func TestMerkleRootsPerSetErrors(t *testing.T) {
+	good := flushMessages(5)
+	sets := []RootSet{
+		{Messages: good},
+		{Messages: nil},
+		{Messages: good, Options: Options{Hash: 42}},
+		{Messages: good, Options: Options{TreeVersion: TreeRFC6962}},
+	}
+	results := MerkleRoots(sets, 2)
+
+	want, err := MerkleRoot(good)
+	require.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, want, results[0].Root)
+	assert.Error(t, results[1].Err)
+	assert.Error(t, results[2].Err)
+	assert.NoError(t, results[3].Err)
+
+	assert.Empty(t, MerkleRoots(nil, 1))
+}
+
+func TestMerkleTreesMatchesSingleCalls(t *testing.T) {
+	options := []Options{
+		{},
+		{TreeVersion: TreeRFC6962},
+		{TreeVersion: TreeRFC6962, Hash: HashSHA3_256},
+	}
+	sets := make([]RootSet, 500)
+	for i := range sets {
+		sets[i] = RootSet{Messages: flushMessages(1 + i%17), Options: options[i%len(options)]}
+	}
+	sets[7] = RootSet{Messages: nil}
+	sets[9].Options.Hash = 42
+
+	for _, workers := range []int{1, 4} {
+		results := MerkleTrees(sets, workers)
+		require.Len(t, results, len(sets))
+		assert.Error(t, results[7].Err)
+		assert.Error(t, results[9].Err)
+		for i, set := range sets {
+			if i == 7 || i == 9 {
+				continue
+			}
+			want, err := MerkleTree(set.Messages, set.Options)
+			require.NoError(t, err)
+			require.NoError(t, results[i].Err, "set %d", i)
+			assert.Equal(t, want, results[i].Tree, "set %d workers=%d", i, workers)
+		}
+	}
+
+	assert.Empty(t, MerkleTrees(nil, 1))
+}
+
+func TestMerkleTreeMatchesRootAndProofs(t *testing.T) {
+	messages := flushMessages(70)
+	for _, opts := range []Options{{}, {TreeVersion: TreeRFC6962}, {TreeVersion: TreeRFC6962, Hash: HashSHA3_256}} {
+		b, err := NewTreeBuilder(opts)
+		require.NoError(t, err)
+
+		for n := 1; n <= len(messages); n++ {
+			tree, err := MerkleTree(messages[:n], opts)
+			require.NoError(t, err)
+			root, err := MerkleRootWithOptions(messages[:n], opts)
+			require.NoError(t, err)
+			assert.Equal(t, root, tree.Root(), "opts=%+v n=%d", opts, n)
+
+			for i := 0; i < n; i += 5 {
+				want, err := MerkleProofWithOptions(messages[:n], i, opts)
+				require.NoError(t, err)
+				got, err := tree.Proof(i)
+				require.NoError(t, err)
+				assert.Equal(t, *want, Proof(*got), "opts=%+v n=%d index=%d", opts, n, i)
+			}
+
+			require.NoError(t, b.Append(messages[n-1]))
+			streamed, err := b.Tree()
+			require.NoError(t, err)
+			assert.Equal(t, tree, streamed, "builder tree, opts=%+v n=%d", opts, n)
+		}
+		b.Free()
+	}
+
+	_, err := MerkleTree(nil, Options{})
+	assert.Error(t, err)
+
+	plain, err := NewBuilder(Options{})
+	require.NoError(t, err)
+	defer plain.Free()
+	require.NoError(t, plain.Append([]byte("x")))
+	_, err = plain.Tree()
+	assert.Error(t, err)
+}
+
+func TestMerkleMultiProofMatchesTree(t *testing.T) {
+	messages := flushMessages(45)
+	for _, opts := range []Options{{}, {TreeVersion: TreeRFC6962, Hash: HashSHA512_256}} {
+		tree, err := MerkleTree(messages, opts)
+		require.NoError(t, err)
+
+		for _, indices := range [][]int{{0}, {44}, {3, 4}, {44, 0, 17, 17, 30}, {1, 2, 3, 4, 5, 6, 7, 8}} {
+			mp, err := MerkleMultiProof(messages, indices, opts)
+			require.NoError(t, err)
+			want, err := tree.MultiProof(indices)
+			require.NoError(t, err)
+			assert.Equal(t, want, mp, "opts=%+v indices=%v", opts, indices)
+
+			leaves := make([][]byte, len(mp.Indices))
+			for i, idx := range mp.Indices {
+				leaves[i] = messages[idx]
+			}
+			assert.NoError(t, opts.params().VerifyMultiProof(leaves, mp, tree.Root()))
+		}
+	}
+
+	_, err := MerkleMultiProof(messages, []int{45}, Options{})
+	assert.Error(t, err)
+	_, err = MerkleMultiProof(messages, nil, Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"veriChat/go/internal/metrics"
+)
+
+const accumulatorColumns = `chat_id, size, peaks, root, hash_alg, last_batch_id, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAccumulator(row rowScanner) (*ChatAccumulator, error) {
+	acc := &ChatAccumulator{}
+	err := row.Scan(&acc.ChatID, &acc.Size, &acc.Peaks, &acc.Root, &acc.HashAlg, &acc.LastBatchID, &acc.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// GetChatAccumulator возвращает аккумулятор чата или nil, если его еще нет.
+func GetChatAccumulator(ctx context.Context, chatID int64) (*ChatAccumulator, error) {
+	start := time.Now()
+	acc, err := scanAccumulator(DB.QueryRowContext(ctx,
+		`SELECT `+accumulatorColumns+` FROM chat_accumulators WHERE chat_id = ?`, chatID))
+	metrics.ObserveDB("GetChatAccumulator", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetChatAccumulator failed: %w", err)
+	}
+	return acc, nil
+}
+
+// GetChatAccumulatorTx читает аккумулятор чата с блокировкой строки (FOR UPDATE).
+// Возвращает nil, если его еще нет.
+func GetChatAccumulatorTx(ctx context.Context, tx *sql.Tx, chatID int64) (*ChatAccumulator, error) {
+	start := time.Now()
+	acc, err := scanAccumulator(tx.QueryRowContext(ctx,
+		`SELECT `+accumulatorColumns+` FROM chat_accumulators WHERE chat_id = ? FOR UPDATE`, chatID))
+	metrics.ObserveDB("GetChatAccumulatorTx", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetChatAccumulatorTx failed: %w", err)
+	}
+	return acc, nil
+}
+
+// UpsertChatAccumulatorTx сохраняет пики аккумулятора в рамках tx.
+func UpsertChatAccumulatorTx(ctx context.Context, tx *sql.Tx, acc *ChatAccumulator) error {
+	start := time.Now()
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO chat_accumulators (chat_id, size, peaks, root, hash_alg, last_batch_id)
+         VALUES (?, ?, ?, ?, ?, ?)
+         ON DUPLICATE KEY UPDATE size = VALUES(size), peaks = VALUES(peaks), root = VALUES(root),
+             hash_alg = VALUES(hash_alg), last_batch_id = VALUES(last_batch_id)`,
+		acc.ChatID, acc.Size, acc.Peaks, acc.Root, acc.HashAlg, acc.LastBatchID,
+	)
+	metrics.ObserveDB("UpsertChatAccumulatorTx", start, err)
+	if err != nil {
+		return fmt.Errorf("UpsertChatAccumulatorTx failed: %w", err)
+	}
+	return nil
+}
+
+// GetChatPayloadHashesTx возвращает payload_hash всех сбатченных сообщений чата
+// в порядке истории (batch_id, message_id).
+func GetChatPayloadHashesTx(ctx context.Context, tx *sql.Tx, chatID int64) ([][]byte, error) {
+	start := time.Now()
+	rows, err := tx.QueryContext(ctx,
+		`SELECT payload_hash FROM messages
+         WHERE chat_id = ? AND batch_id IS NOT NULL
+         ORDER BY batch_id, message_id`, chatID)
+	metrics.ObserveDB("GetChatPayloadHashesTx", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetChatPayloadHashesTx query: %w", err)
+	}
+	return scanPayloadHashes(rows, "GetChatPayloadHashesTx")
+}
+
+// GetChatPayloadHashes возвращает первые limit payload_hash истории чата
+func GetChatPayloadHashes(ctx context.Context, chatID, limit int64) ([][]byte, error) {
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT payload_hash FROM messages
+         WHERE chat_id = ? AND batch_id IS NOT NULL
+         ORDER BY batch_id, message_id
+         LIMIT ?`, chatID, limit)
+	metrics.ObserveDB("GetChatPayloadHashes", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetChatPayloadHashes query: %w", err)
+	}
+	return scanPayloadHashes(rows, "GetChatPayloadHashes")
+}
+
+func scanPayloadHashes(rows *sql.Rows, op string) ([][]byte, error) {
+	defer rows.Close()
+
+	var hashes [][]byte
+	for rows.Next() {
+		var h []byte
+		if err := rows.Scan(&h); err != nil {
+			return nil, fmt.Errorf("%s scan: %w", op, err)
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, rows.Err()
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"veriChat/go/internal/metrics"
+)
+
+const (
+	AnchorPending = "pending"
+	AnchorDone    = "done"
+)
+
+const anchorColumns = `batch_id, anchor, status, attempts, last_error, reference, receipt, next_attempt_at, anchored_at`
+
+func scanAnchors(rows *sql.Rows) ([]*BatchAnchor, error) {
+	defer rows.Close()
+	var anchors []*BatchAnchor
+	for rows.Next() {
+		a := &BatchAnchor{}
+		if err := rows.Scan(&a.BatchID, &a.Anchor, &a.Status, &a.Attempts, &a.LastError, &a.Reference,
+			&a.Receipt, &a.NextAttemptAt, &a.AnchoredAt); err != nil {
+			return nil, err
+		}
+		anchors = append(anchors, a)
+	}
+	return anchors, rows.Err()
+}
+
+// InsertPendingAnchorsTx заводит ожидающие публикации батча в каждом anchor в рамках tx,
+// чтобы батч не остался без публикации, если процесс упадет сразу после коммита.
+func InsertPendingAnchorsTx(ctx context.Context, tx *sql.Tx, batchID int64, anchors []string, nextAttemptAt time.Time) error {
+	if len(anchors) == 0 {
+		return nil
+	}
+	start := time.Now()
+	values := make([]string, len(anchors))
+	args := make([]any, 0, 3*len(anchors))
+	for i, name := range anchors {
+		values[i] = "(?, ?, ?)"
+		args = append(args, batchID, name, nextAttemptAt)
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO batch_anchors (batch_id, anchor, next_attempt_at) VALUES `+strings.Join(values, ", "), args...)
+	metrics.ObserveDB("InsertPendingAnchorsTx", start, err)
+	if err != nil {
+		return fmt.Errorf("InsertPendingAnchorsTx failed: %w", err)
+	}
+	return nil
+}
+
+// MarkAnchorDone сохраняет квитанцию публикации.
+func MarkAnchorDone(ctx context.Context, batchID int64, anchor, reference string, receipt []byte) error {
+	start := time.Now()
+	_, err := DB.ExecContext(ctx,
+		`UPDATE batch_anchors
+         SET status = ?, attempts = attempts + 1, last_error = '', reference = ?, receipt = ?, anchored_at = CURRENT_TIMESTAMP
+         WHERE batch_id = ? AND anchor = ?`,
+		AnchorDone, reference, receipt, batchID, anchor)
+	metrics.ObserveDB("MarkAnchorDone", start, err)
+	if err != nil {
+		return fmt.Errorf("MarkAnchorDone failed: %w", err)
+	}
+	return nil
+}
+
+// MarkAnchorFailed откладывает следующую попытку публикации до nextAttemptAt.
+func MarkAnchorFailed(ctx context.Context, batchID int64, anchor, lastError string, nextAttemptAt time.Time) error {
+	start := time.Now()
+	_, err := DB.ExecContext(ctx,
+		`UPDATE batch_anchors SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+         WHERE batch_id = ? AND anchor = ? AND status = ?`,
+		lastError, nextAttemptAt, batchID, anchor, AnchorPending)
+	metrics.ObserveDB("MarkAnchorFailed", start, err)
+	if err != nil {
+		return fmt.Errorf("MarkAnchorFailed failed: %w", err)
+	}
+	return nil
+}
+
+// DeletePendingAnchor снимает ожидающую публикацию батча, которого нет в merkle_batches.
+func DeletePendingAnchor(ctx context.Context, batchID int64, anchor string) error {
+	start := time.Now()
+	_, err := DB.ExecContext(ctx,
+		`DELETE FROM batch_anchors WHERE batch_id = ? AND anchor = ? AND status = ?`,
+		batchID, anchor, AnchorPending)
+	metrics.ObserveDB("DeletePendingAnchor", start, err)
+	if err != nil {
+		return fmt.Errorf("DeletePendingAnchor failed: %w", err)
+	}
+	return nil
+}
+
+// GetDueAnchors возвращает до limit ожидающих публикаций в anchor, время которых пришло.
+func GetDueAnchors(ctx context.Context, anchor string, now time.Time, limit int) ([]*BatchAnchor, error) {
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+anchorColumns+` FROM batch_anchors
+         WHERE anchor = ? AND status = ? AND next_attempt_at <= ?
+         ORDER BY next_attempt_at LIMIT ?`,
+		anchor, AnchorPending, now, limit)
+	metrics.ObserveDB("GetDueAnchors", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetDueAnchors query: %w", err)
+	}
+	anchors, err := scanAnchors(rows)
+	if err != nil {
+		return nil, fmt.Errorf("GetDueAnchors scan: %w", err)
+	}
+	return anchors, nil
+}
+
+// GetBatchAnchors возвращает публикации батча во всех anchor.
+func GetBatchAnchors(ctx context.Context, batchID int64) ([]*BatchAnchor, error) {
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+anchorColumns+` FROM batch_anchors WHERE batch_id = ? ORDER BY anchor`, batchID)
+	metrics.ObserveDB("GetBatchAnchors", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetBatchAnchors query: %w", err)
+	}
+	anchors, err := scanAnchors(rows)
+	if err != nil {
+		return nil, fmt.Errorf("GetBatchAnchors scan: %w", err)
+	}
+	return anchors, nil
+}
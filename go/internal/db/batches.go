@@ -0,0 +1,240 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"veriChat/go/internal/metrics"
+)
+
+const batchColumns = `batch_id, chat_id, root_hash, from_message_id, to_message_id, tree_version, hash_alg, chat_size, chat_root, prev_batch_hash, created_at`
+
+func scanBatch(row rowScanner) (*MerkleBatch, error) {
+	b := &MerkleBatch{}
+	err := row.Scan(&b.BatchID, &b.ChatID, &b.RootHash, &b.FromMessageID, &b.ToMessageID,
+		&b.TreeVersion, &b.HashAlg, &b.ChatSize, &b.ChatRoot, &b.PrevBatchHash, &b.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+const messageColumns = `message_id, chat_id, user_id, payload, payload_hash, created_at, batch_id`
+
+func scanMessage(row rowScanner) (*Message, error) {
+	m := &Message{}
+	err := row.Scan(&m.MessageID, &m.ChatID, &m.UserID, &m.Payload, &m.PayloadHash, &m.CreatedAt, &m.BatchID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetMerkleBatch возвращает батч или nil, если его нет.
+func GetMerkleBatch(ctx context.Context, batchID int64) (*MerkleBatch, error) {
+	start := time.Now()
+	b, err := scanBatch(DB.QueryRowContext(ctx,
+		`SELECT `+batchColumns+` FROM merkle_batches WHERE batch_id = ?`, batchID))
+	metrics.ObserveDB("GetMerkleBatch", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetMerkleBatch failed: %w", err)
+	}
+	return b, nil
+}
+
+// GetMerkleBatchByRoot возвращает первый батч чата с root_hash = root или nil, если такого нет.
+func GetMerkleBatchByRoot(ctx context.Context, chatID int64, root []byte) (*MerkleBatch, error) {
+	start := time.Now()
+	b, err := scanBatch(DB.QueryRowContext(ctx,
+		`SELECT `+batchColumns+` FROM merkle_batches WHERE chat_id = ? AND root_hash = ? ORDER BY batch_id LIMIT 1`, chatID, root))
+	metrics.ObserveDB("GetMerkleBatchByRoot", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetMerkleBatchByRoot failed: %w", err)
+	}
+	return b, nil
+}
+
+// GetLastMerkleBatch возвращает последний батч чата или nil, если батчей нет.
+func GetLastMerkleBatch(ctx context.Context, chatID int64) (*MerkleBatch, error) {
+	start := time.Now()
+	b, err := scanBatch(DB.QueryRowContext(ctx,
+		`SELECT `+batchColumns+` FROM merkle_batches WHERE chat_id = ? ORDER BY batch_id DESC LIMIT 1`, chatID))
+	metrics.ObserveDB("GetLastMerkleBatch", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetLastMerkleBatch failed: %w", err)
+	}
+	return b, nil
+}
+
+// GetLastMerkleBatchTx возвращает последний батч чата в рамках tx или nil, если батчей нет.
+func GetLastMerkleBatchTx(ctx context.Context, tx *sql.Tx, chatID int64) (*MerkleBatch, error) {
+	start := time.Now()
+	b, err := scanBatch(tx.QueryRowContext(ctx,
+		`SELECT `+batchColumns+` FROM merkle_batches WHERE chat_id = ? ORDER BY batch_id DESC LIMIT 1`, chatID))
+	metrics.ObserveDB("GetLastMerkleBatchTx", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetLastMerkleBatchTx failed: %w", err)
+	}
+	return b, nil
+}
+
+// GetChatBatches возвращает все батчи чата по возрастанию batch_id.
+func GetChatBatches(ctx context.Context, chatID int64) ([]*MerkleBatch, error) {
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+batchColumns+` FROM merkle_batches WHERE chat_id = ? ORDER BY batch_id`, chatID)
+	metrics.ObserveDB("GetChatBatches", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetChatBatches query: %w", err)
+	}
+	defer rows.Close()
+
+	var batches []*MerkleBatch
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("GetChatBatches scan: %w", err)
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+// GetMessage возвращает сообщение или nil, если его нет.
+func GetMessage(ctx context.Context, messageID int64) (*Message, error) {
+	start := time.Now()
+	m, err := scanMessage(DB.QueryRowContext(ctx,
+		`SELECT `+messageColumns+` FROM messages WHERE message_id = ?`, messageID))
+	metrics.ObserveDB("GetMessage", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetMessage failed: %w", err)
+	}
+	return m, nil
+}
+
+// GetBatchMessageIndex позиция сообщения в дереве батча (листья идут по message_id).
+func GetBatchMessageIndex(ctx context.Context, batchID, messageID int64) (int, error) {
+	start := time.Now()
+	var index int
+	err := DB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM messages WHERE batch_id = ? AND message_id < ?`, batchID, messageID,
+	).Scan(&index)
+	metrics.ObserveDB("GetBatchMessageIndex", start, err)
+	if err != nil {
+		return 0, fmt.Errorf("GetBatchMessageIndex failed: %w", err)
+	}
+	return index, nil
+}
+
+// GetBatchPayloads возвращает payloads сообщений батча в порядке листьев дерева.
+func GetBatchPayloads(ctx context.Context, batchID int64) ([][]byte, error) {
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT payload FROM messages WHERE batch_id = ? ORDER BY message_id`, batchID)
+	metrics.ObserveDB("GetBatchPayloads", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetBatchPayloads query: %w", err)
+	}
+	defer rows.Close()
+
+	var payloads [][]byte
+	for rows.Next() {
+		var p []byte
+		if err := rows.Scan(&p); err != nil {
+			return nil, fmt.Errorf("GetBatchPayloads scan: %w", err)
+		}
+		payloads = append(payloads, p)
+	}
+	return payloads, rows.Err()
+}
+
+// GetBatchMessageIDs возвращает message_id батча в порядке листьев дерева.
+func GetBatchMessageIDs(ctx context.Context, batchID int64) ([]int64, error) {
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT message_id FROM messages WHERE batch_id = ? ORDER BY message_id`, batchID)
+	metrics.ObserveDB("GetBatchMessageIDs", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetBatchMessageIDs query: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("GetBatchMessageIDs scan: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ListChatBatches возвращает до limit батчей чата после batch_id afterID
+// (0 - с начала) по возрастанию batch_id, при desc - по убыванию.
+func ListChatBatches(ctx context.Context, chatID, afterID int64, desc bool, limit int) ([]*MerkleBatch, error) {
+	where, order := "", "ASC"
+	args := []any{chatID}
+	if desc {
+		order = "DESC"
+	}
+	if afterID != 0 {
+		if desc {
+			where = " AND batch_id < ?"
+		} else {
+			where = " AND batch_id > ?"
+		}
+		args = append(args, afterID)
+	}
+	args = append(args, limit)
+
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+batchColumns+` FROM merkle_batches WHERE chat_id = ?`+where+`
+         ORDER BY batch_id `+order+` LIMIT ?`, args...)
+	metrics.ObserveDB("ListChatBatches", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("ListChatBatches query: %w", err)
+	}
+	defer rows.Close()
+
+	var batches []*MerkleBatch
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListChatBatches scan: %w", err)
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+// GetBatchMessages возвращает сообщения батча в порядке листьев дерева.
+func GetBatchMessages(ctx context.Context, batchID int64) ([]*Message, error) {
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+messageColumns+` FROM messages WHERE batch_id = ? ORDER BY message_id`, batchID)
+	metrics.ObserveDB("GetBatchMessages", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetBatchMessages query: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("GetBatchMessages scan: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
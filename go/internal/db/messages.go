@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"veriChat/go/internal/metrics"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Rejected сообщает, что ошибку вернул сам сервер MySQL: запрос вне транзакции
+// точно не выполнен. Обрыв соединения или отмена ctx так не считаются - запрос
+// мог успеть закоммититься.
+func Rejected(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr)
+}
+
+// ChatMessagesQuery - страница истории чата. Сообщения идут в порядке индекса
+// idx_chat_time: (created_at, message_id), по возрастанию или при Desc по убыванию.
+type ChatMessagesQuery struct {
+	ChatID int64
+	UserID int64     // 0 - все пользователи
+	From   time.Time // created_at >= From, нулевое - без ограничения
+	To     time.Time // created_at < To, нулевое - без ограничения
+	After  *Message  // курсор: страница начинается после этого сообщения (в порядке выдачи)
+	Desc   bool
+	Limit  int
+}
+
+// ListChatMessages возвращает страницу сообщений чата.
+func ListChatMessages(ctx context.Context, q ChatMessagesQuery) ([]*Message, error) {
+	where := []string{"chat_id = ?"}
+	args := []any{q.ChatID}
+	if q.UserID != 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, q.UserID)
+	}
+	if !q.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, q.To)
+	}
+	cmp, order := ">", "ASC"
+	if q.Desc {
+		cmp, order = "<", "DESC"
+	}
+	if q.After != nil {
+		where = append(where, fmt.Sprintf("(created_at %s ? OR (created_at = ? AND message_id %s ?))", cmp, cmp))
+		args = append(args, q.After.CreatedAt, q.After.CreatedAt, q.After.MessageID)
+	}
+	args = append(args, q.Limit)
+
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT `+messageColumns+` FROM messages
+         WHERE `+strings.Join(where, " AND ")+`
+         ORDER BY created_at `+order+`, message_id `+order+` LIMIT ?`, args...)
+	metrics.ObserveDB("ListChatMessages", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("ListChatMessages query: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListChatMessages scan: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejected(t *testing.T) {
+	dup := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", dup, true},
+		{"wrapped server error", fmt.Errorf("insert message failed: %w", dup), true},
+		// ответ не дошел: вставка могла закоммититься
+		{"bad conn", driver.ErrBadConn, false},
+		{"unexpected eof", io.ErrUnexpectedEOF, false},
+		{"canceled", context.Canceled, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Rejected(tc.err))
+		})
+	}
+}
@@ -18,5 +18,87 @@ type MerkleBatch struct {
     RootHash      []byte
     FromMessageID int64
     ToMessageID   int64
+    TreeVersion   int // версия правил дерева (ENGINE_TREE_* в engine.h)
+    HashAlg       int // алгоритм хеширования (ENGINE_HASH_* в engine.h)
+    ChatSize      *int64  // размер истории чата после батча (nil для старых записей)
+    ChatRoot      []byte  // root истории чата после батча
+    PrevBatchHash []byte  // verify.BatchHash предыдущего батча чата (nil для старых записей)
     CreatedAt     time.Time
 }
+
+type Chat struct {
+    ChatID    int64
+    HashAlg   int
+    CreatedAt time.Time
+}
+
+// ChatAccumulator - пики MMR истории чата
+type ChatAccumulator struct {
+    ChatID      int64
+    Size        int64
+    Peaks       []byte // пики по 32 байта подряд
+    Root        []byte
+    HashAlg     int
+    LastBatchID int64
+    UpdatedAt   time.Time
+}
+
+// SigningKey - Ed25519 ключ подписи tree head. Активен ровно один (retired_at IS NULL),
+// выведенные из ротации остаются для проверки старых подписей.
+type SigningKey struct {
+    KeyID       string
+    PublicKey   []byte
+    ActivatedAt time.Time
+    RetiredAt   *time.Time
+}
+
+// SignedTreeHead - подписанное состояние чата после батча (verify.SignedTreeHead)
+type SignedTreeHead struct {
+    BatchID     int64
+    ChatID      int64
+    TreeSize    int64
+    Root        []byte // root истории чата
+    BatchRoot   []byte
+    TimestampMs int64 // подписанное время, миллисекунды Unix
+    KeyID       string
+    Signature   []byte
+}
+
+// BatchTimestamp - метка времени root батча от TSA (tsa.Authority)
+type BatchTimestamp struct {
+    BatchID   int64
+    Authority string
+    Token     []byte // DER токена (verify.ParseTimestampToken)
+    GenTime   time.Time
+    CreatedAt time.Time
+}
+
+// PendingTimestamp - метка времени батча, которую еще предстоит получить у TSA
+type PendingTimestamp struct {
+    BatchID       int64
+    Attempts      int
+    LastError     string
+    NextAttemptAt time.Time
+}
+
+// BatchAnchor - публикация батча в одном anchor.Anchor: квитанция или состояние повторов
+type BatchAnchor struct {
+    BatchID       int64
+    Anchor        string
+    Status        string // pending, done
+    Attempts      int
+    LastError     string
+    Reference     string
+    Receipt       []byte
+    NextAttemptAt time.Time
+    AnchoredAt    *time.Time
+}
+
+// Cosignature - подпись свидетеля под tree head батча (verify.Cosignature)
+type Cosignature struct {
+    BatchID      int64
+    WitnessKeyID string
+    TimestampMs  int64 // время проверки свидетелем, миллисекунды Unix
+    Signature    []byte
+    CreatedAt    time.Time
+}
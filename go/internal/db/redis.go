@@ -3,6 +3,8 @@ package db
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 	"veriChat/go/internal/metrics"
 
@@ -19,43 +21,194 @@ func InitRedis(addr, pass string, db int) {
     })
 }
 
+// IdempotencyRecord - состояние Idempotency-Key: fingerprint запроса и message_id
+// (0, пока запрос с этим ключом еще выполняется)
+type IdempotencyRecord struct {
+    MessageID   int64
+    Fingerprint string
+}
+
+func idempotencyKey(userID int64, key string) string {
+    return fmt.Sprintf("idemp:%d:%s", userID, key)
+}
+
+func parseIdempotency(val string) (*IdempotencyRecord, error) {
+    id, fp, ok := strings.Cut(val, ":")
+    if !ok {
+        return nil, fmt.Errorf("malformed idempotency record %q", val)
+    }
+    rec := &IdempotencyRecord{Fingerprint: fp}
+    if id != "pending" {
+        if _, err := fmt.Sscanf(id, "%d", &rec.MessageID); err != nil {
+            return nil, fmt.Errorf("malformed idempotency record %q", val)
+        }
+    }
+    return rec, nil
+}
+
+// ReserveIdempotency резервирует ключ пользователя под запрос с fingerprint на ttl (SET NX).
+// Если ключ уже занят, возвращает false и его запись (nil, если она успела истечь).
+func ReserveIdempotency(ctx context.Context, userID int64, key, fingerprint string, ttl time.Duration) (bool, *IdempotencyRecord, error) {
+    start := time.Now()
+    ok, err := RedisClient.SetNX(ctx, idempotencyKey(userID, key), "pending:"+fingerprint, ttl).Result()
+    metrics.ObserveRedis("ReserveIdempotency", start, err)
+    if err != nil || ok {
+        return ok, nil, err
+    }
+    rec, err := GetIdempotency(ctx, userID, key)
+    return false, rec, err
+}
+
+// refreshIdempotencyScript продлевает ключ, только если в нем все еще резерв этого запроса
+var refreshIdempotencyScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// RefreshIdempotency продлевает резерв ключа с fingerprint еще на ttl. Возвращает false,
+// если резерва уже нет: ключ истек или в нем записан message_id.
+func RefreshIdempotency(ctx context.Context, userID int64, key, fingerprint string, ttl time.Duration) (bool, error) {
+    start := time.Now()
+    n, err := refreshIdempotencyScript.Run(ctx, RedisClient, []string{idempotencyKey(userID, key)}, "pending:"+fingerprint, ttl.Milliseconds()).Int()
+    metrics.ObserveRedis("RefreshIdempotency", start, err)
+    return n == 1, err
+}
+
 // SetIdempotency сохраняем idempotency key
-func SetIdempotency(ctx context.Context, key string, messageID int64, ttl time.Duration) error {
+func SetIdempotency(ctx context.Context, userID int64, key, fingerprint string, messageID int64, ttl time.Duration) error {
     start := time.Now()
-    err := RedisClient.Set(ctx, "idemp:"+key, messageID, ttl).Err()
-    metrics.ObserveRedis("SetIdempotency", start,err)
+    err := RedisClient.Set(ctx, idempotencyKey(userID, key), fmt.Sprintf("%d:%s", messageID, fingerprint), ttl).Err()
+    metrics.ObserveRedis("SetIdempotency", start, err)
     return err
+}
 
+// ReleaseIdempotency снимает резерв ключа, если запрос не удался
+func ReleaseIdempotency(ctx context.Context, userID int64, key string) error {
+    start := time.Now()
+    err := RedisClient.Del(ctx, idempotencyKey(userID, key)).Err()
+    metrics.ObserveRedis("ReleaseIdempotency", start, err)
+    return err
 }
 
-// GetIdempotency проверка
-func GetIdempotency(ctx context.Context, key string) (int64, bool, error) {
+// GetIdempotency проверка, nil если ключа нет
+func GetIdempotency(ctx context.Context, userID int64, key string) (*IdempotencyRecord, error) {
     start := time.Now()
-    val, err := RedisClient.Get(ctx, "idemp:"+key).Result()
+    val, err := RedisClient.Get(ctx, idempotencyKey(userID, key)).Result()
     metrics.ObserveRedis("GetIdempotency", start,err)
     if err == redis.Nil {
-        return 0, false, nil
+        return nil, nil
     }
     if err != nil {
-        return 0, false, err
+        return nil, err
     }
-    var id int64
-    fmt.Sscanf(val, "%d", &id)
-    return id, true, nil
+    return parseIdempotency(val)
+}
+
+// LatestRoot - root последнего батча чата и состояние истории чата после него
+type LatestRoot struct {
+    ChatID   int64
+    BatchID  int64
+    Root     []byte
+    ChatSize *int64 // nil для батчей до chat_accumulators
+    ChatRoot []byte
 }
 
-// SetLatestRoot
-func SetLatestRoot(ctx context.Context, chatID int64, root []byte) error {
+func latestBatchKey(chatID int64) string {
+    return fmt.Sprintf("chat:%d:latest_batch", chatID)
+}
+
+// SetLatestBatch кеширует LatestRoot чата одним hash
+func SetLatestBatch(ctx context.Context, r *LatestRoot) error {
+    fields := map[string]any{
+        "batch_id":  r.BatchID,
+        "root":      r.Root,
+        "chat_root": r.ChatRoot,
+    }
+    if r.ChatSize != nil {
+        fields["chat_size"] = *r.ChatSize
+    }
     start := time.Now()
-    err := RedisClient.Set(ctx, fmt.Sprintf("chat:%d:latest_root", chatID), root, 0).Err()
-    metrics.ObserveRedis("SetLatestRoot",start,err)
-    return  err
+    err := RedisClient.HSet(ctx, latestBatchKey(r.ChatID), fields).Err()
+    metrics.ObserveRedis("SetLatestBatch", start, err)
+    return err
 }
 
-// GetLatestRoot
-func GetLatestRoot(ctx context.Context, chatID int64) ([]byte, error) {
+// DeleteLatestBatch убирает LatestRoot чата из кеша
+func DeleteLatestBatch(ctx context.Context, chatID int64) error {
     start := time.Now()
-    arr, err := RedisClient.Get(ctx, fmt.Sprintf("chat:%d:latest_root", chatID)).Bytes()
-    metrics.ObserveRedis("GetLatestRoot", start,err)
-    return arr,err
+    err := RedisClient.Del(ctx, latestBatchKey(chatID)).Err()
+    metrics.ObserveRedis("DeleteLatestBatch", start, err)
+    return err
+}
+
+// GetLatestBatch возвращает LatestRoot чата из кеша, nil если его там нет
+func GetLatestBatch(ctx context.Context, chatID int64) (*LatestRoot, error) {
+    start := time.Now()
+    fields, err := RedisClient.HGetAll(ctx, latestBatchKey(chatID)).Result()
+    metrics.ObserveRedis("GetLatestBatch", start, err)
+    if err != nil {
+        return nil, err
+    }
+    if len(fields) == 0 {
+        return nil, nil
+    }
+    r := &LatestRoot{ChatID: chatID, Root: []byte(fields["root"]), ChatRoot: []byte(fields["chat_root"])}
+    if r.BatchID, err = strconv.ParseInt(fields["batch_id"], 10, 64); err != nil {
+        return nil, fmt.Errorf("malformed latest batch of chat %d: %w", chatID, err)
+    }
+    if v, ok := fields["chat_size"]; ok {
+        size, err := strconv.ParseInt(v, 10, 64)
+        if err != nil {
+            return nil, fmt.Errorf("malformed latest batch of chat %d: %w", chatID, err)
+        }
+        r.ChatSize = &size
+    }
+    if len(r.ChatRoot) == 0 {
+        r.ChatRoot = nil
+    }
+    return r, nil
+}
+
+// SetBatchTree кеширует сериализованное дерево батча
+func SetBatchTree(ctx context.Context, batchID int64, tree []byte, ttl time.Duration) error {
+    start := time.Now()
+    err := RedisClient.Set(ctx, fmt.Sprintf("batch:%d:tree", batchID), tree, ttl).Err()
+    metrics.ObserveRedis("SetBatchTree", start, err)
+    return err
+}
+
+// GetBatchTree возвращает дерево батча из кеша, nil если его там нет
+func GetBatchTree(ctx context.Context, batchID int64) ([]byte, error) {
+    start := time.Now()
+    data, err := RedisClient.Get(ctx, fmt.Sprintf("batch:%d:tree", batchID)).Bytes()
+    metrics.ObserveRedis("GetBatchTree", start, err)
+    if err == redis.Nil {
+        return nil, nil
+    }
+    return data, err
+}
+
+// BatchesChannel - канал Redis pub/sub, куда после коммита публикуется "chat_id:batch_id"
+const BatchesChannel = "batches:committed"
+
+// PublishBatchCommitted сообщает подписчикам всех экземпляров сервиса о новом батче
+func PublishBatchCommitted(ctx context.Context, chatID, batchID int64) error {
+    start := time.Now()
+    err := RedisClient.Publish(ctx, BatchesChannel, fmt.Sprintf("%d:%d", chatID, batchID)).Err()
+    metrics.ObserveRedis("PublishBatchCommitted", start, err)
+    return err
+}
+
+// SubscribeBatches подписка на BatchesChannel; подписка активна после первого Receive
+func SubscribeBatches(ctx context.Context) *redis.PubSub {
+    return RedisClient.Subscribe(ctx, BatchesChannel)
+}
+
+// ParseBatchCommitted разбирает сообщение BatchesChannel
+func ParseBatchCommitted(payload string) (chatID, batchID int64, err error) {
+    if _, err := fmt.Sscanf(payload, "%d:%d", &chatID, &batchID); err != nil {
+        return 0, 0, fmt.Errorf("malformed batch notification %q", payload)
+    }
+    return chatID, batchID, nil
 }
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIdempotency(t *testing.T) {
+	cases := []struct {
+		val  string
+		want *IdempotencyRecord
+	}{
+		{"pending:abc", &IdempotencyRecord{Fingerprint: "abc"}},
+		{"42:abc", &IdempotencyRecord{MessageID: 42, Fingerprint: "abc"}},
+		// fingerprint - hex, но запись делится только по первому ':'
+		{"7:a:b", &IdempotencyRecord{MessageID: 7, Fingerprint: "a:b"}},
+		{"pending:", &IdempotencyRecord{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.val, func(t *testing.T) {
+			rec, err := parseIdempotency(tc.val)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, rec)
+		})
+	}
+
+	for _, val := range []string{"", "42", "abc:fp", "pending"} {
+		t.Run("malformed "+val, func(t *testing.T) {
+			_, err := parseIdempotency(val)
+			assert.Error(t, err)
+		})
+	}
+}
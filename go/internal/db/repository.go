@@ -26,9 +26,9 @@ func InsertMessage(ctx context.Context, msg *Message) (int64, error) {
 func InsertMerkleBatch(ctx context.Context, batch *MerkleBatch) (int64, error) {
 	start := time.Now()
     res, err := DB.ExecContext(ctx,
-        `INSERT INTO merkle_batches (chat_id, root_hash, from_message_id, to_message_id)
-         VALUES (?, ?, ?, ?)`,
-        batch.ChatID, batch.RootHash, batch.FromMessageID, batch.ToMessageID,
+        `INSERT INTO merkle_batches (chat_id, root_hash, from_message_id, to_message_id, tree_version, hash_alg, chat_size, chat_root, prev_batch_hash)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        batch.ChatID, batch.RootHash, batch.FromMessageID, batch.ToMessageID, batch.TreeVersion, batch.HashAlg, batch.ChatSize, batch.ChatRoot, batch.PrevBatchHash,
     )
 	metrics.ObserveDB("InsertMerkleBatch", start,err)
     if err != nil {
@@ -38,55 +38,116 @@ func InsertMerkleBatch(ctx context.Context, batch *MerkleBatch) (int64, error) {
 }
 
 
-// GetMessagePayloads возвращает payloads и их хешы по списку message_id.
-// Возвращает slice в том порядке, в котором были переданы ids (если id не найден nil в соответствующей позиции).
-func GetMessagePayloads(ctx context.Context, ids []int64) ([][]byte, [][]byte, error) {
-	if len(ids) == 0 {
-		return nil, nil, nil
-	}
+// queryer - *sql.DB или *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
 
+// pendingMessagesQuery выбирает сообщения ids, еще не попавшие в батч, в порядке message_id
+func pendingMessagesQuery(columns string, ids []int64, forUpdate bool) (string, []any) {
 	placeholders := make([]string, len(ids))
-	args := make([]interface{}, len(ids))
+	args := make([]any, len(ids))
 	for i, id := range ids {
 		placeholders[i] = "?"
 		args[i] = id
 	}
-	query := fmt.Sprintf(`SELECT message_id, payload, payload_hash FROM messages WHERE message_id IN (%s)`, strings.Join(placeholders, ","))
-	rows, err := DB.QueryContext(ctx, query, args...)
+	query := fmt.Sprintf(`SELECT %s FROM messages WHERE message_id IN (%s) AND batch_id IS NULL ORDER BY message_id`,
+		columns, strings.Join(placeholders, ","))
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	return query, args
+}
+
+// streamMessagePayloads - общая часть StreamMessagePayloads и StreamMessagePayloadsTx
+func streamMessagePayloads(ctx context.Context, q queryer, name string, forUpdate bool, ids []int64, fn func(messageID int64, payload, hash []byte) error) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args := pendingMessagesQuery(`message_id, payload, payload_hash`, ids, forUpdate)
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args...)
+	metrics.ObserveDB(name, start, err)
 	if err != nil {
-		return nil, nil, fmt.Errorf("GetMessagePayloads query: %w", err)
+		return fmt.Errorf("%s query: %w", name, err)
 	}
 	defer rows.Close()
 
-	// Собираем в мапу, потом распакуем в порядок ids
-	payloadMap := make(map[int64][]byte)
-	hashMap := make(map[int64][]byte)
+	count := 0
 	for rows.Next() {
 		var id int64
-		var payload []byte
-		var hash []byte
+		var payload, hash sql.RawBytes
 		if err := rows.Scan(&id, &payload, &hash); err != nil {
-			return nil, nil, fmt.Errorf("GetMessagePayloads scan: %w", err)
+			return fmt.Errorf("%s scan: %w", name, err)
 		}
-		payloadMap[id] = payload
-		hashMap[id] = hash
+		if err := fn(id, payload, hash); err != nil {
+			return err
+		}
+		count++
 	}
-	payloads := make([][]byte, len(ids))
-	hashes := make([][]byte, len(ids))
-	for i, id := range ids {
-		payloads[i] = payloadMap[id]
-		hashes[i] = hashMap[id]
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s rows: %w", name, err)
+	}
+	if count != len(ids) {
+		return fmt.Errorf("%s: found %d of %d pending messages", name, count, len(ids))
+	}
+	return nil
+}
+
+// StreamMessagePayloadsTx читает сообщения ids курсором в порядке message_id и
+// вызывает fn для каждого. payload и hash действительны только внутри fn, поэтому
+// в памяти одновременно лежит одно сообщение. Строки блокируются до конца tx;
+// если хоть одного сообщения нет или оно уже в батче - ошибка.
+func StreamMessagePayloadsTx(ctx context.Context, tx *sql.Tx, ids []int64, fn func(messageID int64, payload, hash []byte) error) error {
+	return streamMessagePayloads(ctx, tx, "StreamMessagePayloadsTx", true, ids, fn)
+}
+
+// StreamMessagePayloads - StreamMessagePayloadsTx вне транзакции, без блокировки строк
+func StreamMessagePayloads(ctx context.Context, ids []int64, fn func(messageID int64, payload, hash []byte) error) error {
+	return streamMessagePayloads(ctx, DB, "StreamMessagePayloads", false, ids, fn)
+}
+
+// GetPendingHashesTx возвращает payload_hash сообщений ids в порядке message_id,
+// блокируя строки до конца tx. Если хоть одного сообщения нет или оно уже в батче - ошибка.
+func GetPendingHashesTx(ctx context.Context, tx *sql.Tx, ids []int64) ([][]byte, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query, args := pendingMessagesQuery(`payload_hash`, ids, true)
+	start := time.Now()
+	rows, err := tx.QueryContext(ctx, query, args...)
+	metrics.ObserveDB("GetPendingHashesTx", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetPendingHashesTx query: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := make([][]byte, 0, len(ids))
+	for rows.Next() {
+		var hash []byte
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("GetPendingHashesTx scan: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetPendingHashesTx rows: %w", err)
 	}
-	return payloads, hashes, nil
+	if len(hashes) != len(ids) {
+		return nil, fmt.Errorf("GetPendingHashesTx: found %d of %d pending messages", len(hashes), len(ids))
+	}
+	return hashes, nil
 }
 
 // InsertMerkleBatchTx вставляет запись merkle_batches в рамках tx и возвращает batch_id.
 func InsertMerkleBatchTx(ctx context.Context, tx *sql.Tx, batch *MerkleBatch) (int64, error) {
 	start := time.Now()
 	res, err := tx.ExecContext(ctx,
-		`INSERT INTO merkle_batches (chat_id, root_hash, from_message_id, to_message_id)
-         VALUES (?, ?, ?, ?)`,
-		batch.ChatID, batch.RootHash, batch.FromMessageID, batch.ToMessageID,
+		`INSERT INTO merkle_batches (chat_id, root_hash, from_message_id, to_message_id, tree_version, hash_alg, chat_size, chat_root, prev_batch_hash)
+         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		batch.ChatID, batch.RootHash, batch.FromMessageID, batch.ToMessageID, batch.TreeVersion, batch.HashAlg, batch.ChatSize, batch.ChatRoot, batch.PrevBatchHash,
 	)
 	metrics.ObserveDB("InsertMerkleBatchTx", start,err)
 	if err != nil {
@@ -125,4 +186,27 @@ func UpdateMessagesBatchIDTx(ctx context.Context, tx *sql.Tx, messageIDs []int64
 		return fmt.Errorf("UpdateMessagesBatchIDTx failed: %w", err)
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// EnsureChat создает запись чата с алгоритмом hashAlg, если ее еще нет,
+// и возвращает алгоритм, закрепленный за чатом.
+func EnsureChat(ctx context.Context, chatID int64, hashAlg int) (int, error) {
+	start := time.Now()
+	_, err := DB.ExecContext(ctx,
+		`INSERT IGNORE INTO chats (chat_id, hash_alg) VALUES (?, ?)`,
+		chatID, hashAlg,
+	)
+	metrics.ObserveDB("EnsureChat", start, err)
+	if err != nil {
+		return 0, fmt.Errorf("EnsureChat insert: %w", err)
+	}
+
+	start = time.Now()
+	var alg int
+	err = DB.QueryRowContext(ctx, `SELECT hash_alg FROM chats WHERE chat_id = ?`, chatID).Scan(&alg)
+	metrics.ObserveDB("GetChatHashAlg", start, err)
+	if err != nil {
+		return 0, fmt.Errorf("EnsureChat select: %w", err)
+	}
+	return alg, nil
+}
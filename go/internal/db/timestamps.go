@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"veriChat/go/internal/metrics"
+)
+
+// InsertBatchTimestamp сохраняет токен метки времени батча и снимает ожидающую
+// метку из batch_timestamp_jobs. Повторная метка для того же батча не перезаписывает первую.
+func InsertBatchTimestamp(ctx context.Context, ts *BatchTimestamp) error {
+	start := time.Now()
+	err := func() error {
+		tx, err := DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx,
+			`INSERT IGNORE INTO batch_timestamps (batch_id, authority, token, gen_time) VALUES (?, ?, ?, ?)`,
+			ts.BatchID, ts.Authority, ts.Token, ts.GenTime); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM batch_timestamp_jobs WHERE batch_id = ?`, ts.BatchID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}()
+	metrics.ObserveDB("InsertBatchTimestamp", start, err)
+	if err != nil {
+		return fmt.Errorf("InsertBatchTimestamp failed: %w", err)
+	}
+	return nil
+}
+
+// InsertPendingTimestampTx заводит ожидающую метку времени батча в рамках tx,
+// чтобы батч не остался без метки, если TSA недоступна или процесс упадет сразу после коммита.
+func InsertPendingTimestampTx(ctx context.Context, tx *sql.Tx, batchID int64, nextAttemptAt time.Time) error {
+	start := time.Now()
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO batch_timestamp_jobs (batch_id, next_attempt_at) VALUES (?, ?)`, batchID, nextAttemptAt)
+	metrics.ObserveDB("InsertPendingTimestampTx", start, err)
+	if err != nil {
+		return fmt.Errorf("InsertPendingTimestampTx failed: %w", err)
+	}
+	return nil
+}
+
+// MarkTimestampFailed откладывает следующую попытку получить метку времени до nextAttemptAt.
+func MarkTimestampFailed(ctx context.Context, batchID int64, lastError string, nextAttemptAt time.Time) error {
+	start := time.Now()
+	_, err := DB.ExecContext(ctx,
+		`UPDATE batch_timestamp_jobs SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+         WHERE batch_id = ?`,
+		lastError, nextAttemptAt, batchID)
+	metrics.ObserveDB("MarkTimestampFailed", start, err)
+	if err != nil {
+		return fmt.Errorf("MarkTimestampFailed failed: %w", err)
+	}
+	return nil
+}
+
+// DeletePendingTimestamp снимает ожидающую метку времени без токена (батч не найден).
+func DeletePendingTimestamp(ctx context.Context, batchID int64) error {
+	start := time.Now()
+	_, err := DB.ExecContext(ctx, `DELETE FROM batch_timestamp_jobs WHERE batch_id = ?`, batchID)
+	metrics.ObserveDB("DeletePendingTimestamp", start, err)
+	if err != nil {
+		return fmt.Errorf("DeletePendingTimestamp failed: %w", err)
+	}
+	return nil
+}
+
+// GetDueTimestamps возвращает до limit ожидающих меток времени, время которых пришло.
+func GetDueTimestamps(ctx context.Context, now time.Time, limit int) ([]*PendingTimestamp, error) {
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT batch_id, attempts, last_error, next_attempt_at FROM batch_timestamp_jobs
+         WHERE next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?`,
+		now, limit)
+	metrics.ObserveDB("GetDueTimestamps", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetDueTimestamps query: %w", err)
+	}
+	defer rows.Close()
+	var pending []*PendingTimestamp
+	for rows.Next() {
+		p := &PendingTimestamp{}
+		if err := rows.Scan(&p.BatchID, &p.Attempts, &p.LastError, &p.NextAttemptAt); err != nil {
+			return nil, fmt.Errorf("GetDueTimestamps scan: %w", err)
+		}
+		pending = append(pending, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetDueTimestamps scan: %w", err)
+	}
+	return pending, nil
+}
+
+// GetBatchTimestamp возвращает метку времени батча или nil, если ее нет.
+func GetBatchTimestamp(ctx context.Context, batchID int64) (*BatchTimestamp, error) {
+	start := time.Now()
+	ts := &BatchTimestamp{}
+	err := DB.QueryRowContext(ctx,
+		`SELECT batch_id, authority, token, gen_time, created_at FROM batch_timestamps WHERE batch_id = ?`, batchID).
+		Scan(&ts.BatchID, &ts.Authority, &ts.Token, &ts.GenTime, &ts.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+		ts = nil
+	}
+	metrics.ObserveDB("GetBatchTimestamp", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetBatchTimestamp failed: %w", err)
+	}
+	return ts, nil
+}
+
+// ActivateTSAKey делает ключ локальной TSA активным, остальные ключи TSA выводятся из ротации.
+// Ключи TSA хранятся отдельно от ключей подписи tree head: токен проверяется только ими.
+func ActivateTSAKey(ctx context.Context, keyID string, publicKey []byte) error {
+	return activateKey(ctx, "ActivateTSAKey", "tsa_keys", keyID, publicKey)
+}
+
+// ListTSAKeys возвращает все ключи локальной TSA по времени активации.
+func ListTSAKeys(ctx context.Context) ([]*SigningKey, error) {
+	return listKeys(ctx, "ListTSAKeys", "tsa_keys")
+}
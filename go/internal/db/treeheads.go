@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"veriChat/go/internal/metrics"
+)
+
+const treeHeadColumns = `batch_id, chat_id, tree_size, root, batch_root, timestamp_ms, key_id, signature`
+
+func scanTreeHead(row rowScanner) (*SignedTreeHead, error) {
+	h := &SignedTreeHead{}
+	err := row.Scan(&h.BatchID, &h.ChatID, &h.TreeSize, &h.Root, &h.BatchRoot, &h.TimestampMs, &h.KeyID, &h.Signature)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// InsertSignedTreeHeadTx сохраняет подписанный head батча в рамках tx.
+func InsertSignedTreeHeadTx(ctx context.Context, tx *sql.Tx, h *SignedTreeHead) error {
+	start := time.Now()
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO signed_tree_heads (`+treeHeadColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		h.BatchID, h.ChatID, h.TreeSize, h.Root, h.BatchRoot, h.TimestampMs, h.KeyID, h.Signature)
+	metrics.ObserveDB("InsertSignedTreeHeadTx", start, err)
+	if err != nil {
+		return fmt.Errorf("InsertSignedTreeHeadTx failed: %w", err)
+	}
+	return nil
+}
+
+// GetLatestSignedTreeHead возвращает head последнего подписанного батча чата или nil.
+func GetLatestSignedTreeHead(ctx context.Context, chatID int64) (*SignedTreeHead, error) {
+	start := time.Now()
+	h, err := scanTreeHead(DB.QueryRowContext(ctx,
+		`SELECT `+treeHeadColumns+` FROM signed_tree_heads WHERE chat_id = ? ORDER BY batch_id DESC LIMIT 1`, chatID))
+	metrics.ObserveDB("GetLatestSignedTreeHead", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetLatestSignedTreeHead failed: %w", err)
+	}
+	return h, nil
+}
+
+// GetSignedTreeHead возвращает head батча или nil, если батч не подписан.
+func GetSignedTreeHead(ctx context.Context, batchID int64) (*SignedTreeHead, error) {
+	start := time.Now()
+	h, err := scanTreeHead(DB.QueryRowContext(ctx,
+		`SELECT `+treeHeadColumns+` FROM signed_tree_heads WHERE batch_id = ?`, batchID))
+	metrics.ObserveDB("GetSignedTreeHead", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetSignedTreeHead failed: %w", err)
+	}
+	return h, nil
+}
+
+// ActivateSigningKey делает ключ активным: добавляет его в историю (или возвращает
+// ранее выведенный) и выводит из ротации остальные активные ключи.
+func ActivateSigningKey(ctx context.Context, keyID string, publicKey []byte) error {
+	return activateKey(ctx, "ActivateSigningKey", "signing_keys", keyID, publicKey)
+}
+
+// ListSigningKeys возвращает все ключи подписи по времени активации.
+func ListSigningKeys(ctx context.Context) ([]*SigningKey, error) {
+	return listKeys(ctx, "ListSigningKeys", "signing_keys")
+}
+
+// activateKey делает ключ активным в таблице ключей table (signing_keys, tsa_keys)
+func activateKey(ctx context.Context, name, table, keyID string, publicKey []byte) error {
+	start := time.Now()
+	err := func() error {
+		tx, err := DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO `+table+` (key_id, public_key) VALUES (?, ?)
+             ON DUPLICATE KEY UPDATE
+                 activated_at = IF(retired_at IS NULL, activated_at, CURRENT_TIMESTAMP),
+                 retired_at = NULL`,
+			keyID, publicKey); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE `+table+` SET retired_at = CURRENT_TIMESTAMP WHERE retired_at IS NULL AND key_id <> ?`,
+			keyID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}()
+	metrics.ObserveDB(name, start, err)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
+
+// listKeys возвращает все ключи таблицы table по времени активации
+func listKeys(ctx context.Context, name, table string) ([]*SigningKey, error) {
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT key_id, public_key, activated_at, retired_at FROM `+table+` ORDER BY activated_at, key_id`)
+	metrics.ObserveDB(name, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("%s query: %w", name, err)
+	}
+	defer rows.Close()
+
+	var keys []*SigningKey
+	for rows.Next() {
+		k := &SigningKey{}
+		if err := rows.Scan(&k.KeyID, &k.PublicKey, &k.ActivatedAt, &k.RetiredAt); err != nil {
+			return nil, fmt.Errorf("%s scan: %w", name, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// UpsertCosignature сохраняет подпись свидетеля под head батча. Повторная подпись
+// того же свидетеля заменяет предыдущую.
+func UpsertCosignature(ctx context.Context, c *Cosignature) error {
+	start := time.Now()
+	_, err := DB.ExecContext(ctx,
+		`INSERT INTO batch_cosignatures (batch_id, witness_key_id, timestamp_ms, signature) VALUES (?, ?, ?, ?)
+         ON DUPLICATE KEY UPDATE timestamp_ms = VALUES(timestamp_ms), signature = VALUES(signature)`,
+		c.BatchID, c.WitnessKeyID, c.TimestampMs, c.Signature)
+	metrics.ObserveDB("UpsertCosignature", start, err)
+	if err != nil {
+		return fmt.Errorf("UpsertCosignature failed: %w", err)
+	}
+	return nil
+}
+
+// GetBatchCosignatures возвращает подписи свидетелей под head батча.
+func GetBatchCosignatures(ctx context.Context, batchID int64) ([]*Cosignature, error) {
+	start := time.Now()
+	rows, err := DB.QueryContext(ctx,
+		`SELECT batch_id, witness_key_id, timestamp_ms, signature, created_at
+         FROM batch_cosignatures WHERE batch_id = ? ORDER BY witness_key_id`, batchID)
+	metrics.ObserveDB("GetBatchCosignatures", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetBatchCosignatures query: %w", err)
+	}
+	defer rows.Close()
+
+	var cosigs []*Cosignature
+	for rows.Next() {
+		c := &Cosignature{}
+		if err := rows.Scan(&c.BatchID, &c.WitnessKeyID, &c.TimestampMs, &c.Signature, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("GetBatchCosignatures scan: %w", err)
+		}
+		cosigs = append(cosigs, c)
+	}
+	return cosigs, rows.Err()
+}
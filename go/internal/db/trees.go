@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"veriChat/go/internal/metrics"
+)
+
+// execer - *sql.DB или *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertBatchTree(ctx context.Context, e execer, name string, batchID int64, tree []byte) error {
+	start := time.Now()
+	_, err := e.ExecContext(ctx,
+		`INSERT IGNORE INTO batch_trees (batch_id, tree) VALUES (?, ?)`, batchID, tree)
+	metrics.ObserveDB(name, start, err)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
+
+// InsertBatchTreeTx сохраняет сериализованное дерево батча (verify.Tree.MarshalBinary) в рамках tx
+func InsertBatchTreeTx(ctx context.Context, tx *sql.Tx, batchID int64, tree []byte) error {
+	return insertBatchTree(ctx, tx, "InsertBatchTreeTx", batchID, tree)
+}
+
+// InsertBatchTree сохраняет дерево батча, пересобранное после коммита.
+// Уже сохраненное дерево не перезаписывается.
+func InsertBatchTree(ctx context.Context, batchID int64, tree []byte) error {
+	return insertBatchTree(ctx, DB, "InsertBatchTree", batchID, tree)
+}
+
+// GetStoredBatchTree возвращает сохраненное дерево батча или nil, если его нет.
+func GetStoredBatchTree(ctx context.Context, batchID int64) ([]byte, error) {
+	start := time.Now()
+	var tree []byte
+	err := DB.QueryRowContext(ctx, `SELECT tree FROM batch_trees WHERE batch_id = ?`, batchID).Scan(&tree)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+		tree = nil
+	}
+	metrics.ObserveDB("GetStoredBatchTree", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("GetStoredBatchTree failed: %w", err)
+	}
+	return tree, nil
+}
@@ -0,0 +1,287 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"path"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"veriChat/go/internal/cgobridge"
+	"veriChat/go/internal/db"
+	"veriChat/go/internal/metrics"
+	"veriChat/go/internal/service"
+)
+
+// maxIdempotencyKeyLen - тот же предел, что у Idempotency-Key в HTTP API
+const maxIdempotencyKeyLen = 255
+
+// Service - методы service.MessageService, которые нужны gRPC API
+type Service interface {
+	SubmitMessage(ctx context.Context, chatID, userID int64, payload []byte, idempKey string) (int64, error)
+	GetMessage(ctx context.Context, messageID int64) (*service.MessageInfo, error)
+	ListChatMessages(ctx context.Context, chatID int64, f service.MessageFilter) (*service.MessagePage, error)
+	GetLatestRoot(ctx context.Context, chatID int64) (*db.LatestRoot, error)
+	GetMessageProof(ctx context.Context, messageID int64) (*service.MessageProof, error)
+	WatchBatches(ctx context.Context, chatID, afterBatchID int64, fn func(*db.MerkleBatch) error) error
+}
+
+var _ Service = (*service.MessageService)(nil)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative verichat.proto
+
+// Server - gRPC API (verichat.proto) рядом с HTTP api.Server над тем же сервисом
+type Server struct {
+	UnimplementedVeriChatServer
+
+	addr       string
+	grpcServer *grpc.Server
+	service    Service
+
+	stop     chan struct{} // закрывается в Shutdown: потоки WatchBatches завершаются
+	stopOnce sync.Once
+}
+
+func NewServer(addr string, svc Service) *Server {
+	s := &Server{
+		addr:    addr,
+		service: svc,
+		stop:    make(chan struct{}),
+	}
+	s.grpcServer = grpc.NewServer(grpc.UnaryInterceptor(observeUnary))
+	RegisterVeriChatServer(s.grpcServer, s)
+	return s
+}
+
+func (s *Server) Start() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("gRPC server listening on %s", lis.Addr())
+	return s.Serve(lis)
+}
+
+// Serve принимает соединения на lis. После Shutdown возвращает grpc.ErrServerStopped или nil.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpcServer.Serve(lis)
+}
+
+// Shutdown закрывает потоки WatchBatches и ждет завершения текущих вызовов.
+// Если ctx истекает раньше, оставшиеся соединения разрываются.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// observeUnary пишет длительность и ошибки вызовов в бизнес-метрики
+func observeUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	metrics.ObserveBusiness("grpc."+path.Base(info.FullMethod), start, err)
+	return resp, err
+}
+
+// statusError переводит ошибку сервиса в статус gRPC, как serviceErrorStatus в HTTP API
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidArgument):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrNotBatched):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrIdempotencyMismatch):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrIdempotencyInFlight):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, service.ErrUnknownWitness):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (s *Server) SubmitMessage(ctx context.Context, req *SubmitMessageRequest) (*SubmitMessageResponse, error) {
+	if req.GetChatId() <= 0 || req.GetUserId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "chat_id and user_id must be positive")
+	}
+	if len(req.GetIdempotencyKey()) > maxIdempotencyKeyLen {
+		return nil, status.Errorf(codes.InvalidArgument, "idempotency_key is longer than %d bytes", maxIdempotencyKeyLen)
+	}
+	id, err := s.service.SubmitMessage(ctx, req.GetChatId(), req.GetUserId(), req.GetPayload(), req.GetIdempotencyKey())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &SubmitMessageResponse{MessageId: id}, nil
+}
+
+func (s *Server) GetMessage(ctx context.Context, req *GetMessageRequest) (*Message, error) {
+	info, err := s.service.GetMessage(ctx, req.GetMessageId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	msg := newMessage(info.Message)
+	if info.Proof != nil {
+		msg.Proof = newProof(info.Proof)
+	}
+	return msg, nil
+}
+
+func (s *Server) ListMessages(ctx context.Context, req *ListMessagesRequest) (*ListMessagesResponse, error) {
+	if req.GetChatId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "chat_id must be positive")
+	}
+	f := service.MessageFilter{
+		UserID: req.GetUserId(),
+		Cursor: req.GetCursor(),
+		Desc:   !req.GetAscending(),
+		Limit:  int(req.GetLimit()),
+	}
+	if req.GetFromMs() != 0 {
+		f.From = time.UnixMilli(req.GetFromMs())
+	}
+	if req.GetToMs() != 0 {
+		f.To = time.UnixMilli(req.GetToMs())
+	}
+	page, err := s.service.ListChatMessages(ctx, req.GetChatId(), f)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &ListMessagesResponse{
+		Messages:   make([]*Message, len(page.Messages)),
+		NextCursor: page.NextCursor,
+	}
+	for i, m := range page.Messages {
+		resp.Messages[i] = newMessage(m)
+	}
+	return resp, nil
+}
+
+func (s *Server) GetLatestRoot(ctx context.Context, req *GetLatestRootRequest) (*GetLatestRootResponse, error) {
+	latest, err := s.service.GetLatestRoot(ctx, req.GetChatId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &GetLatestRootResponse{
+		ChatId:   latest.ChatID,
+		Root:     latest.Root,
+		BatchId:  latest.BatchID,
+		ChatRoot: latest.ChatRoot,
+	}
+	if latest.ChatSize != nil {
+		resp.ChatSize = *latest.ChatSize
+	}
+	return resp, nil
+}
+
+func (s *Server) GetProof(ctx context.Context, req *GetProofRequest) (*Proof, error) {
+	p, err := s.service.GetMessageProof(ctx, req.GetMessageId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return newProof(p), nil
+}
+
+// WatchBatches отправляет батчи, пока клиент не отменит вызов или сервер не остановится
+func (s *Server) WatchBatches(req *WatchBatchesRequest, stream grpc.ServerStreamingServer[Batch]) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := s.service.WatchBatches(ctx, req.GetChatId(), req.GetAfterBatchId(), func(b *db.MerkleBatch) error {
+		return stream.Send(newBatch(b))
+	})
+	select {
+	case <-s.stop:
+		return status.Error(codes.Unavailable, "server is shutting down")
+	default:
+	}
+	if err == nil {
+		// сервис остановлен раньше gRPC сервера
+		return status.Error(codes.Unavailable, "service is shutting down")
+	}
+	if _, ok := status.FromError(err); ok {
+		// ошибка stream.Send уже статус gRPC
+		return err
+	}
+	return statusError(err)
+}
+
+func newMessage(m *db.Message) *Message {
+	msg := &Message{
+		MessageId:   m.MessageID,
+		ChatId:      m.ChatID,
+		UserId:      m.UserID,
+		Payload:     m.Payload,
+		PayloadHash: m.PayloadHash,
+		CreatedAtMs: m.CreatedAt.UnixMilli(),
+		Status:      service.MessageStatus(m),
+	}
+	if m.BatchID != nil {
+		msg.BatchId = *m.BatchID
+	}
+	return msg
+}
+
+func newProof(p *service.MessageProof) *Proof {
+	return &Proof{
+		MessageId:   p.MessageID,
+		BatchId:     p.BatchID,
+		Index:       int32(p.Proof.Index),
+		Root:        p.Root,
+		TreeVersion: p.TreeVersion.String(),
+		HashAlg:     p.HashAlg.String(),
+		Siblings:    p.Proof.Siblings,
+		Left:        p.Proof.Left,
+	}
+}
+
+func newBatch(b *db.MerkleBatch) *Batch {
+	batch := &Batch{
+		BatchId:       b.BatchID,
+		ChatId:        b.ChatID,
+		Root:          b.RootHash,
+		FromMessageId: b.FromMessageID,
+		ToMessageId:   b.ToMessageID,
+		TreeVersion:   cgobridge.TreeVersion(b.TreeVersion).String(),
+		HashAlg:       cgobridge.HashAlg(b.HashAlg).String(),
+		ChatRoot:      b.ChatRoot,
+		PrevBatchHash: b.PrevBatchHash,
+		CreatedAtMs:   b.CreatedAt.UnixMilli(),
+	}
+	if b.ChatSize != nil {
+		batch.ChatSize = *b.ChatSize
+	}
+	return batch
+}
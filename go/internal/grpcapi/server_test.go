@@ -0,0 +1,237 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"veriChat/go/internal/cgobridge"
+	"veriChat/go/internal/db"
+	"veriChat/go/internal/service"
+	"veriChat/go/pkg/verify"
+)
+
+// fakeService - Service в памяти: сообщения чата 1 и батчи из канала batches
+type fakeService struct {
+	messages map[int64]*db.Message
+	batches  chan *db.MerkleBatch
+
+	submitted []any // аргументы последнего SubmitMessage
+	filter    service.MessageFilter
+}
+
+func newFakeService() *fakeService {
+	batchID := int64(3)
+	return &fakeService{
+		messages: map[int64]*db.Message{
+			1: {MessageID: 1, ChatID: 1, UserID: 2, Payload: []byte("pending"), CreatedAt: time.UnixMilli(1700000000000)},
+			2: {MessageID: 2, ChatID: 1, UserID: 2, Payload: []byte("batched"), CreatedAt: time.UnixMilli(1700000001000), BatchID: &batchID},
+		},
+		batches: make(chan *db.MerkleBatch),
+	}
+}
+
+func (f *fakeService) SubmitMessage(ctx context.Context, chatID, userID int64, payload []byte, idempKey string) (int64, error) {
+	f.submitted = []any{chatID, userID, payload, idempKey}
+	if idempKey == "busy" {
+		return 0, service.ErrIdempotencyInFlight
+	}
+	return 10, nil
+}
+
+func (f *fakeService) GetMessage(ctx context.Context, messageID int64) (*service.MessageInfo, error) {
+	msg, ok := f.messages[messageID]
+	if !ok {
+		return nil, fmt.Errorf("message %d: %w", messageID, service.ErrNotFound)
+	}
+	info := &service.MessageInfo{Message: msg}
+	if msg.BatchID != nil {
+		info.Proof, _ = f.GetMessageProof(ctx, messageID)
+	}
+	return info, nil
+}
+
+func (f *fakeService) ListChatMessages(ctx context.Context, chatID int64, mf service.MessageFilter) (*service.MessagePage, error) {
+	f.filter = mf
+	return &service.MessagePage{Messages: []*db.Message{f.messages[2], f.messages[1]}, NextCursor: 1}, nil
+}
+
+func (f *fakeService) GetLatestRoot(ctx context.Context, chatID int64) (*db.LatestRoot, error) {
+	size := int64(7)
+	return &db.LatestRoot{ChatID: chatID, BatchID: 3, Root: []byte{0xab, 0xcd}, ChatSize: &size, ChatRoot: []byte{0xef}}, nil
+}
+
+func (f *fakeService) GetMessageProof(ctx context.Context, messageID int64) (*service.MessageProof, error) {
+	msg, ok := f.messages[messageID]
+	if !ok {
+		return nil, fmt.Errorf("message %d: %w", messageID, service.ErrNotFound)
+	}
+	if msg.BatchID == nil {
+		return nil, service.ErrNotBatched
+	}
+	return &service.MessageProof{
+		MessageID:   messageID,
+		BatchID:     *msg.BatchID,
+		Index:       1,
+		Root:        []byte{0xab, 0xcd},
+		TreeVersion: cgobridge.TreeRFC6962,
+		HashAlg:     cgobridge.HashSHA256,
+		Proof:       &verify.Proof{Index: 1, Siblings: [][]byte{{0x01}}, Left: []bool{true}},
+	}, nil
+}
+
+func (f *fakeService) WatchBatches(ctx context.Context, chatID, afterBatchID int64, fn func(*db.MerkleBatch) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case b := <-f.batches:
+			if err := fn(b); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// startServer запускает Server над svc на bufconn и возвращает клиента к нему
+func startServer(t *testing.T, svc Service) (*Server, VeriChatClient) {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer("bufconn", svc)
+	go srv.Serve(lis)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return srv, NewVeriChatClient(conn)
+}
+
+func TestServerUnary(t *testing.T) {
+	svc := newFakeService()
+	_, client := startServer(t, svc)
+	ctx := context.Background()
+
+	sub, err := client.SubmitMessage(ctx, &SubmitMessageRequest{ChatId: 1, UserId: 2, Payload: []byte("hi"), IdempotencyKey: "k"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), sub.GetMessageId())
+	assert.Equal(t, []any{int64(1), int64(2), []byte("hi"), "k"}, svc.submitted)
+
+	msg, err := client.GetMessage(ctx, &GetMessageRequest{MessageId: 2})
+	require.NoError(t, err)
+	assert.Equal(t, service.StatusBatched, msg.GetStatus())
+	assert.Equal(t, int64(3), msg.GetBatchId())
+	assert.Equal(t, int64(1700000001000), msg.GetCreatedAtMs())
+	require.NotNil(t, msg.GetProof())
+	assert.Equal(t, []bool{true}, msg.GetProof().GetLeft())
+	assert.Equal(t, cgobridge.HashSHA256.String(), msg.GetProof().GetHashAlg())
+
+	msg, err = client.GetMessage(ctx, &GetMessageRequest{MessageId: 1})
+	require.NoError(t, err)
+	assert.Equal(t, service.StatusPending, msg.GetStatus())
+	assert.Nil(t, msg.GetProof())
+
+	page, err := client.ListMessages(ctx, &ListMessagesRequest{ChatId: 1, FromMs: 1700000000000, Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, page.GetMessages(), 2)
+	assert.Equal(t, int64(1), page.GetNextCursor())
+	assert.True(t, svc.filter.Desc)
+	assert.Equal(t, int64(1700000000000), svc.filter.From.UnixMilli())
+	assert.True(t, svc.filter.To.IsZero())
+
+	root, err := client.GetLatestRoot(ctx, &GetLatestRootRequest{ChatId: 1})
+	require.NoError(t, err)
+	want := &GetLatestRootResponse{ChatId: 1, Root: []byte{0xab, 0xcd}, BatchId: 3, ChatSize: 7, ChatRoot: []byte{0xef}}
+	assert.True(t, proto.Equal(want, root), "got %v", root)
+
+	proof, err := client.GetProof(ctx, &GetProofRequest{MessageId: 2})
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{{0x01}}, proof.GetSiblings())
+}
+
+func TestServerErrors(t *testing.T) {
+	_, client := startServer(t, newFakeService())
+	ctx := context.Background()
+
+	cases := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"submit without chat", func() error {
+			_, err := client.SubmitMessage(ctx, &SubmitMessageRequest{UserId: 2})
+			return err
+		}, codes.InvalidArgument},
+		{"submit in flight", func() error {
+			_, err := client.SubmitMessage(ctx, &SubmitMessageRequest{ChatId: 1, UserId: 2, IdempotencyKey: "busy"})
+			return err
+		}, codes.Aborted},
+		{"unknown message", func() error {
+			_, err := client.GetMessage(ctx, &GetMessageRequest{MessageId: 99})
+			return err
+		}, codes.NotFound},
+		{"proof of pending message", func() error {
+			_, err := client.GetProof(ctx, &GetProofRequest{MessageId: 1})
+			return err
+		}, codes.FailedPrecondition},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.code, status.Code(tc.call()))
+		})
+	}
+}
+
+func TestWatchBatches(t *testing.T) {
+	svc := newFakeService()
+	srv, client := startServer(t, svc)
+
+	stream, err := client.WatchBatches(context.Background(), &WatchBatchesRequest{ChatId: 1})
+	require.NoError(t, err)
+
+	size := int64(5)
+	svc.batches <- &db.MerkleBatch{BatchID: 4, ChatID: 1, RootHash: []byte{1}, ChatSize: &size, CreatedAt: time.UnixMilli(1700000000000)}
+	b, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), b.GetBatchId())
+	assert.Equal(t, int64(5), b.GetChatSize())
+
+	// Shutdown не ждет клиента потока: поток закрывается с Unavailable
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+// TestWireCompat - сгенерированный код пишет те же байты, что и клиенты прежней версии сервера
+func TestWireCompat(t *testing.T) {
+	data, err := proto.Marshal(&SubmitMessageRequest{ChatId: 1, UserId: 150, Payload: []byte("hi")})
+	require.NoError(t, err)
+	assert.Equal(t, "08011096011a026869", hex.EncodeToString(data))
+
+	// repeated bool - packed
+	data, err = proto.Marshal(&Proof{Left: []bool{true, false, true}})
+	require.NoError(t, err)
+	assert.Equal(t, "4203010001", hex.EncodeToString(data))
+
+	// поле 15 клиента из будущей версии proto пропускается
+	var req GetMessageRequest
+	require.NoError(t, proto.Unmarshal([]byte{0x08, 0x03, 0x7a, 0x01, 'x'}, &req))
+	assert.Equal(t, int64(3), req.GetMessageId())
+}
@@ -0,0 +1,1032 @@
+// gRPC API veriChat. Клиент на любом языке генерирует код из этого файла; код для
+// Go (verichat.pb.go, verichat_grpc.pb.go) пересобирается go generate в go/internal/grpcapi.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: verichat.proto
+
+package grpcapi
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SubmitMessageRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	ChatId  int64                  `protobuf:"varint,1,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	UserId  int64                  `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Payload []byte                 `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	// то же, что заголовок Idempotency-Key: действует в пределах user_id
+	IdempotencyKey string `protobuf:"bytes,4,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SubmitMessageRequest) Reset() {
+	*x = SubmitMessageRequest{}
+	mi := &file_verichat_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitMessageRequest) ProtoMessage() {}
+
+func (x *SubmitMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitMessageRequest.ProtoReflect.Descriptor instead.
+func (*SubmitMessageRequest) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubmitMessageRequest) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+func (x *SubmitMessageRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *SubmitMessageRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *SubmitMessageRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+type SubmitMessageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     int64                  `protobuf:"varint,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitMessageResponse) Reset() {
+	*x = SubmitMessageResponse{}
+	mi := &file_verichat_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitMessageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitMessageResponse) ProtoMessage() {}
+
+func (x *SubmitMessageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitMessageResponse.ProtoReflect.Descriptor instead.
+func (*SubmitMessageResponse) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SubmitMessageResponse) GetMessageId() int64 {
+	if x != nil {
+		return x.MessageId
+	}
+	return 0
+}
+
+type GetMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     int64                  `protobuf:"varint,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMessageRequest) Reset() {
+	*x = GetMessageRequest{}
+	mi := &file_verichat_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMessageRequest) ProtoMessage() {}
+
+func (x *GetMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMessageRequest.ProtoReflect.Descriptor instead.
+func (*GetMessageRequest) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetMessageRequest) GetMessageId() int64 {
+	if x != nil {
+		return x.MessageId
+	}
+	return 0
+}
+
+type Message struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     int64                  `protobuf:"varint,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	ChatId        int64                  `protobuf:"varint,2,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	UserId        int64                  `protobuf:"varint,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Payload       []byte                 `protobuf:"bytes,4,opt,name=payload,proto3" json:"payload,omitempty"`
+	PayloadHash   []byte                 `protobuf:"bytes,5,opt,name=payload_hash,json=payloadHash,proto3" json:"payload_hash,omitempty"`
+	CreatedAtMs   int64                  `protobuf:"varint,6,opt,name=created_at_ms,json=createdAtMs,proto3" json:"created_at_ms,omitempty"`
+	BatchId       int64                  `protobuf:"varint,7,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"` // 0 - сообщение еще не в батче
+	Status        string                 `protobuf:"bytes,8,opt,name=status,proto3" json:"status,omitempty"`                   // pending или batched
+	Proof         *Proof                 `protobuf:"bytes,9,opt,name=proof,proto3" json:"proof,omitempty"`                     // только в GetMessage и только для batched
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Message) Reset() {
+	*x = Message{}
+	mi := &file_verichat_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Message) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Message) ProtoMessage() {}
+
+func (x *Message) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Message.ProtoReflect.Descriptor instead.
+func (*Message) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Message) GetMessageId() int64 {
+	if x != nil {
+		return x.MessageId
+	}
+	return 0
+}
+
+func (x *Message) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+func (x *Message) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *Message) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Message) GetPayloadHash() []byte {
+	if x != nil {
+		return x.PayloadHash
+	}
+	return nil
+}
+
+func (x *Message) GetCreatedAtMs() int64 {
+	if x != nil {
+		return x.CreatedAtMs
+	}
+	return 0
+}
+
+func (x *Message) GetBatchId() int64 {
+	if x != nil {
+		return x.BatchId
+	}
+	return 0
+}
+
+func (x *Message) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Message) GetProof() *Proof {
+	if x != nil {
+		return x.Proof
+	}
+	return nil
+}
+
+type ListMessagesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChatId        int64                  `protobuf:"varint,1,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	UserId        int64                  `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // 0 - все пользователи
+	FromMs        int64                  `protobuf:"varint,3,opt,name=from_ms,json=fromMs,proto3" json:"from_ms,omitempty"` // created_at >= from_ms, 0 - без ограничения
+	ToMs          int64                  `protobuf:"varint,4,opt,name=to_ms,json=toMs,proto3" json:"to_ms,omitempty"`       // created_at < to_ms, 0 - без ограничения
+	Cursor        int64                  `protobuf:"varint,5,opt,name=cursor,proto3" json:"cursor,omitempty"`               // next_cursor предыдущей страницы
+	Limit         int32                  `protobuf:"varint,6,opt,name=limit,proto3" json:"limit,omitempty"`                 // 0 - 50, не больше 200
+	Ascending     bool                   `protobuf:"varint,7,opt,name=ascending,proto3" json:"ascending,omitempty"`         // по умолчанию от новых к старым
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMessagesRequest) Reset() {
+	*x = ListMessagesRequest{}
+	mi := &file_verichat_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMessagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMessagesRequest) ProtoMessage() {}
+
+func (x *ListMessagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMessagesRequest.ProtoReflect.Descriptor instead.
+func (*ListMessagesRequest) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListMessagesRequest) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+func (x *ListMessagesRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *ListMessagesRequest) GetFromMs() int64 {
+	if x != nil {
+		return x.FromMs
+	}
+	return 0
+}
+
+func (x *ListMessagesRequest) GetToMs() int64 {
+	if x != nil {
+		return x.ToMs
+	}
+	return 0
+}
+
+func (x *ListMessagesRequest) GetCursor() int64 {
+	if x != nil {
+		return x.Cursor
+	}
+	return 0
+}
+
+func (x *ListMessagesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListMessagesRequest) GetAscending() bool {
+	if x != nil {
+		return x.Ascending
+	}
+	return false
+}
+
+type ListMessagesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*Message             `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	NextCursor    int64                  `protobuf:"varint,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"` // 0 - страница последняя
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMessagesResponse) Reset() {
+	*x = ListMessagesResponse{}
+	mi := &file_verichat_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMessagesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMessagesResponse) ProtoMessage() {}
+
+func (x *ListMessagesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMessagesResponse.ProtoReflect.Descriptor instead.
+func (*ListMessagesResponse) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListMessagesResponse) GetMessages() []*Message {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *ListMessagesResponse) GetNextCursor() int64 {
+	if x != nil {
+		return x.NextCursor
+	}
+	return 0
+}
+
+type GetLatestRootRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChatId        int64                  `protobuf:"varint,1,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLatestRootRequest) Reset() {
+	*x = GetLatestRootRequest{}
+	mi := &file_verichat_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLatestRootRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLatestRootRequest) ProtoMessage() {}
+
+func (x *GetLatestRootRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLatestRootRequest.ProtoReflect.Descriptor instead.
+func (*GetLatestRootRequest) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetLatestRootRequest) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+type GetLatestRootResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChatId        int64                  `protobuf:"varint,1,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	Root          []byte                 `protobuf:"bytes,2,opt,name=root,proto3" json:"root,omitempty"` // root последнего батча
+	BatchId       int64                  `protobuf:"varint,3,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	ChatSize      int64                  `protobuf:"varint,4,opt,name=chat_size,json=chatSize,proto3" json:"chat_size,omitempty"` // 0 - у батча нет истории чата
+	ChatRoot      []byte                 `protobuf:"bytes,5,opt,name=chat_root,json=chatRoot,proto3" json:"chat_root,omitempty"`  // root истории чата после батча
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLatestRootResponse) Reset() {
+	*x = GetLatestRootResponse{}
+	mi := &file_verichat_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLatestRootResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLatestRootResponse) ProtoMessage() {}
+
+func (x *GetLatestRootResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLatestRootResponse.ProtoReflect.Descriptor instead.
+func (*GetLatestRootResponse) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetLatestRootResponse) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+func (x *GetLatestRootResponse) GetRoot() []byte {
+	if x != nil {
+		return x.Root
+	}
+	return nil
+}
+
+func (x *GetLatestRootResponse) GetBatchId() int64 {
+	if x != nil {
+		return x.BatchId
+	}
+	return 0
+}
+
+func (x *GetLatestRootResponse) GetChatSize() int64 {
+	if x != nil {
+		return x.ChatSize
+	}
+	return 0
+}
+
+func (x *GetLatestRootResponse) GetChatRoot() []byte {
+	if x != nil {
+		return x.ChatRoot
+	}
+	return nil
+}
+
+type GetProofRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     int64                  `protobuf:"varint,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetProofRequest) Reset() {
+	*x = GetProofRequest{}
+	mi := &file_verichat_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetProofRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetProofRequest) ProtoMessage() {}
+
+func (x *GetProofRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetProofRequest.ProtoReflect.Descriptor instead.
+func (*GetProofRequest) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetProofRequest) GetMessageId() int64 {
+	if x != nil {
+		return x.MessageId
+	}
+	return 0
+}
+
+type Proof struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     int64                  `protobuf:"varint,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	BatchId       int64                  `protobuf:"varint,2,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	Index         int32                  `protobuf:"varint,3,opt,name=index,proto3" json:"index,omitempty"`
+	Root          []byte                 `protobuf:"bytes,4,opt,name=root,proto3" json:"root,omitempty"`
+	TreeVersion   string                 `protobuf:"bytes,5,opt,name=tree_version,json=treeVersion,proto3" json:"tree_version,omitempty"`
+	HashAlg       string                 `protobuf:"bytes,6,opt,name=hash_alg,json=hashAlg,proto3" json:"hash_alg,omitempty"`
+	Siblings      [][]byte               `protobuf:"bytes,7,rep,name=siblings,proto3" json:"siblings,omitempty"` // снизу вверх
+	Left          []bool                 `protobuf:"varint,8,rep,packed,name=left,proto3" json:"left,omitempty"` // true - сосед слева от узла на пути
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Proof) Reset() {
+	*x = Proof{}
+	mi := &file_verichat_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Proof) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Proof) ProtoMessage() {}
+
+func (x *Proof) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Proof.ProtoReflect.Descriptor instead.
+func (*Proof) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Proof) GetMessageId() int64 {
+	if x != nil {
+		return x.MessageId
+	}
+	return 0
+}
+
+func (x *Proof) GetBatchId() int64 {
+	if x != nil {
+		return x.BatchId
+	}
+	return 0
+}
+
+func (x *Proof) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Proof) GetRoot() []byte {
+	if x != nil {
+		return x.Root
+	}
+	return nil
+}
+
+func (x *Proof) GetTreeVersion() string {
+	if x != nil {
+		return x.TreeVersion
+	}
+	return ""
+}
+
+func (x *Proof) GetHashAlg() string {
+	if x != nil {
+		return x.HashAlg
+	}
+	return ""
+}
+
+func (x *Proof) GetSiblings() [][]byte {
+	if x != nil {
+		return x.Siblings
+	}
+	return nil
+}
+
+func (x *Proof) GetLeft() []bool {
+	if x != nil {
+		return x.Left
+	}
+	return nil
+}
+
+type WatchBatchesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChatId        int64                  `protobuf:"varint,1,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`                     // 0 - батчи всех чатов
+	AfterBatchId  int64                  `protobuf:"varint,2,opt,name=after_batch_id,json=afterBatchId,proto3" json:"after_batch_id,omitempty"` // сначала уже сохраненные батчи чата после него (только с chat_id != 0)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchBatchesRequest) Reset() {
+	*x = WatchBatchesRequest{}
+	mi := &file_verichat_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchBatchesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchBatchesRequest) ProtoMessage() {}
+
+func (x *WatchBatchesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchBatchesRequest.ProtoReflect.Descriptor instead.
+func (*WatchBatchesRequest) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *WatchBatchesRequest) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+func (x *WatchBatchesRequest) GetAfterBatchId() int64 {
+	if x != nil {
+		return x.AfterBatchId
+	}
+	return 0
+}
+
+type Batch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BatchId       int64                  `protobuf:"varint,1,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	ChatId        int64                  `protobuf:"varint,2,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	Root          []byte                 `protobuf:"bytes,3,opt,name=root,proto3" json:"root,omitempty"`
+	FromMessageId int64                  `protobuf:"varint,4,opt,name=from_message_id,json=fromMessageId,proto3" json:"from_message_id,omitempty"`
+	ToMessageId   int64                  `protobuf:"varint,5,opt,name=to_message_id,json=toMessageId,proto3" json:"to_message_id,omitempty"`
+	TreeVersion   string                 `protobuf:"bytes,6,opt,name=tree_version,json=treeVersion,proto3" json:"tree_version,omitempty"`
+	HashAlg       string                 `protobuf:"bytes,7,opt,name=hash_alg,json=hashAlg,proto3" json:"hash_alg,omitempty"`
+	ChatSize      int64                  `protobuf:"varint,8,opt,name=chat_size,json=chatSize,proto3" json:"chat_size,omitempty"`
+	ChatRoot      []byte                 `protobuf:"bytes,9,opt,name=chat_root,json=chatRoot,proto3" json:"chat_root,omitempty"`
+	PrevBatchHash []byte                 `protobuf:"bytes,10,opt,name=prev_batch_hash,json=prevBatchHash,proto3" json:"prev_batch_hash,omitempty"`
+	CreatedAtMs   int64                  `protobuf:"varint,11,opt,name=created_at_ms,json=createdAtMs,proto3" json:"created_at_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Batch) Reset() {
+	*x = Batch{}
+	mi := &file_verichat_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Batch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Batch) ProtoMessage() {}
+
+func (x *Batch) ProtoReflect() protoreflect.Message {
+	mi := &file_verichat_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Batch.ProtoReflect.Descriptor instead.
+func (*Batch) Descriptor() ([]byte, []int) {
+	return file_verichat_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Batch) GetBatchId() int64 {
+	if x != nil {
+		return x.BatchId
+	}
+	return 0
+}
+
+func (x *Batch) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+func (x *Batch) GetRoot() []byte {
+	if x != nil {
+		return x.Root
+	}
+	return nil
+}
+
+func (x *Batch) GetFromMessageId() int64 {
+	if x != nil {
+		return x.FromMessageId
+	}
+	return 0
+}
+
+func (x *Batch) GetToMessageId() int64 {
+	if x != nil {
+		return x.ToMessageId
+	}
+	return 0
+}
+
+func (x *Batch) GetTreeVersion() string {
+	if x != nil {
+		return x.TreeVersion
+	}
+	return ""
+}
+
+func (x *Batch) GetHashAlg() string {
+	if x != nil {
+		return x.HashAlg
+	}
+	return ""
+}
+
+func (x *Batch) GetChatSize() int64 {
+	if x != nil {
+		return x.ChatSize
+	}
+	return 0
+}
+
+func (x *Batch) GetChatRoot() []byte {
+	if x != nil {
+		return x.ChatRoot
+	}
+	return nil
+}
+
+func (x *Batch) GetPrevBatchHash() []byte {
+	if x != nil {
+		return x.PrevBatchHash
+	}
+	return nil
+}
+
+func (x *Batch) GetCreatedAtMs() int64 {
+	if x != nil {
+		return x.CreatedAtMs
+	}
+	return 0
+}
+
+var File_verichat_proto protoreflect.FileDescriptor
+
+const file_verichat_proto_rawDesc = "" +
+	"\n" +
+	"\x0everichat.proto\x12\vverichat.v1\"\x8b\x01\n" +
+	"\x14SubmitMessageRequest\x12\x17\n" +
+	"\achat_id\x18\x01 \x01(\x03R\x06chatId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\x03R\x06userId\x12\x18\n" +
+	"\apayload\x18\x03 \x01(\fR\apayload\x12'\n" +
+	"\x0fidempotency_key\x18\x04 \x01(\tR\x0eidempotencyKey\"6\n" +
+	"\x15SubmitMessageResponse\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\x03R\tmessageId\"2\n" +
+	"\x11GetMessageRequest\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\x03R\tmessageId\"\x98\x02\n" +
+	"\aMessage\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\x03R\tmessageId\x12\x17\n" +
+	"\achat_id\x18\x02 \x01(\x03R\x06chatId\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\x03R\x06userId\x12\x18\n" +
+	"\apayload\x18\x04 \x01(\fR\apayload\x12!\n" +
+	"\fpayload_hash\x18\x05 \x01(\fR\vpayloadHash\x12\"\n" +
+	"\rcreated_at_ms\x18\x06 \x01(\x03R\vcreatedAtMs\x12\x19\n" +
+	"\bbatch_id\x18\a \x01(\x03R\abatchId\x12\x16\n" +
+	"\x06status\x18\b \x01(\tR\x06status\x12(\n" +
+	"\x05proof\x18\t \x01(\v2\x12.verichat.v1.ProofR\x05proof\"\xc1\x01\n" +
+	"\x13ListMessagesRequest\x12\x17\n" +
+	"\achat_id\x18\x01 \x01(\x03R\x06chatId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\x03R\x06userId\x12\x17\n" +
+	"\afrom_ms\x18\x03 \x01(\x03R\x06fromMs\x12\x13\n" +
+	"\x05to_ms\x18\x04 \x01(\x03R\x04toMs\x12\x16\n" +
+	"\x06cursor\x18\x05 \x01(\x03R\x06cursor\x12\x14\n" +
+	"\x05limit\x18\x06 \x01(\x05R\x05limit\x12\x1c\n" +
+	"\tascending\x18\a \x01(\bR\tascending\"i\n" +
+	"\x14ListMessagesResponse\x120\n" +
+	"\bmessages\x18\x01 \x03(\v2\x14.verichat.v1.MessageR\bmessages\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\x03R\n" +
+	"nextCursor\"/\n" +
+	"\x14GetLatestRootRequest\x12\x17\n" +
+	"\achat_id\x18\x01 \x01(\x03R\x06chatId\"\x99\x01\n" +
+	"\x15GetLatestRootResponse\x12\x17\n" +
+	"\achat_id\x18\x01 \x01(\x03R\x06chatId\x12\x12\n" +
+	"\x04root\x18\x02 \x01(\fR\x04root\x12\x19\n" +
+	"\bbatch_id\x18\x03 \x01(\x03R\abatchId\x12\x1b\n" +
+	"\tchat_size\x18\x04 \x01(\x03R\bchatSize\x12\x1b\n" +
+	"\tchat_root\x18\x05 \x01(\fR\bchatRoot\"0\n" +
+	"\x0fGetProofRequest\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\x03R\tmessageId\"\xd9\x01\n" +
+	"\x05Proof\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\x03R\tmessageId\x12\x19\n" +
+	"\bbatch_id\x18\x02 \x01(\x03R\abatchId\x12\x14\n" +
+	"\x05index\x18\x03 \x01(\x05R\x05index\x12\x12\n" +
+	"\x04root\x18\x04 \x01(\fR\x04root\x12!\n" +
+	"\ftree_version\x18\x05 \x01(\tR\vtreeVersion\x12\x19\n" +
+	"\bhash_alg\x18\x06 \x01(\tR\ahashAlg\x12\x1a\n" +
+	"\bsiblings\x18\a \x03(\fR\bsiblings\x12\x12\n" +
+	"\x04left\x18\b \x03(\bR\x04left\"T\n" +
+	"\x13WatchBatchesRequest\x12\x17\n" +
+	"\achat_id\x18\x01 \x01(\x03R\x06chatId\x12$\n" +
+	"\x0eafter_batch_id\x18\x02 \x01(\x03R\fafterBatchId\"\xdf\x02\n" +
+	"\x05Batch\x12\x19\n" +
+	"\bbatch_id\x18\x01 \x01(\x03R\abatchId\x12\x17\n" +
+	"\achat_id\x18\x02 \x01(\x03R\x06chatId\x12\x12\n" +
+	"\x04root\x18\x03 \x01(\fR\x04root\x12&\n" +
+	"\x0ffrom_message_id\x18\x04 \x01(\x03R\rfromMessageId\x12\"\n" +
+	"\rto_message_id\x18\x05 \x01(\x03R\vtoMessageId\x12!\n" +
+	"\ftree_version\x18\x06 \x01(\tR\vtreeVersion\x12\x19\n" +
+	"\bhash_alg\x18\a \x01(\tR\ahashAlg\x12\x1b\n" +
+	"\tchat_size\x18\b \x01(\x03R\bchatSize\x12\x1b\n" +
+	"\tchat_root\x18\t \x01(\fR\bchatRoot\x12&\n" +
+	"\x0fprev_batch_hash\x18\n" +
+	" \x01(\fR\rprevBatchHash\x12\"\n" +
+	"\rcreated_at_ms\x18\v \x01(\x03R\vcreatedAtMs2\xd9\x03\n" +
+	"\bVeriChat\x12V\n" +
+	"\rSubmitMessage\x12!.verichat.v1.SubmitMessageRequest\x1a\".verichat.v1.SubmitMessageResponse\x12B\n" +
+	"\n" +
+	"GetMessage\x12\x1e.verichat.v1.GetMessageRequest\x1a\x14.verichat.v1.Message\x12S\n" +
+	"\fListMessages\x12 .verichat.v1.ListMessagesRequest\x1a!.verichat.v1.ListMessagesResponse\x12V\n" +
+	"\rGetLatestRoot\x12!.verichat.v1.GetLatestRootRequest\x1a\".verichat.v1.GetLatestRootResponse\x12<\n" +
+	"\bGetProof\x12\x1c.verichat.v1.GetProofRequest\x1a\x12.verichat.v1.Proof\x12F\n" +
+	"\fWatchBatches\x12 .verichat.v1.WatchBatchesRequest\x1a\x12.verichat.v1.Batch0\x01B\x1eZ\x1cveriChat/go/internal/grpcapib\x06proto3"
+
+var (
+	file_verichat_proto_rawDescOnce sync.Once
+	file_verichat_proto_rawDescData []byte
+)
+
+func file_verichat_proto_rawDescGZIP() []byte {
+	file_verichat_proto_rawDescOnce.Do(func() {
+		file_verichat_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_verichat_proto_rawDesc), len(file_verichat_proto_rawDesc)))
+	})
+	return file_verichat_proto_rawDescData
+}
+
+var file_verichat_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_verichat_proto_goTypes = []any{
+	(*SubmitMessageRequest)(nil),  // 0: verichat.v1.SubmitMessageRequest
+	(*SubmitMessageResponse)(nil), // 1: verichat.v1.SubmitMessageResponse
+	(*GetMessageRequest)(nil),     // 2: verichat.v1.GetMessageRequest
+	(*Message)(nil),               // 3: verichat.v1.Message
+	(*ListMessagesRequest)(nil),   // 4: verichat.v1.ListMessagesRequest
+	(*ListMessagesResponse)(nil),  // 5: verichat.v1.ListMessagesResponse
+	(*GetLatestRootRequest)(nil),  // 6: verichat.v1.GetLatestRootRequest
+	(*GetLatestRootResponse)(nil), // 7: verichat.v1.GetLatestRootResponse
+	(*GetProofRequest)(nil),       // 8: verichat.v1.GetProofRequest
+	(*Proof)(nil),                 // 9: verichat.v1.Proof
+	(*WatchBatchesRequest)(nil),   // 10: verichat.v1.WatchBatchesRequest
+	(*Batch)(nil),                 // 11: verichat.v1.Batch
+}
+var file_verichat_proto_depIdxs = []int32{
+	9,  // 0: verichat.v1.Message.proof:type_name -> verichat.v1.Proof
+	3,  // 1: verichat.v1.ListMessagesResponse.messages:type_name -> verichat.v1.Message
+	0,  // 2: verichat.v1.VeriChat.SubmitMessage:input_type -> verichat.v1.SubmitMessageRequest
+	2,  // 3: verichat.v1.VeriChat.GetMessage:input_type -> verichat.v1.GetMessageRequest
+	4,  // 4: verichat.v1.VeriChat.ListMessages:input_type -> verichat.v1.ListMessagesRequest
+	6,  // 5: verichat.v1.VeriChat.GetLatestRoot:input_type -> verichat.v1.GetLatestRootRequest
+	8,  // 6: verichat.v1.VeriChat.GetProof:input_type -> verichat.v1.GetProofRequest
+	10, // 7: verichat.v1.VeriChat.WatchBatches:input_type -> verichat.v1.WatchBatchesRequest
+	1,  // 8: verichat.v1.VeriChat.SubmitMessage:output_type -> verichat.v1.SubmitMessageResponse
+	3,  // 9: verichat.v1.VeriChat.GetMessage:output_type -> verichat.v1.Message
+	5,  // 10: verichat.v1.VeriChat.ListMessages:output_type -> verichat.v1.ListMessagesResponse
+	7,  // 11: verichat.v1.VeriChat.GetLatestRoot:output_type -> verichat.v1.GetLatestRootResponse
+	9,  // 12: verichat.v1.VeriChat.GetProof:output_type -> verichat.v1.Proof
+	11, // 13: verichat.v1.VeriChat.WatchBatches:output_type -> verichat.v1.Batch
+	8,  // [8:14] is the sub-list for method output_type
+	2,  // [2:8] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_verichat_proto_init() }
+func file_verichat_proto_init() {
+	if File_verichat_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_verichat_proto_rawDesc), len(file_verichat_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_verichat_proto_goTypes,
+		DependencyIndexes: file_verichat_proto_depIdxs,
+		MessageInfos:      file_verichat_proto_msgTypes,
+	}.Build()
+	File_verichat_proto = out.File
+	file_verichat_proto_goTypes = nil
+	file_verichat_proto_depIdxs = nil
+}
@@ -0,0 +1,336 @@
+// gRPC API veriChat. Клиент на любом языке генерирует код из этого файла; код для
+// Go (verichat.pb.go, verichat_grpc.pb.go) пересобирается go generate в go/internal/grpcapi.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: verichat.proto
+
+package grpcapi
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	VeriChat_SubmitMessage_FullMethodName = "/verichat.v1.VeriChat/SubmitMessage"
+	VeriChat_GetMessage_FullMethodName    = "/verichat.v1.VeriChat/GetMessage"
+	VeriChat_ListMessages_FullMethodName  = "/verichat.v1.VeriChat/ListMessages"
+	VeriChat_GetLatestRoot_FullMethodName = "/verichat.v1.VeriChat/GetLatestRoot"
+	VeriChat_GetProof_FullMethodName      = "/verichat.v1.VeriChat/GetProof"
+	VeriChat_WatchBatches_FullMethodName  = "/verichat.v1.VeriChat/WatchBatches"
+)
+
+// VeriChatClient is the client API for VeriChat service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VeriChatClient interface {
+	// Принять сообщение, как POST /messages
+	SubmitMessage(ctx context.Context, in *SubmitMessageRequest, opts ...grpc.CallOption) (*SubmitMessageResponse, error)
+	// Сообщение и inclusion proof, если оно уже в батче, как GET /messages/{id}
+	GetMessage(ctx context.Context, in *GetMessageRequest, opts ...grpc.CallOption) (*Message, error)
+	// История чата страницами, как GET /chats/{id}/messages
+	ListMessages(ctx context.Context, in *ListMessagesRequest, opts ...grpc.CallOption) (*ListMessagesResponse, error)
+	// Root последнего батча чата, как GET /chats/{id}/root
+	GetLatestRoot(ctx context.Context, in *GetLatestRootRequest, opts ...grpc.CallOption) (*GetLatestRootResponse, error)
+	// Inclusion proof сообщения в дерево его батча
+	GetProof(ctx context.Context, in *GetProofRequest, opts ...grpc.CallOption) (*Proof, error)
+	// Новые батчи по мере коммита. Поток не заканчивается сам; при остановке
+	// сервера он закрывается с UNAVAILABLE - переподключитесь с after_batch_id.
+	// Поток всех чатов (chat_id = 0) возобновить нельзя: батчи разных чатов
+	// коммитятся не в порядке batch_id; пропущенные - через GET /chats/{id}/batches.
+	WatchBatches(ctx context.Context, in *WatchBatchesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Batch], error)
+}
+
+type veriChatClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVeriChatClient(cc grpc.ClientConnInterface) VeriChatClient {
+	return &veriChatClient{cc}
+}
+
+func (c *veriChatClient) SubmitMessage(ctx context.Context, in *SubmitMessageRequest, opts ...grpc.CallOption) (*SubmitMessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitMessageResponse)
+	err := c.cc.Invoke(ctx, VeriChat_SubmitMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *veriChatClient) GetMessage(ctx context.Context, in *GetMessageRequest, opts ...grpc.CallOption) (*Message, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Message)
+	err := c.cc.Invoke(ctx, VeriChat_GetMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *veriChatClient) ListMessages(ctx context.Context, in *ListMessagesRequest, opts ...grpc.CallOption) (*ListMessagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMessagesResponse)
+	err := c.cc.Invoke(ctx, VeriChat_ListMessages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *veriChatClient) GetLatestRoot(ctx context.Context, in *GetLatestRootRequest, opts ...grpc.CallOption) (*GetLatestRootResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetLatestRootResponse)
+	err := c.cc.Invoke(ctx, VeriChat_GetLatestRoot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *veriChatClient) GetProof(ctx context.Context, in *GetProofRequest, opts ...grpc.CallOption) (*Proof, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Proof)
+	err := c.cc.Invoke(ctx, VeriChat_GetProof_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *veriChatClient) WatchBatches(ctx context.Context, in *WatchBatchesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Batch], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &VeriChat_ServiceDesc.Streams[0], VeriChat_WatchBatches_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchBatchesRequest, Batch]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VeriChat_WatchBatchesClient = grpc.ServerStreamingClient[Batch]
+
+// VeriChatServer is the server API for VeriChat service.
+// All implementations must embed UnimplementedVeriChatServer
+// for forward compatibility.
+type VeriChatServer interface {
+	// Принять сообщение, как POST /messages
+	SubmitMessage(context.Context, *SubmitMessageRequest) (*SubmitMessageResponse, error)
+	// Сообщение и inclusion proof, если оно уже в батче, как GET /messages/{id}
+	GetMessage(context.Context, *GetMessageRequest) (*Message, error)
+	// История чата страницами, как GET /chats/{id}/messages
+	ListMessages(context.Context, *ListMessagesRequest) (*ListMessagesResponse, error)
+	// Root последнего батча чата, как GET /chats/{id}/root
+	GetLatestRoot(context.Context, *GetLatestRootRequest) (*GetLatestRootResponse, error)
+	// Inclusion proof сообщения в дерево его батча
+	GetProof(context.Context, *GetProofRequest) (*Proof, error)
+	// Новые батчи по мере коммита. Поток не заканчивается сам; при остановке
+	// сервера он закрывается с UNAVAILABLE - переподключитесь с after_batch_id.
+	// Поток всех чатов (chat_id = 0) возобновить нельзя: батчи разных чатов
+	// коммитятся не в порядке batch_id; пропущенные - через GET /chats/{id}/batches.
+	WatchBatches(*WatchBatchesRequest, grpc.ServerStreamingServer[Batch]) error
+	mustEmbedUnimplementedVeriChatServer()
+}
+
+// UnimplementedVeriChatServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedVeriChatServer struct{}
+
+func (UnimplementedVeriChatServer) SubmitMessage(context.Context, *SubmitMessageRequest) (*SubmitMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitMessage not implemented")
+}
+func (UnimplementedVeriChatServer) GetMessage(context.Context, *GetMessageRequest) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMessage not implemented")
+}
+func (UnimplementedVeriChatServer) ListMessages(context.Context, *ListMessagesRequest) (*ListMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMessages not implemented")
+}
+func (UnimplementedVeriChatServer) GetLatestRoot(context.Context, *GetLatestRootRequest) (*GetLatestRootResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLatestRoot not implemented")
+}
+func (UnimplementedVeriChatServer) GetProof(context.Context, *GetProofRequest) (*Proof, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProof not implemented")
+}
+func (UnimplementedVeriChatServer) WatchBatches(*WatchBatchesRequest, grpc.ServerStreamingServer[Batch]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchBatches not implemented")
+}
+func (UnimplementedVeriChatServer) mustEmbedUnimplementedVeriChatServer() {}
+func (UnimplementedVeriChatServer) testEmbeddedByValue()                  {}
+
+// UnsafeVeriChatServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VeriChatServer will
+// result in compilation errors.
+type UnsafeVeriChatServer interface {
+	mustEmbedUnimplementedVeriChatServer()
+}
+
+func RegisterVeriChatServer(s grpc.ServiceRegistrar, srv VeriChatServer) {
+	// If the following call pancis, it indicates UnimplementedVeriChatServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&VeriChat_ServiceDesc, srv)
+}
+
+func _VeriChat_SubmitMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VeriChatServer).SubmitMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VeriChat_SubmitMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VeriChatServer).SubmitMessage(ctx, req.(*SubmitMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VeriChat_GetMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VeriChatServer).GetMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VeriChat_GetMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VeriChatServer).GetMessage(ctx, req.(*GetMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VeriChat_ListMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VeriChatServer).ListMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VeriChat_ListMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VeriChatServer).ListMessages(ctx, req.(*ListMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VeriChat_GetLatestRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLatestRootRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VeriChatServer).GetLatestRoot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VeriChat_GetLatestRoot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VeriChatServer).GetLatestRoot(ctx, req.(*GetLatestRootRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VeriChat_GetProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VeriChatServer).GetProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VeriChat_GetProof_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VeriChatServer).GetProof(ctx, req.(*GetProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VeriChat_WatchBatches_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchBatchesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VeriChatServer).WatchBatches(m, &grpc.GenericServerStream[WatchBatchesRequest, Batch]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VeriChat_WatchBatchesServer = grpc.ServerStreamingServer[Batch]
+
+// VeriChat_ServiceDesc is the grpc.ServiceDesc for VeriChat service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VeriChat_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "verichat.v1.VeriChat",
+	HandlerType: (*VeriChatServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitMessage",
+			Handler:    _VeriChat_SubmitMessage_Handler,
+		},
+		{
+			MethodName: "GetMessage",
+			Handler:    _VeriChat_GetMessage_Handler,
+		},
+		{
+			MethodName: "ListMessages",
+			Handler:    _VeriChat_ListMessages_Handler,
+		},
+		{
+			MethodName: "GetLatestRoot",
+			Handler:    _VeriChat_GetLatestRoot_Handler,
+		},
+		{
+			MethodName: "GetProof",
+			Handler:    _VeriChat_GetProof_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchBatches",
+			Handler:       _VeriChat_WatchBatches_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "verichat.proto",
+}
@@ -0,0 +1,33 @@
+// Package keyfile читает Ed25519 ключи сервиса и свидетелей из PEM файлов.
+package keyfile
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// LoadEd25519Key читает Ed25519 ключ из PEM файла PKCS#8
+// (openssl genpkey -algorithm ed25519 -out key.pem). what - чей это ключ
+// ("signing key", "witness key"), с него начинаются ошибки.
+func LoadEd25519Key(path, what string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", what, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s %s: no PRIVATE KEY PEM block", what, path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", what, path, err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s %s: %T is not ed25519", what, path, key)
+	}
+	return edKey, nil
+}
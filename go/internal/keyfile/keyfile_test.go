@@ -0,0 +1,48 @@
+package keyfile
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+	return path
+}
+
+func TestLoadEd25519Key(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	loaded, err := LoadEd25519Key(writePEM(t, "PRIVATE KEY", der), "signing key")
+	require.NoError(t, err)
+	assert.Equal(t, key, loaded)
+
+	_, err = LoadEd25519Key(filepath.Join(t.TempDir(), "missing.pem"), "signing key")
+	assert.ErrorContains(t, err, "signing key")
+
+	_, err = LoadEd25519Key(writePEM(t, "PUBLIC KEY", der), "witness key")
+	assert.ErrorContains(t, err, "witness key")
+	assert.ErrorContains(t, err, "no PRIVATE KEY PEM block")
+
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err = x509.MarshalPKCS8PrivateKey(ec)
+	require.NoError(t, err)
+	_, err = LoadEd25519Key(writePEM(t, "PRIVATE KEY", der), "tsa key")
+	assert.ErrorContains(t, err, "is not ed25519")
+}
@@ -147,6 +147,10 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 func InstrumentHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
+		if r.Pattern != "" {
+			// шаблон маршрута вместо пути, чтобы id не раздували метки
+			path = r.Pattern
+		}
 		method := r.Method
 		httpInFlight.Inc()
 		start := time.Now()
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"veriChat/go/internal/anchor"
+	"veriChat/go/internal/db"
+	"veriChat/go/pkg/verify"
+)
+
+const (
+	// anchorRetryBatch сколько ожидающих публикаций одного anchor берется за проход
+	anchorRetryBatch = 100
+	// maxAnchorBackoff предел паузы между попытками
+	maxAnchorBackoff = time.Hour
+	// maxRetryError сколько символов ошибки сохраняется в last_error
+	maxRetryError = 1024
+)
+
+func (s *MessageService) anchorNames() []string {
+	names := make([]string, len(s.cfg.Anchors))
+	for i, a := range s.cfg.Anchors {
+		names[i] = a.Name()
+	}
+	return names
+}
+
+// retryBackoff пауза перед следующей попыткой публикации или метки времени
+// после attempts неудачных
+func (s *MessageService) retryBackoff(attempts int) time.Duration {
+	d := s.cfg.AnchorRetryInterval
+	for i := 1; i < attempts && d < maxAnchorBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxAnchorBackoff)
+}
+
+// lastError текст ошибки для last_error
+func lastError(err error) string {
+	msg := err.Error()
+	if len(msg) > maxRetryError {
+		msg = msg[:maxRetryError]
+	}
+	return msg
+}
+
+// publishAnchor публикует батч в одном anchor и сохраняет квитанцию или время
+// следующей попытки. attempts - сколько попыток уже было.
+func (s *MessageService) publishAnchor(ctx context.Context, a anchor.Anchor, batch verify.Batch, attempts int) error {
+	receipt, err := a.Anchor(ctx, batch)
+	if err != nil {
+		next := time.Now().Add(s.retryBackoff(attempts + 1))
+		if dbErr := db.MarkAnchorFailed(ctx, batch.BatchID, a.Name(), lastError(err), next); dbErr != nil {
+			return dbErr
+		}
+		return fmt.Errorf("batch %d anchor %s: %w", batch.BatchID, a.Name(), err)
+	}
+	return db.MarkAnchorDone(ctx, batch.BatchID, a.Name(), receipt.Reference, receipt.Data)
+}
+
+// anchorBatch публикует только что закоммиченный батч во все anchor.
+// Неудачные публикации остаются pending и подхватываются anchorRetrier.
+func (s *MessageService) anchorBatch(ctx context.Context, batch verify.Batch) {
+	for _, a := range s.cfg.Anchors {
+		if err := s.publishAnchor(ctx, a, batch, 0); err != nil {
+			log.Printf("chat %d: %v", batch.ChatID, err)
+		}
+	}
+}
+
+// anchorRetrier раз в AnchorRetryInterval повторяет публикации, которые не удались
+// сразу после коммита (или не были сделаны из-за падения процесса)
+func (s *MessageService) anchorRetrier() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.AnchorRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.retryAnchors(context.Background())
+		}
+	}
+}
+
+// retryAnchors один проход повторов по всем anchor
+func (s *MessageService) retryAnchors(ctx context.Context) {
+	for _, a := range s.cfg.Anchors {
+		due, err := db.GetDueAnchors(ctx, a.Name(), time.Now(), anchorRetryBatch)
+		if err != nil {
+			log.Printf("anchor %s retry: %v", a.Name(), err)
+			continue
+		}
+		for _, p := range due {
+			batch, err := db.GetMerkleBatch(ctx, p.BatchID)
+			if err != nil {
+				log.Printf("anchor %s retry: batch %d: %v", a.Name(), p.BatchID, err)
+				continue
+			}
+			if batch == nil {
+				// публиковать нечего: без удаления строка возвращалась бы каждый проход
+				log.Printf("anchor %s retry: batch %d not found, dropping pending anchor", a.Name(), p.BatchID)
+				if err := db.DeletePendingAnchor(ctx, p.BatchID, a.Name()); err != nil {
+					log.Printf("anchor %s retry: batch %d: %v", a.Name(), p.BatchID, err)
+				}
+				continue
+			}
+			if err := s.publishAnchor(ctx, a, toVerifyBatch(batch), p.Attempts); err != nil {
+				log.Printf("anchor %s retry: %v (attempt %d)", a.Name(), err, p.Attempts+1)
+			}
+		}
+	}
+}
+
+// GetBatchAnchors возвращает квитанции и состояние публикаций батча
+func (s *MessageService) GetBatchAnchors(ctx context.Context, batchID int64) ([]*db.BatchAnchor, error) {
+	batch, err := db.GetMerkleBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, fmt.Errorf("batch %d: %w", batchID, ErrNotFound)
+	}
+	return db.GetBatchAnchors(ctx, batchID)
+}
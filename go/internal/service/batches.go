@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"veriChat/go/internal/db"
+)
+
+// BatchFilter - курсор страницы батчей чата
+type BatchFilter struct {
+	Cursor int64 // batch_id последнего батча предыдущей страницы, 0 - с начала
+	Desc   bool  // от новых к старым
+	Limit  int   // 0 - DefaultPageSize
+}
+
+// BatchPage - страница батчей чата
+type BatchPage struct {
+	Batches    []*db.MerkleBatch
+	NextCursor int64 // 0 - страница последняя
+}
+
+// ListChatBatches страница батчей чата в порядке batch_id
+func (s *MessageService) ListChatBatches(ctx context.Context, chatID int64, f BatchFilter) (*BatchPage, error) {
+	if f.Limit == 0 {
+		f.Limit = DefaultPageSize
+	}
+	if f.Limit < 0 || f.Limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit %d, want 1..%d", ErrInvalidArgument, f.Limit, MaxPageSize)
+	}
+	if f.Cursor < 0 {
+		return nil, fmt.Errorf("%w: cursor %d", ErrInvalidArgument, f.Cursor)
+	}
+
+	batches, err := db.ListChatBatches(ctx, chatID, f.Cursor, f.Desc, f.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &BatchPage{Batches: batches}
+	if len(batches) > f.Limit {
+		page.Batches = batches[:f.Limit]
+		page.NextCursor = page.Batches[f.Limit-1].BatchID
+	}
+	return page, nil
+}
+
+// BatchInfo - батч и сообщения, которые покрывает его root
+type BatchInfo struct {
+	Batch    *db.MerkleBatch
+	Messages []*db.Message // в порядке листьев дерева
+}
+
+// GetBatch возвращает батч вместе с его сообщениями
+func (s *MessageService) GetBatch(ctx context.Context, batchID int64) (*BatchInfo, error) {
+	batch, err := db.GetMerkleBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, fmt.Errorf("batch %d: %w", batchID, ErrNotFound)
+	}
+	messages, err := db.GetBatchMessages(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	return &BatchInfo{Batch: batch, Messages: messages}, nil
+}
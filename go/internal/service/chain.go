@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"veriChat/go/internal/db"
+	"veriChat/go/pkg/verify"
+)
+
+// ChainStatus - результат проверки цепочки батчей чата
+type ChainStatus struct {
+	ChatID        int64
+	Batches       int
+	OK            bool
+	BrokenBatchID int64 // первый батч с нарушенным звеном, 0 если цепочка цела
+	Reason        string
+}
+
+// toVerifyBatch запись merkle_batches в виде verify.Batch
+func toVerifyBatch(b *db.MerkleBatch) verify.Batch {
+	vb := verify.Batch{
+		BatchID:       b.BatchID,
+		ChatID:        b.ChatID,
+		RootHash:      b.RootHash,
+		FromMessageID: b.FromMessageID,
+		ToMessageID:   b.ToMessageID,
+		TreeVersion:   verify.TreeVersion(b.TreeVersion),
+		HashAlg:       verify.HashAlg(b.HashAlg),
+		ChatRoot:      b.ChatRoot,
+		PrevBatchHash: b.PrevBatchHash,
+		CreatedAt:     b.CreatedAt,
+	}
+	if b.ChatSize != nil {
+		vb.ChatSize = uint64(*b.ChatSize)
+	}
+	return vb
+}
+
+// prevBatchHashTx prev_batch_hash для следующего батча чата. Вызывается после
+// loadChatHistoryTx, строка аккумулятора уже заблокирована, поэтому два flush
+// одного чата не сошлются на один и тот же батч.
+func prevBatchHashTx(ctx context.Context, tx *sql.Tx, chatID int64) ([]byte, error) {
+	last, err := db.GetLastMerkleBatchTx(ctx, tx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if last == nil {
+		return verify.GenesisBatchHash(), nil
+	}
+	return verify.BatchHash(toVerifyBatch(last)), nil
+}
+
+// VerifyBatchChain проверяет цепочку prev_batch_hash всех батчей чата и то, что
+// последний батч совпадает с last_batch_id аккумулятора (хвост не удален).
+// Нарушение цепочки - не ошибка, а OK=false с первым нарушенным звеном.
+func (s *MessageService) VerifyBatchChain(ctx context.Context, chatID int64) (*ChainStatus, error) {
+	head, err := s.GetChatHead(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.GetChatBatches(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &ChainStatus{ChatID: chatID, Batches: len(rows), OK: true}
+	batches := make([]verify.Batch, len(rows))
+	for i, b := range rows {
+		batches[i] = toVerifyBatch(b)
+	}
+
+	var chainErr *verify.ChainError
+	switch err := verify.VerifyChain(batches); {
+	case errors.As(err, &chainErr):
+		status.OK = false
+		status.BrokenBatchID = chainErr.BatchID
+		status.Reason = chainErr.Reason
+	case err != nil:
+		return nil, err
+	case len(batches) == 0:
+		status.OK = false
+		status.BrokenBatchID = head.LastBatchID
+		status.Reason = "accumulator points to a missing batch"
+	case batches[len(batches)-1].BatchID != head.LastBatchID:
+		status.OK = false
+		status.BrokenBatchID = batches[len(batches)-1].BatchID
+		status.Reason = fmt.Sprintf("last batch, accumulator says %d", head.LastBatchID)
+	}
+	return status, nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"veriChat/go/internal/cgobridge"
+	"veriChat/go/internal/db"
+	"veriChat/go/pkg/verify"
+)
+
+var (
+	// ErrNotFound - запрошенной записи нет
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidArgument - параметры запроса вне допустимого диапазона
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// ChatHead - состояние всей истории чата: MMR над payload_hash всех
+// сбатченных сообщений в порядке (batch_id, message_id)
+type ChatHead struct {
+	ChatID      int64
+	Size        int64
+	Root        []byte
+	HashAlg     cgobridge.HashAlg
+	LastBatchID int64
+	UpdatedAt   time.Time
+}
+
+// GetChatHead возвращает root всей истории чата и ее размер
+func (s *MessageService) GetChatHead(ctx context.Context, chatID int64) (*ChatHead, error) {
+	acc, err := db.GetChatAccumulator(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if acc == nil {
+		return nil, fmt.Errorf("chat %d history: %w", chatID, ErrNotFound)
+	}
+	return &ChatHead{
+		ChatID:      acc.ChatID,
+		Size:        acc.Size,
+		Root:        acc.Root,
+		HashAlg:     cgobridge.HashAlg(acc.HashAlg),
+		LastBatchID: acc.LastBatchID,
+		UpdatedAt:   acc.UpdatedAt,
+	}, nil
+}
+
+// ConsistencyProof доказывает, что история чата размера ToSize продолжает историю
+// размера FromSize без переписывания (RFC 6962, проверка - verify.VerifyConsistency)
+type ConsistencyProof struct {
+	ChatID   int64
+	FromSize int64
+	ToSize   int64
+	FromRoot []byte
+	ToRoot   []byte
+	HashAlg  cgobridge.HashAlg
+	Proof    [][]byte
+}
+
+// GetConsistencyProof строит consistency proof между двумя размерами истории чата.
+// 0 < from <= to <= текущий размер истории.
+func (s *MessageService) GetConsistencyProof(ctx context.Context, chatID, from, to int64) (*ConsistencyProof, error) {
+	head, err := s.GetChatHead(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if from <= 0 || from > to || to > head.Size {
+		return nil, fmt.Errorf("%w: sizes %d..%d, chat %d has %d messages", ErrInvalidArgument, from, to, chatID, head.Size)
+	}
+
+	// история только растет, поэтому первые to листьев не зависят от параллельных flush
+	hashes, err := db.GetChatPayloadHashes(ctx, chatID, to)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(hashes)) != to {
+		return nil, fmt.Errorf("chat %d history has %d messages, accumulator says %d", chatID, len(hashes), head.Size)
+	}
+
+	proof, err := verify.ConsistencyProof(head.HashAlg, hashes, uint64(from))
+	if err != nil {
+		return nil, err
+	}
+	toRoot := verify.ChatRoot(head.HashAlg, hashes)
+	if to == head.Size && !bytes.Equal(toRoot, head.Root) {
+		return nil, fmt.Errorf("chat %d history root does not match accumulator", chatID)
+	}
+	return &ConsistencyProof{
+		ChatID:   chatID,
+		FromSize: from,
+		ToSize:   to,
+		FromRoot: verify.ChatRoot(head.HashAlg, hashes[:from]),
+		ToRoot:   toRoot,
+		HashAlg:  head.HashAlg,
+		Proof:    proof,
+	}, nil
+}
+
+// loadChatHistoryTx читает аккумулятор чата под блокировкой строки.
+// Если записи еще нет (чат до появления аккумулятора), собирает его из уже
+// сбатченных сообщений, чтобы root покрывал всю историю.
+func loadChatHistoryTx(ctx context.Context, tx *sql.Tx, chatID int64, alg cgobridge.HashAlg) (*verify.MMR, error) {
+	acc, err := db.GetChatAccumulatorTx(ctx, tx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if acc != nil {
+		history := &verify.MMR{Hash: cgobridge.HashAlg(acc.HashAlg), Size: uint64(acc.Size)}
+		if err := history.UnmarshalPeaks(acc.Peaks); err != nil {
+			return nil, fmt.Errorf("chat %d accumulator: %w", chatID, err)
+		}
+		return history, nil
+	}
+
+	hashes, err := db.GetChatPayloadHashesTx(ctx, tx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	history := &verify.MMR{Hash: alg}
+	for _, h := range hashes {
+		history.Append(h)
+	}
+	return history, nil
+}
+
+// saveChatHistoryTx сохраняет пики аккумулятора после батча batchID
+func saveChatHistoryTx(ctx context.Context, tx *sql.Tx, chatID int64, history *verify.MMR, batchID int64) error {
+	return db.UpsertChatAccumulatorTx(ctx, tx, &db.ChatAccumulator{
+		ChatID:      chatID,
+		Size:        int64(history.Size),
+		Peaks:       history.MarshalPeaks(),
+		Root:        history.Root(),
+		HashAlg:     int(history.Hash),
+		LastBatchID: batchID,
+	})
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"veriChat/go/internal/db"
+)
+
+var (
+	// ErrIdempotencyMismatch - Idempotency-Key уже использован с другим запросом
+	ErrIdempotencyMismatch = errors.New("idempotency key reused with a different request")
+	// ErrIdempotencyInFlight - запрос с этим Idempotency-Key еще выполняется
+	ErrIdempotencyInFlight = errors.New("request with this idempotency key is in progress")
+)
+
+// idempotencyReserveTTL сколько живет резерв ключа без продления. Пока запрос выполняется,
+// keepIdempotency продлевает его; если процесс упал до записи message_id, ключ освободится сам.
+const idempotencyReserveTTL = 30 * time.Second
+
+// idempotencyRefreshInterval как часто продлевается резерв (var - для тестов)
+var idempotencyRefreshInterval = idempotencyReserveTTL / 3
+
+// idempotencyStore - хранилище Idempotency-Key, в сервисе - Redis (redisIdempotency)
+type idempotencyStore interface {
+	Reserve(ctx context.Context, userID int64, key, fingerprint string, ttl time.Duration) (bool, *db.IdempotencyRecord, error)
+	Refresh(ctx context.Context, userID int64, key, fingerprint string, ttl time.Duration) (bool, error)
+	Set(ctx context.Context, userID int64, key, fingerprint string, messageID int64, ttl time.Duration) error
+	Release(ctx context.Context, userID int64, key string) error
+}
+
+// redisIdempotency - idempotencyStore поверх функций db
+type redisIdempotency struct{}
+
+func (redisIdempotency) Reserve(ctx context.Context, userID int64, key, fingerprint string, ttl time.Duration) (bool, *db.IdempotencyRecord, error) {
+	return db.ReserveIdempotency(ctx, userID, key, fingerprint, ttl)
+}
+
+func (redisIdempotency) Refresh(ctx context.Context, userID int64, key, fingerprint string, ttl time.Duration) (bool, error) {
+	return db.RefreshIdempotency(ctx, userID, key, fingerprint, ttl)
+}
+
+func (redisIdempotency) Set(ctx context.Context, userID int64, key, fingerprint string, messageID int64, ttl time.Duration) error {
+	return db.SetIdempotency(ctx, userID, key, fingerprint, messageID, ttl)
+}
+
+func (redisIdempotency) Release(ctx context.Context, userID int64, key string) error {
+	return db.ReleaseIdempotency(ctx, userID, key)
+}
+
+// requestFingerprint - sha256 полей запроса, которые определяют сообщение
+func requestFingerprint(chatID, userID int64, payload []byte) string {
+	h := sha256.New()
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], uint64(chatID))
+	binary.BigEndian.PutUint64(buf[8:], uint64(userID))
+	h.Write(buf[:])
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// reserveIdempotency занимает ключ пользователя под запрос. Возвращает message_id,
+// если запрос с тем же fingerprint уже выполнен, иначе 0 и ключ остается за вызывающим.
+func (s *MessageService) reserveIdempotency(ctx context.Context, userID int64, key, fingerprint string) (int64, error) {
+	ok, rec, err := s.idemp.Reserve(ctx, userID, key, fingerprint, idempotencyReserveTTL)
+	if err != nil {
+		return 0, fmt.Errorf("idempotency key: %w", err)
+	}
+	if ok {
+		return 0, nil
+	}
+	if rec == nil {
+		// запись истекла между SET NX и GET - клиент повторит запрос
+		return 0, ErrIdempotencyInFlight
+	}
+	if rec.Fingerprint != fingerprint {
+		return 0, ErrIdempotencyMismatch
+	}
+	if rec.MessageID == 0 {
+		return 0, ErrIdempotencyInFlight
+	}
+	return rec.MessageID, nil
+}
+
+// releaseIdempotency снимает резерв, чтобы повтор после ошибки не ждал idempotencyReserveTTL
+func (s *MessageService) releaseIdempotency(userID int64, key string) {
+	if key == "" {
+		return
+	}
+	if err := s.idemp.Release(context.Background(), userID, key); err != nil {
+		log.Printf("idempotency key %q of user %d: release failed: %v", key, userID, err)
+	}
+}
+
+// keepIdempotency продлевает резерв ключа каждые idempotencyRefreshInterval, пока не
+// вызвана возвращенная stop: резерв не истекает, даже если InsertMessage идет дольше
+// idempotencyReserveTTL. stop дожидается последнего продления.
+func (s *MessageService) keepIdempotency(userID int64, key, fingerprint string) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(idempotencyRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ok, err := s.idemp.Refresh(context.Background(), userID, key, fingerprint, idempotencyReserveTTL)
+				if err != nil {
+					log.Printf("idempotency key %q of user %d: refresh failed: %v", key, userID, err)
+					continue
+				}
+				if !ok {
+					// резерв потерян (истек, пока Redis был недоступен) - продлевать нечего
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// recordIdempotency записывает message_id под ключ вместо резерва. При ошибке резерв
+// остается до истечения idempotencyReserveTTL: повтор запроса в это время получит
+// ErrIdempotencyInFlight, а не второе сообщение.
+func (s *MessageService) recordIdempotency(ctx context.Context, userID int64, key, fingerprint string, messageID int64) error {
+	if err := s.idemp.Set(ctx, userID, key, fingerprint, messageID, s.cfg.IdempotencyTTL); err != nil {
+		return fmt.Errorf("SetIdempotency failed: %w", err)
+	}
+	return nil
+}
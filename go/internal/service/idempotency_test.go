@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"veriChat/go/internal/db"
+)
+
+// fakeIdempotency - idempotencyStore в памяти
+type fakeIdempotency struct {
+	mu        sync.Mutex
+	records   map[string]*db.IdempotencyRecord // "userID:key" -> запись
+	refreshes int
+	err       error // ошибка всех вызовов, если задана
+}
+
+func newFakeIdempotency() *fakeIdempotency {
+	return &fakeIdempotency{records: make(map[string]*db.IdempotencyRecord)}
+}
+
+func fakeKey(userID int64, key string) string {
+	return fmt.Sprintf("%d:%s", userID, key)
+}
+
+func (f *fakeIdempotency) Reserve(ctx context.Context, userID int64, key, fingerprint string, ttl time.Duration) (bool, *db.IdempotencyRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return false, nil, f.err
+	}
+	if rec, ok := f.records[fakeKey(userID, key)]; ok {
+		return false, rec, nil
+	}
+	f.records[fakeKey(userID, key)] = &db.IdempotencyRecord{Fingerprint: fingerprint}
+	return true, nil, nil
+}
+
+func (f *fakeIdempotency) Refresh(ctx context.Context, userID int64, key, fingerprint string, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refreshes++
+	if f.err != nil {
+		return false, f.err
+	}
+	rec, ok := f.records[fakeKey(userID, key)]
+	return ok && rec.MessageID == 0 && rec.Fingerprint == fingerprint, nil
+}
+
+func (f *fakeIdempotency) Set(ctx context.Context, userID int64, key, fingerprint string, messageID int64, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.records[fakeKey(userID, key)] = &db.IdempotencyRecord{MessageID: messageID, Fingerprint: fingerprint}
+	return nil
+}
+
+func (f *fakeIdempotency) Release(ctx context.Context, userID int64, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.records, fakeKey(userID, key))
+	return f.err
+}
+
+func (f *fakeIdempotency) refreshCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.refreshes
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	fp := requestFingerprint(1, 2, []byte("hi"))
+	assert.Len(t, fp, 64)
+	assert.Equal(t, fp, requestFingerprint(1, 2, []byte("hi")))
+
+	// каждое поле входит в fingerprint
+	assert.NotEqual(t, fp, requestFingerprint(3, 2, []byte("hi")))
+	assert.NotEqual(t, fp, requestFingerprint(1, 3, []byte("hi")))
+	assert.NotEqual(t, fp, requestFingerprint(1, 2, []byte("ho")))
+	// chat_id и user_id фиксированной длины: границы полей не сдвигаются в payload
+	assert.NotEqual(t, requestFingerprint(1, 2, nil), requestFingerprint(2, 1, nil))
+	assert.NotEqual(t, requestFingerprint(1, 2, []byte{}), requestFingerprint(1, 2, []byte{0}))
+}
+
+func TestReserveIdempotency(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeIdempotency()
+	s := &MessageService{cfg: Config{IdempotencyTTL: time.Hour}, idemp: store}
+	fp := requestFingerprint(1, 2, []byte("hi"))
+
+	// первый запрос занимает ключ
+	id, err := s.reserveIdempotency(ctx, 2, "k", fp)
+	require.NoError(t, err)
+	assert.Zero(t, id)
+
+	// повтор, пока первый выполняется
+	_, err = s.reserveIdempotency(ctx, 2, "k", fp)
+	assert.ErrorIs(t, err, ErrIdempotencyInFlight)
+
+	// тот же ключ с другим телом - и до, и после записи message_id
+	other := requestFingerprint(1, 2, []byte("other"))
+	_, err = s.reserveIdempotency(ctx, 2, "k", other)
+	assert.ErrorIs(t, err, ErrIdempotencyMismatch)
+
+	require.NoError(t, s.recordIdempotency(ctx, 2, "k", fp, 10))
+	_, err = s.reserveIdempotency(ctx, 2, "k", other)
+	assert.ErrorIs(t, err, ErrIdempotencyMismatch)
+
+	// повтор после записи получает прежний message_id
+	id, err = s.reserveIdempotency(ctx, 2, "k", fp)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), id)
+
+	// ключ действует в пределах пользователя
+	id, err = s.reserveIdempotency(ctx, 3, "k", fp)
+	require.NoError(t, err)
+	assert.Zero(t, id)
+
+	// после release ключ снова свободен
+	s.releaseIdempotency(3, "k")
+	id, err = s.reserveIdempotency(ctx, 3, "k", fp)
+	require.NoError(t, err)
+	assert.Zero(t, id)
+}
+
+func TestReserveIdempotencyErrors(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeIdempotency()
+	s := &MessageService{cfg: Config{IdempotencyTTL: time.Hour}, idemp: store}
+
+	// ключ истек между SET NX и GET
+	store.records[fakeKey(2, "gone")] = nil
+	_, err := s.reserveIdempotency(ctx, 2, "gone", "fp")
+	assert.ErrorIs(t, err, ErrIdempotencyInFlight)
+
+	store.err = errors.New("redis down")
+	_, err = s.reserveIdempotency(ctx, 2, "k", "fp")
+	assert.ErrorIs(t, err, store.err)
+	assert.ErrorIs(t, s.recordIdempotency(ctx, 2, "k", "fp", 10), store.err)
+}
+
+func TestKeepIdempotency(t *testing.T) {
+	defer func(d time.Duration) { idempotencyRefreshInterval = d }(idempotencyRefreshInterval)
+	idempotencyRefreshInterval = time.Millisecond
+
+	ctx := context.Background()
+	store := newFakeIdempotency()
+	s := &MessageService{cfg: Config{IdempotencyTTL: time.Hour}, idemp: store}
+	_, err := s.reserveIdempotency(ctx, 2, "k", "fp")
+	require.NoError(t, err)
+
+	// резерв продлевается, пока запрос выполняется
+	stop := s.keepIdempotency(2, "k", "fp")
+	require.Eventually(t, func() bool { return store.refreshCount() >= 3 }, time.Second, time.Millisecond)
+	stop()
+	n := store.refreshCount()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, n, store.refreshCount(), "refresh after stop")
+
+	// message_id записан - продление прекращается само
+	require.NoError(t, s.recordIdempotency(ctx, 2, "k", "fp", 10))
+	stop = s.keepIdempotency(2, "k", "fp")
+	require.Eventually(t, func() bool { return store.refreshCount() == n+1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, n+1, store.refreshCount())
+	stop()
+
+	// ошибки Redis не останавливают продление
+	store.err = errors.New("redis down")
+	stop = s.keepIdempotency(2, "k", "fp")
+	require.Eventually(t, func() bool { return store.refreshCount() >= n+3 }, time.Second, time.Millisecond)
+	stop()
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"veriChat/go/internal/db"
+)
+
+const (
+	// DefaultPageSize сообщений на странице истории, если размер не задан
+	DefaultPageSize = 50
+	// MaxPageSize предел размера страницы
+	MaxPageSize = 200
+)
+
+// Статус сообщения относительно батчей
+const (
+	StatusPending = "pending" // ждет flush в pending_batch
+	StatusBatched = "batched" // входит в батч с root и proof
+)
+
+// MessageStatus статус сообщения: StatusPending или StatusBatched
+func MessageStatus(m *db.Message) string {
+	if m.BatchID == nil {
+		return StatusPending
+	}
+	return StatusBatched
+}
+
+// messageStore - чтение сообщений для ListChatMessages, в сервисе - функции db (dbMessages)
+type messageStore interface {
+	GetMessage(ctx context.Context, messageID int64) (*db.Message, error)
+	ListChatMessages(ctx context.Context, q db.ChatMessagesQuery) ([]*db.Message, error)
+}
+
+type dbMessages struct{}
+
+func (dbMessages) GetMessage(ctx context.Context, messageID int64) (*db.Message, error) {
+	return db.GetMessage(ctx, messageID)
+}
+
+func (dbMessages) ListChatMessages(ctx context.Context, q db.ChatMessagesQuery) ([]*db.Message, error) {
+	return db.ListChatMessages(ctx, q)
+}
+
+// MessageFilter - фильтры и курсор страницы истории чата
+type MessageFilter struct {
+	UserID int64     // 0 - все пользователи
+	From   time.Time // created_at >= From
+	To     time.Time // created_at < To
+	Cursor int64     // message_id последнего сообщения предыдущей страницы, 0 - с начала
+	Desc   bool      // от новых к старым
+	Limit  int       // 0 - DefaultPageSize
+}
+
+// MessagePage - страница истории чата
+type MessagePage struct {
+	Messages   []*db.Message
+	NextCursor int64 // 0 - страница последняя
+}
+
+// ListChatMessages страница истории чата. Сообщения идут по (created_at, message_id),
+// для сообщений, принятых сервисом, это порядок message_id.
+func (s *MessageService) ListChatMessages(ctx context.Context, chatID int64, f MessageFilter) (*MessagePage, error) {
+	if f.Limit == 0 {
+		f.Limit = DefaultPageSize
+	}
+	if f.Limit < 0 || f.Limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit %d, want 1..%d", ErrInvalidArgument, f.Limit, MaxPageSize)
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return nil, fmt.Errorf("%w: empty time range %s..%s", ErrInvalidArgument, f.From, f.To)
+	}
+
+	q := db.ChatMessagesQuery{
+		ChatID: chatID,
+		UserID: f.UserID,
+		From:   f.From,
+		To:     f.To,
+		Desc:   f.Desc,
+		Limit:  f.Limit + 1, // лишнее сообщение - признак следующей страницы
+	}
+	if f.Cursor != 0 {
+		after, err := s.messages.GetMessage(ctx, f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if after == nil || after.ChatID != chatID {
+			return nil, fmt.Errorf("%w: cursor %d is not a message of chat %d", ErrInvalidArgument, f.Cursor, chatID)
+		}
+		q.After = after
+	}
+
+	messages, err := s.messages.ListChatMessages(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	page := &MessagePage{Messages: messages}
+	if len(messages) > f.Limit {
+		page.Messages = messages[:f.Limit]
+		page.NextCursor = page.Messages[f.Limit-1].MessageID
+	}
+	return page, nil
+}
+
+// MessageInfo - сообщение и, если оно уже в батче, его inclusion proof
+type MessageInfo struct {
+	Message *db.Message
+	Proof   *MessageProof // nil, пока сообщение StatusPending
+}
+
+// GetMessage возвращает сообщение по message_id. Для сообщения в батче к нему
+// прикладывается proof относительно root батча.
+func (s *MessageService) GetMessage(ctx context.Context, messageID int64) (*MessageInfo, error) {
+	msg, err := db.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, fmt.Errorf("message %d: %w", messageID, ErrNotFound)
+	}
+	info := &MessageInfo{Message: msg}
+	if msg.BatchID == nil {
+		return info, nil
+	}
+	if info.Proof, err = s.batchProof(ctx, *msg.BatchID, messageID); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
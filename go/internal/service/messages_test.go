@@ -0,0 +1,156 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"veriChat/go/internal/db"
+)
+
+// fakeMessages - messageStore над сообщениями в памяти, с тем же порядком и курсором, что у db
+type fakeMessages struct {
+	all     []*db.Message
+	queries []db.ChatMessagesQuery
+}
+
+func (f *fakeMessages) GetMessage(ctx context.Context, messageID int64) (*db.Message, error) {
+	for _, m := range f.all {
+		if m.MessageID == messageID {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeMessages) ListChatMessages(ctx context.Context, q db.ChatMessagesQuery) ([]*db.Message, error) {
+	f.queries = append(f.queries, q)
+	// порядок выдачи: (created_at, message_id), в Desc - обратный
+	order := func(a, b *db.Message) int {
+		c := a.CreatedAt.Compare(b.CreatedAt)
+		if c == 0 {
+			c = cmp.Compare(a.MessageID, b.MessageID)
+		}
+		if q.Desc {
+			c = -c
+		}
+		return c
+	}
+	var out []*db.Message
+	for _, m := range f.all {
+		switch {
+		case m.ChatID != q.ChatID,
+			q.UserID != 0 && m.UserID != q.UserID,
+			!q.From.IsZero() && m.CreatedAt.Before(q.From),
+			!q.To.IsZero() && !m.CreatedAt.Before(q.To),
+			q.After != nil && order(m, q.After) <= 0:
+			continue
+		}
+		out = append(out, m)
+	}
+	slices.SortFunc(out, order)
+	if len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
+
+// newFakeMessages - 5 сообщений чата 1 (id 1..5, у 2 и 3 одно created_at) и одно сообщение чата 2
+func newFakeMessages() *fakeMessages {
+	t0 := time.UnixMilli(1700000000000)
+	at := func(sec int) time.Time { return t0.Add(time.Duration(sec) * time.Second) }
+	return &fakeMessages{all: []*db.Message{
+		{MessageID: 1, ChatID: 1, UserID: 10, CreatedAt: at(0)},
+		{MessageID: 2, ChatID: 1, UserID: 11, CreatedAt: at(1)},
+		{MessageID: 3, ChatID: 1, UserID: 10, CreatedAt: at(1)},
+		{MessageID: 6, ChatID: 2, UserID: 10, CreatedAt: at(2)},
+		{MessageID: 4, ChatID: 1, UserID: 10, CreatedAt: at(3)},
+		{MessageID: 5, ChatID: 1, UserID: 11, CreatedAt: at(4)},
+	}}
+}
+
+func messageIDs(messages []*db.Message) []int64 {
+	ids := make([]int64, len(messages))
+	for i, m := range messages {
+		ids[i] = m.MessageID
+	}
+	return ids
+}
+
+func TestListChatMessagesPages(t *testing.T) {
+	t0 := time.UnixMilli(1700000000000)
+	cases := []struct {
+		name  string
+		f     MessageFilter
+		pages [][]int64
+	}{
+		{"asc", MessageFilter{Limit: 2}, [][]int64{{1, 2}, {3, 4}, {5}}},
+		{"desc", MessageFilter{Limit: 2, Desc: true}, [][]int64{{5, 4}, {3, 2}, {1}}},
+		// последняя страница ровно из Limit сообщений - без next_cursor
+		{"exact pages", MessageFilter{Limit: 5}, [][]int64{{1, 2, 3, 4, 5}}},
+		{"page boundary", MessageFilter{Limit: 4, Desc: true}, [][]int64{{5, 4, 3, 2}, {1}}},
+		{"user", MessageFilter{Limit: 2, UserID: 10}, [][]int64{{1, 3}, {4}}},
+		{"time range", MessageFilter{Limit: 1, Desc: true, From: t0.Add(time.Second), To: t0.Add(4 * time.Second)}, [][]int64{{4}, {3}, {2}}},
+		{"default limit", MessageFilter{}, [][]int64{{1, 2, 3, 4, 5}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newFakeMessages()
+			s := &MessageService{messages: store}
+			f := tc.f
+			for i, want := range tc.pages {
+				page, err := s.ListChatMessages(context.Background(), 1, f)
+				require.NoError(t, err)
+				assert.Equal(t, want, messageIDs(page.Messages), "page %d", i)
+
+				// сервис просит на одно сообщение больше - признак следующей страницы
+				limit := f.Limit
+				if limit == 0 {
+					limit = DefaultPageSize
+				}
+				assert.Equal(t, limit+1, store.queries[len(store.queries)-1].Limit)
+
+				if i == len(tc.pages)-1 {
+					assert.Zero(t, page.NextCursor, "last page")
+					break
+				}
+				require.Equal(t, want[len(want)-1], page.NextCursor)
+				f.Cursor = page.NextCursor
+			}
+		})
+	}
+}
+
+func TestListChatMessagesInvalid(t *testing.T) {
+	t0 := time.UnixMilli(1700000000000)
+	cases := []struct {
+		name string
+		f    MessageFilter
+	}{
+		{"negative limit", MessageFilter{Limit: -1}},
+		{"limit above max", MessageFilter{Limit: MaxPageSize + 1}},
+		{"empty time range", MessageFilter{From: t0, To: t0}},
+		{"reversed time range", MessageFilter{From: t0.Add(time.Second), To: t0}},
+		{"cursor of other chat", MessageFilter{Cursor: 6}},
+		{"unknown cursor", MessageFilter{Cursor: 99}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newFakeMessages()
+			s := &MessageService{messages: store}
+			_, err := s.ListChatMessages(context.Background(), 1, tc.f)
+			assert.ErrorIs(t, err, ErrInvalidArgument)
+			assert.Empty(t, store.queries)
+		})
+	}
+
+	s := &MessageService{messages: newFakeMessages()}
+	page, err := s.ListChatMessages(context.Background(), 1, MessageFilter{Limit: MaxPageSize})
+	require.NoError(t, err)
+	assert.Len(t, page.Messages, 5)
+}
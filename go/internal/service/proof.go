@@ -0,0 +1,209 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"veriChat/go/internal/cgobridge"
+	"veriChat/go/internal/db"
+	"veriChat/go/pkg/verify"
+)
+
+// ErrNotBatched - сообщение еще не попало в батч, proof пока нет
+var ErrNotBatched = errors.New("message is not batched yet")
+
+// MessageProof - inclusion proof сообщения в дерево его батча
+type MessageProof struct {
+	MessageID   int64
+	BatchID     int64
+	Index       int // позиция в батче (листья по message_id)
+	Root        []byte
+	TreeVersion cgobridge.TreeVersion
+	HashAlg     cgobridge.HashAlg
+	Proof       *verify.Proof
+}
+
+// GetMessageProof возвращает inclusion proof сообщения. Дерево батча берется из
+// Redis, при промахе пересобирается по сообщениям батча и кешируется.
+func (s *MessageService) GetMessageProof(ctx context.Context, messageID int64) (*MessageProof, error) {
+	msg, err := db.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, fmt.Errorf("message %d: %w", messageID, ErrNotFound)
+	}
+	if msg.BatchID == nil {
+		return nil, fmt.Errorf("message %d: %w", messageID, ErrNotBatched)
+	}
+	return s.batchProof(ctx, *msg.BatchID, messageID)
+}
+
+// batchProof proof сообщения messageID в батче batchID
+func (s *MessageService) batchProof(ctx context.Context, batchID, messageID int64) (*MessageProof, error) {
+	batch, err := db.GetMerkleBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, fmt.Errorf("batch %d: %w", batchID, ErrNotFound)
+	}
+	index, err := db.GetBatchMessageIndex(ctx, batchID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := s.batchTree(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := tree.Proof(index)
+	if err != nil {
+		return nil, fmt.Errorf("batch %d proof: %w", batchID, err)
+	}
+	return &MessageProof{
+		MessageID:   messageID,
+		BatchID:     batchID,
+		Index:       index,
+		Root:        batch.RootHash,
+		TreeVersion: cgobridge.TreeVersion(batch.TreeVersion),
+		HashAlg:     cgobridge.HashAlg(batch.HashAlg),
+		Proof:       proof,
+	}, nil
+}
+
+// batchTree дерево батча из кеша Redis, из batch_trees или пересобранное по его
+// сообщениям. Root дерева сверяется с root_hash батча.
+func (s *MessageService) batchTree(ctx context.Context, batch *db.MerkleBatch) (*cgobridge.Tree, error) {
+	if data, err := db.GetBatchTree(ctx, batch.BatchID); err == nil && data != nil {
+		if tree := decodeBatchTree(data, batch); tree != nil {
+			return tree, nil
+		}
+		// битая запись кеша - читаем из MySQL
+	}
+	if data, err := db.GetStoredBatchTree(ctx, batch.BatchID); err == nil && data != nil {
+		if tree := decodeBatchTree(data, batch); tree != nil {
+			s.cacheTree(ctx, batch.BatchID, tree)
+			return tree, nil
+		}
+	}
+
+	// батч до batch_trees или битая запись - пересобираем
+	payloads, err := db.GetBatchPayloads(ctx, batch.BatchID)
+	if err != nil {
+		return nil, err
+	}
+	opts := cgobridge.Options{
+		TreeVersion: cgobridge.TreeVersion(batch.TreeVersion),
+		Hash:        cgobridge.HashAlg(batch.HashAlg),
+		Workers:     s.cfg.MerkleWorkers,
+	}
+	tree, err := cgobridge.MerkleTree(payloads, opts)
+	if err != nil {
+		return nil, fmt.Errorf("batch %d MerkleTree failed: %w", batch.BatchID, err)
+	}
+	if !bytes.Equal(tree.Root(), batch.RootHash) {
+		return nil, fmt.Errorf("batch %d: messages do not match stored root: %w", batch.BatchID, verify.ErrRootMismatch)
+	}
+	if data, err := tree.MarshalBinary(); err == nil {
+		if err := db.InsertBatchTree(ctx, batch.BatchID, data); err != nil {
+			// следующий proof батча пересоберет дерево еще раз
+			log.Printf("batch %d: save rebuilt tree: %v", batch.BatchID, err)
+		}
+	}
+	s.cacheTree(ctx, batch.BatchID, tree)
+	return tree, nil
+}
+
+// decodeBatchTree разбирает сохраненное дерево батча, nil - запись битая или не от этого батча
+func decodeBatchTree(data []byte, batch *db.MerkleBatch) *cgobridge.Tree {
+	tree := &cgobridge.Tree{}
+	if err := tree.UnmarshalBinary(data); err != nil || !bytes.Equal(tree.Root(), batch.RootHash) {
+		return nil
+	}
+	return tree
+}
+
+// saveBatchTreeTx сохраняет дерево батча в batch_trees в рамках tx
+func saveBatchTreeTx(ctx context.Context, tx *sql.Tx, batchID int64, tree *cgobridge.Tree) error {
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("batch %d tree: %w", batchID, err)
+	}
+	return db.InsertBatchTreeTx(ctx, tx, batchID, data)
+}
+
+// cacheTree кладет дерево батча в Redis на TreeCacheTTL
+func (s *MessageService) cacheTree(ctx context.Context, batchID int64, tree *cgobridge.Tree) {
+	data, err := tree.MarshalBinary()
+	if err != nil {
+		return
+	}
+	if err := db.SetBatchTree(ctx, batchID, data, s.cfg.TreeCacheTTL); err != nil {
+		log.Printf("batch %d: cache tree: %v", batchID, err)
+	}
+}
+
+// BatchMultiProof - один proof для нескольких сообщений батча
+type BatchMultiProof struct {
+	BatchID     int64
+	MessageIDs  []int64 // по возрастанию, в порядке MultiProof.Indices
+	Root        []byte
+	TreeVersion cgobridge.TreeVersion
+	HashAlg     cgobridge.HashAlg
+	Proof       *cgobridge.MultiProof
+}
+
+// GetBatchMultiProof строит multiproof для сообщений messageIDs батча batchID
+// по закешированному дереву батча
+func (s *MessageService) GetBatchMultiProof(ctx context.Context, batchID int64, messageIDs []int64) (*BatchMultiProof, error) {
+	if len(messageIDs) == 0 {
+		return nil, fmt.Errorf("%w: no message ids", ErrInvalidArgument)
+	}
+	batch, err := db.GetMerkleBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, fmt.Errorf("batch %d: %w", batchID, ErrNotFound)
+	}
+	ids, err := db.GetBatchMessageIDs(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make([]int, len(messageIDs))
+	for i, id := range messageIDs {
+		idx, ok := slices.BinarySearch(ids, id)
+		if !ok {
+			return nil, fmt.Errorf("%w: message %d is not in batch %d", ErrInvalidArgument, id, batchID)
+		}
+		indices[i] = idx
+	}
+
+	tree, err := s.batchTree(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	mp, err := tree.MultiProof(indices)
+	if err != nil {
+		return nil, fmt.Errorf("batch %d multiproof: %w", batchID, err)
+	}
+
+	proven := make([]int64, len(mp.Indices))
+	for i, idx := range mp.Indices {
+		proven[i] = ids[idx]
+	}
+	return &BatchMultiProof{
+		BatchID:     batchID,
+		MessageIDs:  proven,
+		Root:        batch.RootHash,
+		TreeVersion: cgobridge.TreeVersion(batch.TreeVersion),
+		HashAlg:     cgobridge.HashAlg(batch.HashAlg),
+		Proof:       mp,
+	}, nil
+}
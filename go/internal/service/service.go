@@ -1,12 +1,19 @@
 package service
 
 import (
+	"bytes"
 	"context"
-	"crypto/sha256"
+	"crypto/ed25519"
+	"database/sql"
 	"fmt"
+	"log"
+	"slices"
+	"veriChat/go/internal/anchor"
 	"veriChat/go/internal/cgobridge"
 	"veriChat/go/internal/db"
 	"veriChat/go/internal/metrics"
+	"veriChat/go/internal/tsa"
+	"veriChat/go/pkg/verify"
 
 	"sync"
 	"time"
@@ -16,10 +23,22 @@ import (
 
 // Config для сервиса
 type Config struct {
-	BatchSize    int
-	BatchTimeout time.Duration // время ожидания перед flush
-	LockTTL      time.Duration // TTL для redis lock
-	RedisClient  *redis.Client
+	BatchSize           int
+	BatchTimeout        time.Duration // время ожидания перед flush
+	LockTTL             time.Duration // TTL для redis lock
+	RedisClient         *redis.Client
+	TreeVersion         cgobridge.TreeVersion // версия дерева для новых батчей (по умолчанию RFC 6962)
+	MerkleWorkers       int                   // потоки engine на один flush (группу чатов во flusher)
+	HashAlg             cgobridge.HashAlg     // алгоритм для новых чатов (по умолчанию SHA-256), старые остаются на своем
+	FlushGroupSize      int                   // чатов на один вызов engine во flusher (по умолчанию 256)
+	FlushGroupBytes     int                   // предел payload в памяти на один вызов engine во flusher (по умолчанию 64 MiB)
+	TreeCacheTTL        time.Duration         // сколько дерево батча живет в Redis для proof (по умолчанию 24h), дальше - из batch_trees
+	SigningKey          ed25519.PrivateKey    // ключ подписи tree head (keyfile.LoadEd25519Key), nil - без подписи
+	TSA                 tsa.Authority         // метки времени root батчей, nil - без меток
+	Anchors             []anchor.Anchor       // куда публикуются батчи после коммита
+	AnchorRetryInterval time.Duration         // пауза перед повтором неудачной публикации или метки времени (по умолчанию 30s)
+	Witnesses           []ed25519.PublicKey   // свидетели, чьи подписи под tree head принимаются
+	IdempotencyTTL      time.Duration         // сколько хранится Idempotency-Key (по умолчанию 24h)
 }
 
 // MessageService управляет поступлением сообщений и батчингом
@@ -30,18 +49,55 @@ type MessageService struct {
 	stopCh      chan struct{}
 	wg          sync.WaitGroup
 	redis       *redis.Client
+	chatAlgs    map[int64]cgobridge.HashAlg // chatID -> алгоритм чата (не меняется)
+	idemp       idempotencyStore
+	batches     batchStore
+	messages    messageStore
 }
 
 // NewMessageService создает сервис и стартует background flusher
 func NewMessageService(cfg Config) *MessageService {
+	if cfg.TreeVersion == 0 {
+		cfg.TreeVersion = cgobridge.TreeRFC6962
+	}
+	if cfg.HashAlg == 0 {
+		cfg.HashAlg = cgobridge.HashSHA256
+	}
+	if cfg.FlushGroupSize <= 0 {
+		cfg.FlushGroupSize = 256
+	}
+	if cfg.FlushGroupBytes <= 0 {
+		cfg.FlushGroupBytes = 64 << 20
+	}
+	if cfg.TreeCacheTTL <= 0 {
+		cfg.TreeCacheTTL = 24 * time.Hour
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		cfg.IdempotencyTTL = 24 * time.Hour
+	}
+	if cfg.AnchorRetryInterval <= 0 {
+		cfg.AnchorRetryInterval = 30 * time.Second
+	}
 	s := &MessageService{
 		cfg:         cfg,
 		activeChats: make(map[int64]time.Time),
 		stopCh:      make(chan struct{}),
 		redis:       cfg.RedisClient,
+		chatAlgs:    make(map[int64]cgobridge.HashAlg),
+		idemp:       redisIdempotency{},
+		batches:     dbBatches{},
+		messages:    dbMessages{},
 	}
 	s.wg.Add(1)
 	go s.flusher()
+	if len(cfg.Anchors) > 0 {
+		s.wg.Add(1)
+		go s.anchorRetrier()
+	}
+	if cfg.TSA != nil {
+		s.wg.Add(1)
+		go s.timestampRetrier()
+	}
 	return s
 }
 
@@ -61,51 +117,63 @@ func (s *MessageService) Shutdown(ctx context.Context) {
 
 // SubmitMessage сохраняет сообщение, пушит его в очередь для батчей и возвращает message_id.
 // Алгоритм:
-// 1. Проверка idempotency в Redis.
+// 1. Резерв Idempotency-Key пользователя в Redis (повтор с тем же телом получает прежний message_id).
 // 2. Insert в messages (MySQL).
 // 3. RPUSH message_id в Redis list chat:{chat_id}:pending_batch
-// 4. mark active 
+// 4. mark active
 // 5. len >= batchSize -> flush.
 func (s *MessageService) SubmitMessage(ctx context.Context, chatID, userID int64, payload []byte, idempKey string) (int64, error) {
 	start := time.Now()
 	err := error(nil)
-	defer func(){
+	defer func() {
 		metrics.IncMessagesProcessed()
 		metrics.ObserveBusiness("ProcessMessage", start, err)
 	}()
-	// 1) Idempotency
+	// 1) Idempotency: ключ пользователя резервируется и продлевается до записи message_id
+	var fingerprint string
+	var existingID int64
+	stopKeep := func() {}
 	if idempKey != "" {
-		val, err := db.RedisClient.Get(ctx, "idemp:"+idempKey).Result()
-		if err == nil && val != "" {
-			// уже есть
-			var existingID int64
-			_, err := fmt.Sscanf(val, "%d", &existingID)
-			if err == nil {
-				return existingID, nil
-			}
-		}
-		if err != nil && err != redis.Nil {
-			// TODO: continue but log
+		fingerprint = requestFingerprint(chatID, userID, payload)
+		existingID, err = s.reserveIdempotency(ctx, userID, idempKey, fingerprint)
+		if err != nil || existingID != 0 {
+			return existingID, err
 		}
+		stopKeep = s.keepIdempotency(userID, idempKey, fingerprint)
 	}
 
 	// 2) Insert into MySQL
-	h := sha256.Sum256(payload)
+	alg, err := s.chatHashAlg(ctx, chatID)
+	if err != nil {
+		stopKeep()
+		s.releaseIdempotency(userID, idempKey)
+		return 0, err
+	}
 	msg := &db.Message{
 		ChatID:      chatID,
 		UserID:      userID,
 		Payload:     payload,
-		PayloadHash: h[:],
+		PayloadHash: alg.Sum(payload),
 		BatchID:     nil,
 	}
 	id, err := db.InsertMessage(ctx, msg)
+	stopKeep()
 	if err != nil {
+		// резерв снимается, только если MySQL точно отверг вставку; иначе сообщение могло
+		// сохраниться, и резерв истекает сам через idempotencyReserveTTL - до этого повтор
+		// получит ErrIdempotencyInFlight, а не второе сообщение
+		if db.Rejected(err) {
+			s.releaseIdempotency(userID, idempKey)
+		}
 		return 0, fmt.Errorf("InsertMessage failed: %w", err)
 	}
 
-	// 3) Set idempotency -> message id
+	// 3) Set idempotency -> message id. Сообщение уже сохранено, поэтому ошибка только
+	// пишется в лог; ctx клиента может быть уже отменен - запись идет без него.
 	if idempKey != "" {
-		_ = db.RedisClient.Set(ctx, "idemp:"+idempKey, fmt.Sprintf("%d", id), 24*time.Hour).Err()
+		if err := s.recordIdempotency(context.WithoutCancel(ctx), userID, idempKey, fingerprint, id); err != nil {
+			log.Printf("message %d: idempotency key %q of user %d: %v", id, idempKey, userID, err)
+		}
 	}
 
 	// 4) Push to pending batch list
@@ -122,34 +190,47 @@ func (s *MessageService) SubmitMessage(ctx context.Context, chatID, userID int64
 	// 6) quick check length and flush if threshold reached
 	if l, _ := db.RedisClient.LLen(ctx, fmt.Sprintf("chat:%d:pending_batch", chatID)).Result(); l >= int64(s.cfg.BatchSize) {
 		go func() {
-			// TODO: process error
-			_ = s.flushChat(context.Background(), chatID)
+			start := time.Now()
+			observeFlush(chatID, start, s.flushChat(context.Background(), chatID))
 		}()
 	}
 
 	return id, nil
 }
 
-// GetLatestRoot получает root из Redis или из MySQL
-func (s *MessageService) GetLatestRoot(ctx context.Context, chatID int64) ([]byte, error) {
-	key := fmt.Sprintf("chat:%d:latest_root", chatID)
-	b, err := db.RedisClient.Get(ctx, key).Bytes()
-	if err == nil {
-		return b, nil
-	}
-	if err != redis.Nil {
-		// Redis error -> try fallback
+// GetLatestRoot возвращает root последнего батча чата вместе с root и размером истории
+// чата после него (chat_accumulators). Берется из Redis, при промахе - из MySQL.
+func (s *MessageService) GetLatestRoot(ctx context.Context, chatID int64) (*db.LatestRoot, error) {
+	latest, err := db.GetLatestBatch(ctx, chatID)
+	if err != nil {
+		// Redis недоступен или запись битая - root берется из MySQL
+		log.Printf("chat %d: latest batch from redis: %v", chatID, err)
+	} else if latest != nil {
+		return latest, nil
 	}
 
-	// Fallback
-	row := db.DB.QueryRowContext(ctx, `SELECT root_hash FROM merkle_batches WHERE chat_id = ? ORDER BY created_at DESC LIMIT 1`, chatID)
-	var root []byte
-	if err := row.Scan(&root); err != nil {
+	batch, err := db.GetLastMerkleBatch(ctx, chatID)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get latest root: %w", err)
 	}
+	if batch == nil {
+		return nil, fmt.Errorf("chat %d root: %w", chatID, ErrNotFound)
+	}
+	latest = newLatestRoot(batch)
+	if err := db.SetLatestBatch(ctx, latest); err != nil {
+		log.Printf("chat %d: cache latest batch: %v", chatID, err)
+	}
+	return latest, nil
+}
 
-	_ = db.RedisClient.Set(ctx, key, root, 0).Err()
-	return root, nil
+func newLatestRoot(b *db.MerkleBatch) *db.LatestRoot {
+	return &db.LatestRoot{
+		ChatID:   b.ChatID,
+		BatchID:  b.BatchID,
+		Root:     b.RootHash,
+		ChatSize: b.ChatSize,
+		ChatRoot: b.ChatRoot,
+	}
 }
 
 func (s *MessageService) flusher() {
@@ -173,15 +254,71 @@ func (s *MessageService) flusher() {
 			}
 			s.mu.Unlock()
 
-			for _, chatID := range chatsToFlush {
-				_ = s.flushChat(context.Background(), chatID)
+			// тихие чаты flush`атся группами: один вызов engine на группу
+			for len(chatsToFlush) > 0 {
+				n := min(len(chatsToFlush), s.cfg.FlushGroupSize)
+				s.flushChats(context.Background(), chatsToFlush[:n])
+				chatsToFlush = chatsToFlush[n:]
 			}
 		}
 	}
 }
 
+// chatHashAlg возвращает алгоритм чата. Новый чат закрепляется за cfg.HashAlg.
+func (s *MessageService) chatHashAlg(ctx context.Context, chatID int64) (cgobridge.HashAlg, error) {
+	s.mu.Lock()
+	alg, ok := s.chatAlgs[chatID]
+	s.mu.Unlock()
+	if ok {
+		return alg, nil
+	}
+
+	v, err := db.EnsureChat(ctx, chatID, int(s.cfg.HashAlg))
+	if err != nil {
+		return 0, fmt.Errorf("EnsureChat failed: %w", err)
+	}
+	alg = cgobridge.HashAlg(v)
+
+	s.mu.Lock()
+	s.chatAlgs[chatID] = alg
+	s.mu.Unlock()
+	return alg, nil
+}
+
+// merkleOptions параметры engine для новых батчей чата с алгоритмом alg
+func (s *MessageService) merkleOptions(alg cgobridge.HashAlg) cgobridge.Options {
+	return cgobridge.Options{
+		TreeVersion: s.cfg.TreeVersion,
+		Workers:     s.cfg.MerkleWorkers,
+		Hash:        alg,
+	}
+}
+
+// streamBatchTreeTx строит дерево батча потоком сообщений ids и дописывает их
+// payload_hash в history. Payload в памяти не копятся, хранятся только узлы дерева.
+func (s *MessageService) streamBatchTreeTx(ctx context.Context, tx *sql.Tx, ids []int64, alg cgobridge.HashAlg, history *verify.MMR) (*cgobridge.Tree, error) {
+	builder, err := cgobridge.NewTreeBuilder(s.merkleOptions(alg))
+	if err != nil {
+		return nil, fmt.Errorf("NewTreeBuilder failed: %w", err)
+	}
+	defer builder.Free()
+
+	err = db.StreamMessagePayloadsTx(ctx, tx, ids, func(_ int64, payload, hash []byte) error {
+		history.Append(hash)
+		return builder.Append(payload)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := builder.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("MerkleTree failed: %w", err)
+	}
+	return tree, nil
+}
 
-// простой мьютекс 
+// простой мьютекс
 func (s *MessageService) acquireLock(ctx context.Context, chatID int64) (bool, error) {
 	key := fmt.Sprintf("lock:chat:%d", chatID)
 	ok, err := db.RedisClient.SetNX(ctx, key, "1", s.cfg.LockTTL).Result()
@@ -193,22 +330,32 @@ func (s *MessageService) releaseLock(ctx context.Context, chatID int64) {
 	_ = db.RedisClient.Del(ctx, key).Err()
 }
 
+// requeue возвращает ids в начало pending_batch после неудачного flush
+func (s *MessageService) requeue(ctx context.Context, key string, ids []int64) {
+	for i := len(ids) - 1; i >= 0; i-- {
+		_ = db.RedisClient.LPush(ctx, key, ids[i]).Err()
+	}
+}
 
+// pendingBatch - сообщения чата, снятые из pending_batch под блокировкой чата
+type pendingBatch struct {
+	chatID int64
+	key    string
+	ids    []int64 // по возрастанию message_id
+	alg    cgobridge.HashAlg
+}
 
-// Вызывается, когда batch заполнился. 
-// 1. По ключу pending_batch`а берет последние BatchSize сообщений
-// 2. Отправляет их payloads в c++ engine, который строит merkle tree и возвращает root
-// 3. Сохраняет root в БД и проставляет batch_id для сообщений
-// 4. И устанавливает latest root для чата
-func (s *MessageService) flushChat(ctx context.Context, chatID int64) error {
+// takeBatch берет блокировку чата и снимает из pending_batch до BatchSize сообщений.
+// Возвращает nil, если чат уже flush`ится или сообщений нет. Для не-nil батча
+// блокировку снимает вызывающий через releaseLock.
+func (s *MessageService) takeBatch(ctx context.Context, chatID int64) (*pendingBatch, error) {
 	ok, err := s.acquireLock(ctx, chatID)
 	if err != nil {
-		return fmt.Errorf("acquire lock error: %w", err)
+		return nil, fmt.Errorf("acquire lock error: %w", err)
 	}
 	if !ok {
-		return nil
+		return nil, nil
 	}
-	defer s.releaseLock(ctx, chatID)
 
 	key := fmt.Sprintf("chat:%d:pending_batch", chatID)
 	ids := make([]int64, 0, s.cfg.BatchSize)
@@ -219,77 +366,318 @@ func (s *MessageService) flushChat(ctx context.Context, chatID int64) error {
 		}
 		if err != nil {
 			// TODO: process error
-			return fmt.Errorf("LPop error: %w", err)
+			s.requeue(ctx, key, ids)
+			s.releaseLock(ctx, chatID)
+			return nil, fmt.Errorf("LPop error: %w", err)
 		}
 		var id int64
 		fmt.Sscanf(val, "%d", &id)
 		ids = append(ids, id)
 	}
 	if len(ids) == 0 {
-		return nil
+		s.releaseLock(ctx, chatID)
+		return nil, nil
 	}
+	// Порядок листьев в батче и в истории чата - по message_id
+	slices.Sort(ids)
 
-	payloads, _, err := db.GetMessagePayloads(ctx, ids)
+	alg, err := s.chatHashAlg(ctx, chatID)
 	if err != nil {
-		return fmt.Errorf("GetMessagePayloads failed: %w", err)
+		s.requeue(ctx, key, ids)
+		s.releaseLock(ctx, chatID)
+		return nil, err
 	}
+	return &pendingBatch{chatID: chatID, key: key, ids: ids, alg: alg}, nil
+}
 
-	// Prepare [][]byte for bridge
-	msgs := make([][]byte, len(payloads))
-	for i := range payloads {
-		msgs[i] = payloads[i]
+// Вызывается, когда batch заполнился.
+// 1. По ключу pending_batch`а берет последние BatchSize сообщений
+// 2. Читает их курсором и по одному отправляет payloads в c++ engine (merkle_builder),
+// payload_hash - в аккумулятор истории чата (MMR)
+// 3. Получает root батча
+// 4. Сохраняет root в БД и проставляет batch_id для сообщений
+// 5. И устанавливает latest root для чата
+func (s *MessageService) flushChat(ctx context.Context, chatID int64) error {
+	pb, err := s.takeBatch(ctx, chatID)
+	if err != nil || pb == nil {
+		return err
+	}
+	defer s.releaseLock(ctx, chatID)
+
+	return s.commitBatch(ctx, pb, s.streamRootFn(ctx, pb))
+}
+
+// streamRootFn - rootFn для commitBatch, которая строит дерево батча потоком.
+// Сообщения читаются курсором и сразу уходят в потоковый построитель engine,
+// поэтому payload батча целиком в памяти не держится. Дерево батча кешируется
+// для proof.
+func (s *MessageService) streamRootFn(ctx context.Context, pb *pendingBatch) func(tx *sql.Tx, history *verify.MMR) ([]byte, *cgobridge.Tree, error) {
+	return func(tx *sql.Tx, history *verify.MMR) ([]byte, *cgobridge.Tree, error) {
+		tree, err := s.streamBatchTreeTx(ctx, tx, pb.ids, pb.alg, history)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tree.Root(), tree, nil
 	}
+}
 
-	root, err := cgobridge.MerkleRoot(msgs)
+// preparedBatch - сообщения из начала pending_batch чата, прочитанные без блокировки
+// чата для общего вызова engine во flushChats
+type preparedBatch struct {
+	chatID   int64
+	ids      []int64 // по возрастанию message_id
+	alg      cgobridge.HashAlg
+	payloads [][]byte
+	hashes   [][]byte
+	size     int // байт payload
+}
+
+// prepareBatch читает до BatchSize сообщений из начала pending_batch чата, не снимая
+// их из очереди. Возвращает nil, если сообщений нет.
+func (s *MessageService) prepareBatch(ctx context.Context, chatID int64) (*preparedBatch, error) {
+	key := fmt.Sprintf("chat:%d:pending_batch", chatID)
+	vals, err := db.RedisClient.LRange(ctx, key, 0, int64(s.cfg.BatchSize)-1).Result()
 	if err != nil {
-		// TODO: process error
-		for _, id := range ids {
-			_ = db.RedisClient.LPush(ctx, key, id).Err()
+		return nil, fmt.Errorf("LRange error: %w", err)
+	}
+	if len(vals) == 0 {
+		return nil, nil
+	}
+	ids := make([]int64, len(vals))
+	for i, val := range vals {
+		fmt.Sscanf(val, "%d", &ids[i])
+	}
+	slices.Sort(ids)
+
+	alg, err := s.chatHashAlg(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	p := &preparedBatch{chatID: chatID, ids: ids, alg: alg}
+	err = db.StreamMessagePayloads(ctx, ids, func(_ int64, payload, hash []byte) error {
+		p.payloads = append(p.payloads, slices.Clone(payload))
+		p.hashes = append(p.hashes, slices.Clone(hash))
+		p.size += len(payload)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// flushChats - flush чатов, у которых истек BatchTimeout. Деревья батчей группы
+// строятся одним вызовом engine (cgobridge.MerkleTrees); payload группы в памяти
+// не больше FlushGroupBytes (плюс один батч). Затем каждый чат коммитится в своей
+// транзакции под своей блокировкой, как во flushChat.
+func (s *MessageService) flushChats(ctx context.Context, chatIDs []int64) {
+	var group []*preparedBatch
+	size := 0
+	for _, chatID := range chatIDs {
+		start := time.Now()
+		p, err := s.prepareBatch(ctx, chatID)
+		if err != nil {
+			observeFlush(chatID, start, err)
+			continue
+		}
+		if p == nil {
+			continue
+		}
+		group = append(group, p)
+		size += p.size
+		if size >= s.cfg.FlushGroupBytes {
+			s.commitPrepared(ctx, group)
+			group, size = nil, 0
+		}
+	}
+	if len(group) > 0 {
+		s.commitPrepared(ctx, group)
+	}
+}
+
+// commitPrepared строит деревья группы одним вызовом engine и коммитит батчи по одному.
+// Сообщения набора, для которого дерево не построено, остаются в pending_batch.
+func (s *MessageService) commitPrepared(ctx context.Context, group []*preparedBatch) {
+	sets := make([]cgobridge.RootSet, len(group))
+	for i, p := range group {
+		sets[i] = cgobridge.RootSet{Messages: p.payloads, Options: s.merkleOptions(p.alg)}
+	}
+	start := time.Now()
+	results := cgobridge.MerkleTrees(sets, s.cfg.MerkleWorkers)
+
+	for i, p := range group {
+		p.payloads = nil
+		err := results[i].Err
+		if err != nil {
+			err = fmt.Errorf("MerkleTrees failed: %w", err)
+		} else {
+			err = s.flushPrepared(ctx, p, results[i].Tree)
 		}
-		return fmt.Errorf("MerkleRoot failed: %w", err)
+		observeFlush(p.chatID, start, err)
+	}
+}
+
+// observeFlush пишет flush чата в метрики, ошибку - еще и в лог с chat ID:
+// сообщения остаются в pending_batch и уйдут со следующим flush
+func observeFlush(chatID int64, start time.Time, err error) {
+	metrics.ObserveBusiness("FlushChat", start, err)
+	if err != nil {
+		log.Printf("chat %d: flush: %v", chatID, err)
+	}
+}
+
+// flushPrepared снимает батч чата из pending_batch под блокировкой чата и коммитит его
+// с деревом, построенным по p. В транзакции сообщения перечитываются с блокировкой строк:
+// они должны быть на месте, без batch_id и с теми же payload_hash. Если очередь
+// изменилась после prepareBatch (чат успел flush`нуть flushChat), батч строится заново потоком.
+func (s *MessageService) flushPrepared(ctx context.Context, p *preparedBatch, tree *cgobridge.Tree) error {
+	pb, err := s.takeBatch(ctx, p.chatID)
+	if err != nil || pb == nil {
+		return err
 	}
+	defer s.releaseLock(ctx, p.chatID)
+
+	if !slices.Equal(pb.ids, p.ids) || pb.alg != p.alg {
+		return s.commitBatch(ctx, pb, s.streamRootFn(ctx, pb))
+	}
+	return s.commitBatch(ctx, pb, func(tx *sql.Tx, history *verify.MMR) ([]byte, *cgobridge.Tree, error) {
+		hashes, err := db.GetPendingHashesTx(ctx, tx, pb.ids)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, h := range hashes {
+			if !bytes.Equal(h, p.hashes[i]) {
+				return nil, nil, fmt.Errorf("message %d changed since batch root was computed", pb.ids[i])
+			}
+			history.Append(h)
+		}
+		return tree.Root(), tree, nil
+	})
+}
+
+// commitBatch сохраняет батч в одной транзакции: загружает аккумулятор истории чата,
+// получает root батча от rootFn (она же дописывает payload_hash в history),
+// вставляет merkle_batches со ссылкой на предыдущий батч чата, подписывает tree head,
+// сохраняет аккумулятор и проставляет batch_id сообщениям.
+// Если rootFn вернула дерево, оно сохраняется в batch_trees в той же транзакции и
+// после коммита кешируется в Redis для proof, затем root батча получает метку времени от Config.TSA и публикуется в Config.Anchors.
+// При ошибке сообщения возвращаются в pending_batch.
+func (s *MessageService) commitBatch(ctx context.Context, pb *pendingBatch, rootFn func(tx *sql.Tx, history *verify.MMR) ([]byte, *cgobridge.Tree, error)) error {
+	chatID, key, ids := pb.chatID, pb.key, pb.ids
 
 	tx, err := db.DB.BeginTx(ctx, nil)
 	if err != nil {
 		// push back to redis
-		for _, id := range ids {
-			_ = db.RedisClient.LPush(ctx, key, id).Err()
-		}
+		s.requeue(ctx, key, ids)
 		return fmt.Errorf("BeginTx failed: %w", err)
 	}
 
+	history, err := loadChatHistoryTx(ctx, tx, chatID, pb.alg)
+	if err != nil {
+		_ = tx.Rollback()
+		s.requeue(ctx, key, ids)
+		return err
+	}
+
+	root, tree, err := rootFn(tx, history)
+	if err != nil {
+		// TODO: process error
+		_ = tx.Rollback()
+		s.requeue(ctx, key, ids)
+		return err
+	}
+	chatSize := int64(history.Size)
+
+	prevHash, err := prevBatchHashTx(ctx, tx, chatID)
+	if err != nil {
+		_ = tx.Rollback()
+		s.requeue(ctx, key, ids)
+		return err
+	}
+
 	batch := &db.MerkleBatch{
 		ChatID:        chatID,
 		RootHash:      root,
 		FromMessageID: ids[0],
 		ToMessageID:   ids[len(ids)-1],
+		TreeVersion:   int(s.cfg.TreeVersion),
+		HashAlg:       int(pb.alg),
+		ChatSize:      &chatSize,
+		ChatRoot:      history.Root(),
+		PrevBatchHash: prevHash,
 	}
 	batchID, err := db.InsertMerkleBatchTx(ctx, tx, batch)
 	if err != nil {
 		_ = tx.Rollback()
-		for _, id := range ids {
-			_ = db.RedisClient.LPush(ctx, key, id).Err()
-		}
+		s.requeue(ctx, key, ids)
 		return fmt.Errorf("InsertMerkleBatchTx failed: %w", err)
 	}
+	batch.BatchID = batchID
 
-	if err := db.UpdateMessagesBatchIDTx(ctx, tx, ids, batchID); err != nil {
+	if err := s.signTreeHeadTx(ctx, tx, batch, history); err != nil {
+		_ = tx.Rollback()
+		s.requeue(ctx, key, ids)
+		return err
+	}
+
+	// первые попытки публикации и метки времени - сразу после коммита, эти строки - на случай сбоя или падения до них
+	if err := db.InsertPendingAnchorsTx(ctx, tx, batchID, s.anchorNames(), time.Now().Add(s.cfg.AnchorRetryInterval)); err != nil {
 		_ = tx.Rollback()
-		for _, id := range ids {
-			_ = db.RedisClient.LPush(ctx, key, id).Err()
+		s.requeue(ctx, key, ids)
+		return err
+	}
+	if s.cfg.TSA != nil {
+		if err := db.InsertPendingTimestampTx(ctx, tx, batchID, time.Now().Add(s.cfg.AnchorRetryInterval)); err != nil {
+			_ = tx.Rollback()
+			s.requeue(ctx, key, ids)
+			return err
 		}
+	}
+
+	if tree != nil {
+		if err := saveBatchTreeTx(ctx, tx, batchID, tree); err != nil {
+			_ = tx.Rollback()
+			s.requeue(ctx, key, ids)
+			return err
+		}
+	}
+
+	if err := saveChatHistoryTx(ctx, tx, chatID, history, batchID); err != nil {
+		_ = tx.Rollback()
+		s.requeue(ctx, key, ids)
+		return err
+	}
+
+	if err := db.UpdateMessagesBatchIDTx(ctx, tx, ids, batchID); err != nil {
+		_ = tx.Rollback()
+		s.requeue(ctx, key, ids)
 		return fmt.Errorf("UpdateMessagesBatchIDTx failed: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		for _, id := range ids {
-			_ = db.RedisClient.LPush(ctx, key, id).Err()
-		}
+		s.requeue(ctx, key, ids)
 		return fmt.Errorf("tx commit failed: %w", err)
 	}
 
-	if err := db.RedisClient.Set(ctx, fmt.Sprintf("chat:%d:latest_root", chatID), root, 0).Err(); err != nil {
-		// TODO: process error
+	if err := db.SetLatestBatch(ctx, newLatestRoot(batch)); err != nil {
+		// в кеше остался прошлый батч: без него GetLatestRoot возьмет root из MySQL
+		log.Printf("chat %d: cache latest batch %d: %v", chatID, batchID, err)
+		if err := db.DeleteLatestBatch(ctx, chatID); err != nil {
+			log.Printf("chat %d: drop stale latest batch: %v", chatID, err)
+		}
+	}
+	if tree != nil {
+		s.cacheTree(ctx, batchID, tree)
+	}
+	if s.cfg.TSA != nil {
+		if err := s.timestampBatch(ctx, batchID, pb.alg, root, 0); err != nil {
+			log.Printf("chat %d: %v", chatID, err)
+		}
+	}
+	s.anchorBatch(ctx, toVerifyBatch(batch))
+	if err := db.PublishBatchCommitted(ctx, chatID, batchID); err != nil {
+		// подписчики WatchBatches чата получат батч из MySQL при переподключении с after_batch_id
+		log.Printf("chat %d: publish batch %d: %v", chatID, batchID, err)
 	}
 
 	return nil
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"log"
+	"time"
+
+	"veriChat/go/internal/db"
+	"veriChat/go/pkg/verify"
+)
+
+// timestampRetryBatch сколько ожидающих меток времени берется за проход
+const timestampRetryBatch = 100
+
+// BatchTimestamp - проверенная метка времени root батча
+type BatchTimestamp struct {
+	BatchID   int64
+	Root      []byte
+	HashAlg   verify.HashAlg
+	Authority string
+	GenTime   time.Time // время из токена: root существовал не позже
+	Token     []byte    // DER токена
+}
+
+// timestampBatch запрашивает у Config.TSA метку времени root батча и сохраняет токен.
+// Вызывается после коммита: при ошибке ожидающая метка из batch_timestamp_jobs
+// откладывается и подхватывается timestampRetrier. attempts - сколько попыток уже было.
+func (s *MessageService) timestampBatch(ctx context.Context, batchID int64, alg verify.HashAlg, root []byte, attempts int) error {
+	ts, err := s.requestTimestamp(ctx, alg, root)
+	if err == nil {
+		ts.BatchID = batchID
+		err = db.InsertBatchTimestamp(ctx, ts)
+	}
+	if err != nil {
+		next := time.Now().Add(s.retryBackoff(attempts + 1))
+		if dbErr := db.MarkTimestampFailed(ctx, batchID, lastError(err), next); dbErr != nil {
+			return dbErr
+		}
+		return fmt.Errorf("batch %d timestamp: %w", batchID, err)
+	}
+	return nil
+}
+
+// requestTimestamp получает у Config.TSA токен на root и проверяет его
+func (s *MessageService) requestTimestamp(ctx context.Context, alg verify.HashAlg, root []byte) (*db.BatchTimestamp, error) {
+	token, err := s.cfg.TSA.Timestamp(ctx, alg, root)
+	if err != nil {
+		return nil, err
+	}
+	genTime, err := s.cfg.TSA.Verify(token, alg, root)
+	if err != nil {
+		return nil, err
+	}
+	return &db.BatchTimestamp{Authority: s.cfg.TSA.Name(), Token: token, GenTime: genTime}, nil
+}
+
+// timestampRetrier раз в AnchorRetryInterval повторяет метки времени, которые не удалось
+// получить сразу после коммита (или не были запрошены из-за падения процесса)
+func (s *MessageService) timestampRetrier() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.AnchorRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.retryTimestamps(context.Background())
+		}
+	}
+}
+
+// retryTimestamps один проход повторов меток времени
+func (s *MessageService) retryTimestamps(ctx context.Context) {
+	due, err := db.GetDueTimestamps(ctx, time.Now(), timestampRetryBatch)
+	if err != nil {
+		log.Printf("timestamp retry: %v", err)
+		return
+	}
+	for _, p := range due {
+		batch, err := db.GetMerkleBatch(ctx, p.BatchID)
+		if err != nil {
+			log.Printf("timestamp retry: batch %d: %v", p.BatchID, err)
+			continue
+		}
+		if batch == nil {
+			log.Printf("timestamp retry: batch %d not found, dropping pending timestamp", p.BatchID)
+			if err := db.DeletePendingTimestamp(ctx, p.BatchID); err != nil {
+				log.Printf("timestamp retry: batch %d: %v", p.BatchID, err)
+			}
+			continue
+		}
+		alg := verify.HashAlg(batch.HashAlg)
+		if alg == 0 {
+			alg = verify.HashSHA256
+		}
+		if err := s.timestampBatch(ctx, p.BatchID, alg, batch.RootHash, p.Attempts); err != nil {
+			log.Printf("timestamp retry: %v (attempt %d)", err, p.Attempts+1)
+		}
+	}
+}
+
+// VerifyBatchTimestamp проверяет сохраненный токен батча через Config.TSA
+// против root_hash из merkle_batches
+func (s *MessageService) VerifyBatchTimestamp(ctx context.Context, batchID int64) (*BatchTimestamp, error) {
+	batch, err := db.GetMerkleBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, fmt.Errorf("batch %d: %w", batchID, ErrNotFound)
+	}
+	ts, err := db.GetBatchTimestamp(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if ts == nil {
+		return nil, fmt.Errorf("batch %d timestamp: %w", batchID, ErrNotFound)
+	}
+	if s.cfg.TSA == nil {
+		return nil, fmt.Errorf("batch %d timestamp: no timestamp authority configured", batchID)
+	}
+
+	alg := verify.HashAlg(batch.HashAlg)
+	if alg == 0 {
+		alg = verify.HashSHA256
+	}
+	genTime, err := s.cfg.TSA.Verify(ts.Token, alg, batch.RootHash)
+	if err != nil {
+		return nil, fmt.Errorf("batch %d timestamp from %s: %w", batchID, ts.Authority, err)
+	}
+	return &BatchTimestamp{
+		BatchID:   batchID,
+		Root:      batch.RootHash,
+		HashAlg:   alg,
+		Authority: ts.Authority,
+		GenTime:   genTime,
+		Token:     ts.Token,
+	}, nil
+}
+
+// RegisterTSAKey заносит ключ локальной TSA (Config.TSA с методом PublicKey) в tsa_keys
+// и передает TSA все ключи оттуда, чтобы токены, выданные до ротации, продолжали
+// проверяться. Ключи подписи tree head TSA не доверяет. Вызывается при старте сервиса.
+func (s *MessageService) RegisterTSAKey(ctx context.Context) error {
+	if local, ok := s.cfg.TSA.(interface{ PublicKey() ed25519.PublicKey }); ok {
+		pub := local.PublicKey()
+		if s.cfg.SigningKey != nil && pub.Equal(s.cfg.SigningKey.Public()) {
+			return fmt.Errorf("tsa key %s: must differ from the tree head signing key", verify.KeyID(pub))
+		}
+		if err := db.ActivateTSAKey(ctx, verify.KeyID(pub), pub); err != nil {
+			return err
+		}
+	}
+	trusting, ok := s.cfg.TSA.(interface{ Trust(ed25519.PublicKey) })
+	if !ok {
+		return nil
+	}
+	keys, err := db.ListTSAKeys(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		trusting.Trust(ed25519.PublicKey(k.PublicKey))
+	}
+	return nil
+}
+
+// ListTSAKeys возвращает текущий и выведенные из ротации ключи локальной TSA
+func (s *MessageService) ListTSAKeys(ctx context.Context) ([]*db.SigningKey, error) {
+	return db.ListTSAKeys(ctx)
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"veriChat/go/internal/tsa"
+)
+
+// TestRegisterTSAKeyRejectsSigningKey - метка времени подписанная ключом tree head ничего не доказывает
+func TestRegisterTSAKeyRejectsSigningKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	s := &MessageService{cfg: Config{SigningKey: key, TSA: tsa.NewLocal(key)}}
+	assert.ErrorContains(t, s.RegisterTSAKey(context.Background()), "must differ")
+}
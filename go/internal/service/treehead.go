@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"veriChat/go/internal/db"
+	"veriChat/go/pkg/verify"
+)
+
+// RegisterSigningKey заносит ключ из Config.SigningKey в signing_keys и выводит
+// из ротации предыдущий. Вызывается при старте сервиса.
+func (s *MessageService) RegisterSigningKey(ctx context.Context) error {
+	if s.cfg.SigningKey == nil {
+		return nil
+	}
+	pub := s.cfg.SigningKey.Public().(ed25519.PublicKey)
+	return db.ActivateSigningKey(ctx, verify.KeyID(pub), pub)
+}
+
+// signTreeHeadTx подписывает состояние чата после батча и сохраняет его в рамках tx.
+// Без ключа подписи ничего не делает.
+func (s *MessageService) signTreeHeadTx(ctx context.Context, tx *sql.Tx, batch *db.MerkleBatch, history *verify.MMR) error {
+	if s.cfg.SigningKey == nil {
+		return nil
+	}
+	sth := verify.SignTreeHead(s.cfg.SigningKey, verify.TreeHead{
+		ChatID:    batch.ChatID,
+		BatchID:   batch.BatchID,
+		Size:      history.Size,
+		Root:      history.Root(),
+		BatchRoot: batch.RootHash,
+		Timestamp: time.Now(),
+	})
+	return db.InsertSignedTreeHeadTx(ctx, tx, &db.SignedTreeHead{
+		BatchID:     sth.BatchID,
+		ChatID:      sth.ChatID,
+		TreeSize:    int64(sth.Size),
+		Root:        sth.Root,
+		BatchRoot:   sth.BatchRoot,
+		TimestampMs: sth.Timestamp.UnixMilli(),
+		KeyID:       sth.KeyID,
+		Signature:   sth.Signature,
+	})
+}
+
+func toVerifyTreeHead(h *db.SignedTreeHead) *verify.SignedTreeHead {
+	return &verify.SignedTreeHead{
+		TreeHead: verify.TreeHead{
+			ChatID:    h.ChatID,
+			BatchID:   h.BatchID,
+			Size:      uint64(h.TreeSize),
+			Root:      h.Root,
+			BatchRoot: h.BatchRoot,
+			Timestamp: time.UnixMilli(h.TimestampMs).UTC(),
+		},
+		KeyID:     h.KeyID,
+		Signature: h.Signature,
+	}
+}
+
+// GetSignedTreeHead возвращает подписанный head последнего батча чата
+func (s *MessageService) GetSignedTreeHead(ctx context.Context, chatID int64) (*verify.SignedTreeHead, error) {
+	h, err := db.GetLatestSignedTreeHead(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return nil, fmt.Errorf("chat %d signed tree head: %w", chatID, ErrNotFound)
+	}
+	return toVerifyTreeHead(h), nil
+}
+
+// GetBatchTreeHead возвращает подписанный head батча
+func (s *MessageService) GetBatchTreeHead(ctx context.Context, batchID int64) (*verify.SignedTreeHead, error) {
+	h, err := db.GetSignedTreeHead(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return nil, fmt.Errorf("batch %d signed tree head: %w", batchID, ErrNotFound)
+	}
+	return toVerifyTreeHead(h), nil
+}
+
+// ListSigningKeys возвращает текущий и выведенные из ротации ключи подписи
+func (s *MessageService) ListSigningKeys(ctx context.Context) ([]*db.SigningKey, error) {
+	return db.ListSigningKeys(ctx)
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"veriChat/go/internal/db"
+	"veriChat/go/pkg/verify"
+)
+
+// batchStore - чтение батчей для VerifyMessageProof, в сервисе - функции db (dbBatches)
+type batchStore interface {
+	GetMerkleBatch(ctx context.Context, batchID int64) (*db.MerkleBatch, error)
+	GetMerkleBatchByRoot(ctx context.Context, chatID int64, root []byte) (*db.MerkleBatch, error)
+}
+
+type dbBatches struct{}
+
+func (dbBatches) GetMerkleBatch(ctx context.Context, batchID int64) (*db.MerkleBatch, error) {
+	return db.GetMerkleBatch(ctx, batchID)
+}
+
+func (dbBatches) GetMerkleBatchByRoot(ctx context.Context, chatID int64, root []byte) (*db.MerkleBatch, error) {
+	return db.GetMerkleBatchByRoot(ctx, chatID, root)
+}
+
+// ProofCheck - inclusion proof, присланный клиентом на проверку
+type ProofCheck struct {
+	Payload []byte
+	Proof   *verify.Proof
+	Root    []byte // заявленный root; nil - root батча BatchID
+	BatchID int64  // 0 - батч ищется по Root среди батчей ChatID
+	ChatID  int64  // 0 - любой чат, если задан BatchID
+}
+
+// ProofCheckResult - итог проверки. Batch - сохраненный батч, с root которого
+// сравнивался proof (nil, если заявленный root не root ни одного батча).
+type ProofCheckResult struct {
+	Valid        bool
+	Reason       string // почему proof не сошелся
+	ComputedRoot []byte
+	Batch        *db.MerkleBatch
+}
+
+// VerifyMessageProof проверяет, что payload входит в сохраненный батч: proof
+// поднимается до root по правилам батча, и этот root совпадает с заявленным.
+func (s *MessageService) VerifyMessageProof(ctx context.Context, c ProofCheck) (*ProofCheckResult, error) {
+	if c.Proof == nil {
+		return nil, fmt.Errorf("%w: proof is required", ErrInvalidArgument)
+	}
+	if c.BatchID == 0 && (c.Root == nil || c.ChatID == 0) {
+		// один root не определяет батч: одинаковый root может быть у батчей разных чатов
+		return nil, fmt.Errorf("%w: batch_id or chat_id with root is required", ErrInvalidArgument)
+	}
+	if c.Root != nil && len(c.Root) != verify.HashSize {
+		return nil, fmt.Errorf("%w: root has %d bytes, want %d", ErrInvalidArgument, len(c.Root), verify.HashSize)
+	}
+
+	var batch *db.MerkleBatch
+	var err error
+	if c.BatchID != 0 {
+		if batch, err = s.batches.GetMerkleBatch(ctx, c.BatchID); err != nil {
+			return nil, err
+		}
+		if batch == nil || (c.ChatID != 0 && batch.ChatID != c.ChatID) {
+			return nil, fmt.Errorf("batch %d: %w", c.BatchID, ErrNotFound)
+		}
+	} else if batch, err = s.batches.GetMerkleBatchByRoot(ctx, c.ChatID, c.Root); err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return &ProofCheckResult{Reason: fmt.Sprintf("root is not a root of any batch of chat %d", c.ChatID)}, nil
+	}
+
+	res := &ProofCheckResult{Batch: batch}
+	res.ComputedRoot, err = toVerifyBatch(batch).Params().RootFromProof(c.Payload, c.Proof)
+	switch {
+	case err != nil:
+		res.Reason = err.Error()
+	case c.Root != nil && !bytes.Equal(c.Root, batch.RootHash):
+		res.Reason = fmt.Sprintf("claimed root differs from root of batch %d", batch.BatchID)
+	case !bytes.Equal(res.ComputedRoot, batch.RootHash):
+		res.Reason = verify.ErrRootMismatch.Error()
+	default:
+		res.Valid = true
+	}
+	return res, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"veriChat/go/internal/db"
+	"veriChat/go/pkg/verify"
+)
+
+// fakeBatches - batchStore над батчами в памяти
+type fakeBatches []*db.MerkleBatch
+
+func (f fakeBatches) GetMerkleBatch(ctx context.Context, batchID int64) (*db.MerkleBatch, error) {
+	for _, b := range f {
+		if b.BatchID == batchID {
+			return b, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f fakeBatches) GetMerkleBatchByRoot(ctx context.Context, chatID int64, root []byte) (*db.MerkleBatch, error) {
+	for _, b := range f {
+		if b.ChatID == chatID && bytes.Equal(b.RootHash, root) {
+			return b, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestVerifyMessageProof(t *testing.T) {
+	params := verify.Params{Version: verify.TreeRFC6962, Hash: verify.HashSHA256}
+	messages := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	root, err := params.Root(messages)
+	require.NoError(t, err)
+	proof, err := params.BuildProof(messages, 1)
+	require.NoError(t, err)
+	otherRoot, err := params.Root([][]byte{[]byte("x")})
+	require.NoError(t, err)
+
+	batch := func(batchID, chatID int64, root []byte) *db.MerkleBatch {
+		return &db.MerkleBatch{BatchID: batchID, ChatID: chatID, RootHash: root,
+			TreeVersion: int(params.Version), HashAlg: int(params.Hash)}
+	}
+	// тот же root у батча другого чата: по root без chat_id батч не определить
+	s := &MessageService{batches: fakeBatches{batch(1, 7, otherRoot), batch(2, 9, root), batch(3, 7, root)}}
+	ctx := context.Background()
+
+	cases := []struct {
+		name    string
+		check   ProofCheck
+		valid   bool
+		reason  string
+		batchID int64 // 0 - батч не найден
+	}{
+		{"valid by batch", ProofCheck{Payload: []byte("b"), Proof: proof, BatchID: 3}, true, "", 3},
+		{"valid by chat and root", ProofCheck{Payload: []byte("b"), Proof: proof, Root: root, ChatID: 7}, true, "", 3},
+		{"valid in other chat", ProofCheck{Payload: []byte("b"), Proof: proof, Root: root, ChatID: 9}, true, "", 2},
+		{"claimed root differs from batch", ProofCheck{Payload: []byte("b"), Proof: proof, Root: otherRoot, BatchID: 3}, false, "claimed root differs", 3},
+		{"computed root differs", ProofCheck{Payload: []byte("x"), Proof: proof, BatchID: 3}, false, verify.ErrRootMismatch.Error(), 3},
+		{"proof for other batch", ProofCheck{Payload: []byte("b"), Proof: proof, Root: otherRoot, ChatID: 7}, false, verify.ErrRootMismatch.Error(), 1},
+		{"unknown root", ProofCheck{Payload: []byte("b"), Proof: proof, Root: root, ChatID: 8}, false, "not a root of any batch of chat 8", 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := s.VerifyMessageProof(ctx, tc.check)
+			require.NoError(t, err)
+			assert.Equal(t, tc.valid, res.Valid)
+			if tc.reason == "" {
+				assert.Empty(t, res.Reason)
+			} else {
+				assert.Contains(t, res.Reason, tc.reason)
+			}
+			if tc.batchID == 0 {
+				assert.Nil(t, res.Batch)
+				assert.Nil(t, res.ComputedRoot)
+				return
+			}
+			require.NotNil(t, res.Batch)
+			assert.Equal(t, tc.batchID, res.Batch.BatchID)
+			if tc.valid {
+				assert.Equal(t, root, res.ComputedRoot)
+			}
+		})
+	}
+}
+
+func TestVerifyMessageProofInvalid(t *testing.T) {
+	root := bytes.Repeat([]byte{1}, verify.HashSize)
+	s := &MessageService{batches: fakeBatches{{BatchID: 3, ChatID: 7, RootHash: root}}}
+	proof := &verify.Proof{}
+
+	cases := []struct {
+		name  string
+		check ProofCheck
+		err   error
+	}{
+		{"no proof", ProofCheck{BatchID: 3}, ErrInvalidArgument},
+		{"root without chat", ProofCheck{Proof: proof, Root: root}, ErrInvalidArgument},
+		{"chat without root", ProofCheck{Proof: proof, ChatID: 7}, ErrInvalidArgument},
+		{"short root", ProofCheck{Proof: proof, Root: root[:4], ChatID: 7}, ErrInvalidArgument},
+		{"unknown batch", ProofCheck{Proof: proof, BatchID: 4}, ErrNotFound},
+		{"batch of other chat", ProofCheck{Proof: proof, BatchID: 3, ChatID: 8}, ErrNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := s.VerifyMessageProof(context.Background(), tc.check)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
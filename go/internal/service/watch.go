@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"veriChat/go/internal/db"
+)
+
+// WatchBatches вызывает fn для каждого закоммиченного батча чата chatID (0 - всех чатов),
+// пока не отменен ctx, не остановлен сервис или fn не вернула ошибку. Уведомления идут
+// через Redis pub/sub (db.BatchesChannel), поэтому видны батчи всех экземпляров сервиса.
+//
+// afterBatchID > 0 (только вместе с chatID) - сначала из MySQL отдаются батчи чата после
+// него: клиент, переподключившись с последним полученным batch_id, ничего не пропустит.
+// Поток всех чатов так не возобновить: батчи разных чатов коммитятся параллельно,
+// и батч с меньшим batch_id может закоммититься позже уже отданного.
+func (s *MessageService) WatchBatches(ctx context.Context, chatID, afterBatchID int64, fn func(*db.MerkleBatch) error) error {
+	if afterBatchID < 0 || (afterBatchID > 0 && chatID == 0) {
+		return fmt.Errorf("%w: after batch %d needs a chat id", ErrInvalidArgument, afterBatchID)
+	}
+
+	// подписка до чтения MySQL, чтобы батч между ними не потерялся
+	sub := db.SubscribeBatches(ctx)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribe batches: %w", err)
+	}
+
+	last := afterBatchID
+	for last > 0 {
+		batches, err := db.ListChatBatches(ctx, chatID, last, false, MaxPageSize)
+		if err != nil {
+			return err
+		}
+		for _, b := range batches {
+			if err := fn(b); err != nil {
+				return err
+			}
+			last = b.BatchID
+		}
+		if len(batches) < MaxPageSize {
+			break
+		}
+	}
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.stopCh:
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return errors.New("batch subscription closed")
+			}
+			msgChatID, batchID, err := db.ParseBatchCommitted(msg.Payload)
+			if err != nil {
+				log.Printf("watch batches: %v", err)
+				continue
+			}
+			// batch_id растет в пределах чата: уже отданные из MySQL пропускаются
+			if chatID != 0 && (msgChatID != chatID || batchID <= last) {
+				continue
+			}
+			batch, err := db.GetMerkleBatch(ctx, batchID)
+			if err != nil {
+				return err
+			}
+			if batch == nil {
+				continue
+			}
+			if err := fn(batch); err != nil {
+				return err
+			}
+			last = batchID
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"time"
+
+	"veriChat/go/internal/db"
+	"veriChat/go/pkg/verify"
+)
+
+// ErrUnknownWitness - подпись от свидетеля, которого нет в Config.Witnesses
+var ErrUnknownWitness = errors.New("unknown witness")
+
+// WitnessHead - последний подписанный head чата и consistency proof от размера,
+// который свидетель видел раньше
+type WitnessHead struct {
+	Head     *verify.SignedTreeHead
+	HashAlg  verify.HashAlg
+	FromSize int64    // 0 - proof не запрашивался
+	Proof    [][]byte // verify.ConsistencyProof от FromSize до Head.Size
+}
+
+// GetWitnessHead возвращает head для свидетеля. Если from > 0, к нему прикладывается
+// consistency proof от истории из from сообщений до истории head.
+func (s *MessageService) GetWitnessHead(ctx context.Context, chatID, from int64) (*WitnessHead, error) {
+	head, err := s.GetSignedTreeHead(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	chat, err := s.GetChatHead(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	wh := &WitnessHead{Head: head, HashAlg: chat.HashAlg}
+	if from == 0 {
+		return wh, nil
+	}
+
+	proof, err := s.GetConsistencyProof(ctx, chatID, from, int64(head.Size))
+	if err != nil {
+		return nil, err
+	}
+	wh.FromSize = from
+	wh.Proof = proof.Proof
+	return wh, nil
+}
+
+// witnessKey ключ свидетеля из Config.Witnesses по key_id
+func (s *MessageService) witnessKey(keyID string) (ed25519.PublicKey, bool) {
+	for _, pub := range s.cfg.Witnesses {
+		if verify.KeyID(pub) == keyID {
+			return pub, true
+		}
+	}
+	return nil, false
+}
+
+// AddCosignature проверяет подпись свидетеля под head батча и сохраняет ее
+func (s *MessageService) AddCosignature(ctx context.Context, batchID int64, cos *verify.Cosignature) error {
+	pub, ok := s.witnessKey(cos.KeyID)
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownWitness, cos.KeyID)
+	}
+	head, err := s.GetBatchTreeHead(ctx, batchID)
+	if err != nil {
+		return err
+	}
+	if err := cos.Verify(pub, head.TreeHead); err != nil {
+		return fmt.Errorf("%w: batch %d cosignature: %v", ErrInvalidArgument, batchID, err)
+	}
+	return db.UpsertCosignature(ctx, &db.Cosignature{
+		BatchID:      batchID,
+		WitnessKeyID: cos.KeyID,
+		TimestampMs:  cos.Timestamp.UnixMilli(),
+		Signature:    cos.Signature,
+	})
+}
+
+// GetCosignatures возвращает head батча и собранные под ним подписи свидетелей
+func (s *MessageService) GetCosignatures(ctx context.Context, batchID int64) (*verify.SignedTreeHead, []*verify.Cosignature, error) {
+	head, err := s.GetBatchTreeHead(ctx, batchID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := db.GetBatchCosignatures(ctx, batchID)
+	if err != nil {
+		return nil, nil, err
+	}
+	cosigs := make([]*verify.Cosignature, len(rows))
+	for i, c := range rows {
+		cosigs[i] = &verify.Cosignature{
+			KeyID:     c.WitnessKeyID,
+			Timestamp: time.UnixMilli(c.TimestampMs).UTC(),
+			Signature: c.Signature,
+		}
+	}
+	return head, cosigs, nil
+}
@@ -0,0 +1,93 @@
+// Package tsa - метки времени для root батчей по образцу RFC 3161.
+// Authority выдает и проверяет токены, Local - встроенная authority на Ed25519 ключе.
+package tsa
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"veriChat/go/pkg/verify"
+)
+
+// Authority выдает подписанные метки времени и проверяет свои же токены
+type Authority interface {
+	// Name имя authority, сохраняется рядом с токеном
+	Name() string
+	// Timestamp выдает токен над digest, посчитанным алгоритмом alg
+	Timestamp(ctx context.Context, alg verify.HashAlg, digest []byte) ([]byte, error)
+	// Verify проверяет токен для digest и возвращает время из него
+	Verify(token []byte, alg verify.HashAlg, digest []byte) (time.Time, error)
+}
+
+// LocalPolicy OID политики Local (дуга 32473 - PEN для примеров, RFC 5612)
+var LocalPolicy = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 32473, 1}
+
+// Local - authority внутри сервиса. Подписывает токены своим ключом и проверяет
+// токены своего и доверенных (выведенных из ротации) ключей.
+type Local struct {
+	key ed25519.PrivateKey
+	now func() time.Time
+
+	mu   sync.RWMutex
+	keys map[string]ed25519.PublicKey
+}
+
+// NewLocal создает authority с ключом key
+func NewLocal(key ed25519.PrivateKey) *Local {
+	pub := key.Public().(ed25519.PublicKey)
+	return &Local{
+		key:  key,
+		now:  time.Now,
+		keys: map[string]ed25519.PublicKey{verify.KeyID(pub): pub},
+	}
+}
+
+// PublicKey ключ, которым Local подписывает новые токены
+func (l *Local) PublicKey() ed25519.PublicKey {
+	return l.key.Public().(ed25519.PublicKey)
+}
+
+// Name возвращает "local/<key_id>"
+func (l *Local) Name() string {
+	return "local/" + verify.KeyID(l.PublicKey())
+}
+
+// Trust добавляет ключ, токены которого принимает Verify
+func (l *Local) Trust(pub ed25519.PublicKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keys[verify.KeyID(pub)] = pub
+}
+
+// Timestamp выдает токен со случайным 128-битным серийным номером
+func (l *Local) Timestamp(_ context.Context, alg verify.HashAlg, digest []byte) ([]byte, error) {
+	imprint, err := verify.NewMessageImprint(alg, digest)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("tsa serial: %w", err)
+	}
+	return verify.SignTimestamp(l.key, verify.TSTInfo{
+		Version:        1,
+		Policy:         LocalPolicy,
+		MessageImprint: imprint,
+		SerialNumber:   serial,
+		GenTime:        l.now(),
+		TSA:            l.Name(),
+	})
+}
+
+// Verify проверяет подпись одним из известных ключей и imprint
+func (l *Local) Verify(token []byte, alg verify.HashAlg, digest []byte) (time.Time, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return verify.VerifyTimestamp(token, alg, digest, l.keys)
+}
@@ -0,0 +1,85 @@
+package tsa
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLocal(t *testing.T, at time.Time) *Local {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	l := NewLocal(key)
+	l.now = func() time.Time { return at }
+	return l
+}
+
+func TestLocalTimestamp(t *testing.T) {
+	at := time.Date(2026, 5, 4, 10, 30, 15, 900e6, time.UTC)
+	l := newTestLocal(t, at)
+	root := verify.HashSHA3_256.Sum([]byte("batch root"))
+
+	token, err := l.Timestamp(context.Background(), verify.HashSHA3_256, root)
+	require.NoError(t, err)
+
+	got, err := l.Verify(token, verify.HashSHA3_256, root)
+	require.NoError(t, err)
+	assert.True(t, got.Equal(at.Truncate(time.Second)), "got %v", got)
+
+	tok, err := verify.ParseTimestampToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tok.Info.Version)
+	assert.True(t, tok.Info.Policy.Equal(LocalPolicy))
+	assert.Equal(t, l.Name(), tok.Info.TSA)
+	assert.Equal(t, "local/"+tok.KeyID, l.Name())
+
+	// другой root, другой алгоритм, испорченный токен
+	_, err = l.Verify(token, verify.HashSHA3_256, verify.HashSHA3_256.Sum([]byte("other")))
+	assert.ErrorIs(t, err, verify.ErrTimestampMismatch)
+	_, err = l.Verify(token, verify.HashSHA256, root)
+	assert.ErrorIs(t, err, verify.ErrTimestampMismatch)
+	forged := append([]byte(nil), token...)
+	forged[len(forged)-1] ^= 1
+	_, err = l.Verify(forged, verify.HashSHA3_256, root)
+	assert.ErrorIs(t, err, verify.ErrBadSignature)
+	_, err = l.Verify(token[:len(token)-3], verify.HashSHA3_256, root)
+	assert.Error(t, err)
+}
+
+func TestLocalSerialsAreUnique(t *testing.T) {
+	l := newTestLocal(t, time.Now())
+	root := verify.HashSHA256.Sum([]byte("root"))
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		token, err := l.Timestamp(context.Background(), verify.HashSHA256, root)
+		require.NoError(t, err)
+		tok, err := verify.ParseTimestampToken(token)
+		require.NoError(t, err)
+		serial := tok.Info.SerialNumber.String()
+		assert.False(t, seen[serial], "serial %s repeated", serial)
+		seen[serial] = true
+	}
+}
+
+func TestLocalTrustRotatedKey(t *testing.T) {
+	at := time.Now()
+	old := newTestLocal(t, at)
+	root := verify.HashSHA256.Sum([]byte("root"))
+	token, err := old.Timestamp(context.Background(), verify.HashSHA256, root)
+	require.NoError(t, err)
+
+	cur := newTestLocal(t, at)
+	_, err = cur.Verify(token, verify.HashSHA256, root)
+	assert.ErrorIs(t, err, verify.ErrBadSignature)
+
+	cur.Trust(old.key.Public().(ed25519.PublicKey))
+	_, err = cur.Verify(token, verify.HashSHA256, root)
+	assert.NoError(t, err)
+}
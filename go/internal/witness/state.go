@@ -0,0 +1,138 @@
+package witness
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"veriChat/go/pkg/verify"
+)
+
+// State - что свидетель уже видел и подписал. Хранится между запусками:
+// без него свидетель не заметит переписанную историю.
+type State struct {
+	ServerKeys map[string]string    `json:"server_keys"` // key_id -> публичный ключ сервиса в hex
+	Chats      map[int64]*ChatState `json:"chats"`
+}
+
+// ChatState - последний подписанный head чата
+type ChatState struct {
+	BatchID int64  `json:"batch_id"`
+	Size    uint64 `json:"size"`
+	Root    string `json:"root"`
+	HashAlg string `json:"hash_alg"`
+}
+
+// NewState пустое состояние
+func NewState() *State {
+	return &State{ServerKeys: map[string]string{}, Chats: map[int64]*ChatState{}}
+}
+
+func (s *State) serverKeys() (map[string]ed25519.PublicKey, error) {
+	keys := make(map[string]ed25519.PublicKey, len(s.ServerKeys))
+	for id, h := range s.ServerKeys {
+		pub, err := hex.DecodeString(h)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("state: server key %s is malformed", id)
+		}
+		keys[id] = pub
+	}
+	return keys, nil
+}
+
+// checkNext проверяет, что head продолжает историю, подписанную раньше
+func (c *ChatState) checkNext(alg verify.HashAlg, sth *verify.SignedTreeHead, fromSize uint64, proof []string) error {
+	if alg.String() != c.HashAlg {
+		return fmt.Errorf("hash algorithm %s, saw %s", alg, c.HashAlg)
+	}
+	if sth.BatchID < c.BatchID || sth.Size < c.Size {
+		return fmt.Errorf("head batch %d size %d is behind batch %d size %d", sth.BatchID, sth.Size, c.BatchID, c.Size)
+	}
+	oldRoot, err := hex.DecodeString(c.Root)
+	if err != nil {
+		return fmt.Errorf("state root: %w", err)
+	}
+	if sth.BatchID == c.BatchID && (sth.Size != c.Size || !bytes.Equal(sth.Root, oldRoot)) {
+		return fmt.Errorf("batch %d has two different heads", sth.BatchID)
+	}
+	if fromSize != c.Size {
+		return fmt.Errorf("consistency proof from size %d, want %d", fromSize, c.Size)
+	}
+	hashes := make([][]byte, len(proof))
+	for i, h := range proof {
+		if hashes[i], err = hex.DecodeString(h); err != nil {
+			return fmt.Errorf("consistency proof: %w", err)
+		}
+	}
+	return verify.VerifyConsistency(alg, c.Size, sth.Size, oldRoot, sth.Root, hashes)
+}
+
+// LoadState читает состояние из path. Нет файла - пустое состояние.
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewState(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("witness state: %w", err)
+	}
+	s := NewState()
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("witness state %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save записывает состояние атомарно (временный файл и rename)
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("witness state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("witness state: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("witness state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("witness state: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// GenerateKey создает новый ключ свидетеля и записывает его в path (PEM PKCS#8)
+func GenerateKey(path string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("witness key: %w", err)
+	}
+	defer f.Close()
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		return nil, fmt.Errorf("witness key: %w", err)
+	}
+	return key, f.Close()
+}
@@ -0,0 +1,240 @@
+// Package witness - свидетель, который подписывает tree head сервиса только после
+// проверки, что новый head согласован со всем, что он видел раньше (защита от split view).
+package witness
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"veriChat/go/pkg/verify"
+)
+
+// ErrInconsistent - сервис показал историю, несовместимую с ранее виденной
+var ErrInconsistent = errors.New("inconsistent tree head")
+
+// Witness проверяет и подписывает head чатов сервиса Server
+type Witness struct {
+	Server string // адрес API, например http://localhost:8080
+	Client *http.Client
+	Key    ed25519.PrivateKey
+	State  *State
+	// TrustNewKeys - при подписи head неизвестным ключом перечитать GET /keys
+	// (ротация ключа сервиса). По умолчанию принимаются только ключи из State:
+	// их список берется из GET /keys один раз, пока State.ServerKeys пуст.
+	TrustNewKeys bool
+
+	now func() time.Time
+}
+
+// New создает свидетеля с пустым состоянием
+func New(server string, key ed25519.PrivateKey) *Witness {
+	return &Witness{
+		Server: server,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		Key:    key,
+		State:  NewState(),
+		now:    time.Now,
+	}
+}
+
+// PublicKey ключ свидетеля, который сервис должен знать (Config.Witnesses)
+func (w *Witness) PublicKey() ed25519.PublicKey {
+	return w.Key.Public().(ed25519.PublicKey)
+}
+
+type headResponse struct {
+	ChatID      int64    `json:"chat_id"`
+	BatchID     int64    `json:"batch_id"`
+	TreeSize    uint64   `json:"tree_size"`
+	Root        string   `json:"root"`
+	BatchRoot   string   `json:"batch_root"`
+	TimestampMs int64    `json:"timestamp_ms"`
+	KeyID       string   `json:"key_id"`
+	Signature   string   `json:"signature"`
+	HashAlg     string   `json:"hash_alg"`
+	FromSize    uint64   `json:"from_size"`
+	Consistency []string `json:"consistency"`
+}
+
+func (r *headResponse) signedTreeHead() (*verify.SignedTreeHead, error) {
+	root, err := hex.DecodeString(r.Root)
+	if err != nil {
+		return nil, fmt.Errorf("head root: %w", err)
+	}
+	batchRoot, err := hex.DecodeString(r.BatchRoot)
+	if err != nil {
+		return nil, fmt.Errorf("head batch root: %w", err)
+	}
+	sig, err := hex.DecodeString(r.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("head signature: %w", err)
+	}
+	return &verify.SignedTreeHead{
+		TreeHead: verify.TreeHead{
+			ChatID:    r.ChatID,
+			BatchID:   r.BatchID,
+			Size:      r.TreeSize,
+			Root:      root,
+			BatchRoot: batchRoot,
+			Timestamp: time.UnixMilli(r.TimestampMs).UTC(),
+		},
+		KeyID:     r.KeyID,
+		Signature: sig,
+	}, nil
+}
+
+// Check забирает последний head чата, проверяет подпись сервиса и согласованность с
+// ранее виденным head, подписывает его и отправляет подпись сервису.
+// Возвращает подписанный head или nil, если с прошлой проверки он не изменился.
+func (w *Witness) Check(ctx context.Context, chatID int64) (*verify.SignedTreeHead, error) {
+	prev := w.State.Chats[chatID]
+	var from uint64
+	if prev != nil {
+		from = prev.Size
+	}
+
+	var resp headResponse
+	status, err := w.getJSON(ctx, fmt.Sprintf("/witness/chats/%d/head?from=%d", chatID, from), &resp)
+	if err != nil {
+		if status == http.StatusBadRequest && prev != nil {
+			// сервис не может доказать согласованность с размером, который уже показывал
+			return nil, fmt.Errorf("%w: chat %d: %v", ErrInconsistent, chatID, err)
+		}
+		return nil, err
+	}
+	sth, err := resp.signedTreeHead()
+	if err != nil {
+		return nil, err
+	}
+	if sth.ChatID != chatID {
+		return nil, fmt.Errorf("%w: asked chat %d, got head of chat %d", ErrInconsistent, chatID, sth.ChatID)
+	}
+	if err := w.verifyServerSignature(ctx, sth); err != nil {
+		return nil, err
+	}
+	alg, err := verify.ParseHashAlg(resp.HashAlg)
+	if err != nil {
+		return nil, err
+	}
+
+	if prev != nil {
+		if err := prev.checkNext(alg, sth, resp.FromSize, resp.Consistency); err != nil {
+			return nil, fmt.Errorf("%w: chat %d: %v", ErrInconsistent, chatID, err)
+		}
+		if prev.BatchID == sth.BatchID {
+			return nil, nil
+		}
+	}
+
+	cos := verify.CosignTreeHead(w.Key, sth.TreeHead, w.now())
+	if err := w.submit(ctx, sth.BatchID, cos); err != nil {
+		return nil, err
+	}
+	w.State.Chats[chatID] = &ChatState{
+		BatchID: sth.BatchID,
+		Size:    sth.Size,
+		Root:    hex.EncodeToString(sth.Root),
+		HashAlg: alg.String(),
+	}
+	return sth, nil
+}
+
+// verifyServerSignature проверяет подпись head ключом сервиса из State.ServerKeys
+func (w *Witness) verifyServerSignature(ctx context.Context, sth *verify.SignedTreeHead) error {
+	if _, ok := w.State.ServerKeys[sth.KeyID]; !ok && (w.TrustNewKeys || len(w.State.ServerKeys) == 0) {
+		if err := w.LoadServerKeys(ctx); err != nil {
+			return err
+		}
+	}
+	keys, err := w.State.serverKeys()
+	if err != nil {
+		return err
+	}
+	if err := verify.VerifyTreeHead(sth, keys); err != nil {
+		return fmt.Errorf("chat %d batch %d head: %w", sth.ChatID, sth.BatchID, err)
+	}
+	return nil
+}
+
+// LoadServerKeys добавляет в State ключи из GET /keys. Уже известные ключи не меняются.
+func (w *Witness) LoadServerKeys(ctx context.Context) error {
+	var resp struct {
+		Keys []struct {
+			KeyID     string `json:"key_id"`
+			Algorithm string `json:"algorithm"`
+			PublicKey string `json:"public_key"`
+		} `json:"keys"`
+	}
+	if _, err := w.getJSON(ctx, "/keys", &resp); err != nil {
+		return err
+	}
+	for _, k := range resp.Keys {
+		if _, ok := w.State.ServerKeys[k.KeyID]; ok || k.Algorithm != "ed25519" {
+			continue
+		}
+		pub, err := hex.DecodeString(k.PublicKey)
+		if err != nil || len(pub) != ed25519.PublicKeySize || verify.KeyID(pub) != k.KeyID {
+			return fmt.Errorf("server key %s: malformed public key", k.KeyID)
+		}
+		w.State.ServerKeys[k.KeyID] = k.PublicKey
+	}
+	return nil
+}
+
+func (w *Witness) submit(ctx context.Context, batchID int64, cos *verify.Cosignature) error {
+	body, err := json.Marshal(map[string]any{
+		"key_id":       cos.KeyID,
+		"timestamp_ms": cos.Timestamp.UnixMilli(),
+		"signature":    hex.EncodeToString(cos.Signature),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(w.Server, "/")+"/witness/batches/"+strconv.FormatInt(batchID, 10)+"/cosignatures", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("submit cosignature: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("submit cosignature: status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// getJSON выполняет GET и разбирает JSON ответ. Возвращает статус ответа и для ошибок HTTP.
+func (w *Witness) getJSON(ctx context.Context, path string, v any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(w.Server, "/")+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("GET %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("GET %s: status %d: %s", path, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return resp.StatusCode, fmt.Errorf("GET %s: %w", path, err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,262 @@
+package witness
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"veriChat/go/internal/keyfile"
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubLog - сервис с одним чатом: история payload_hash и подписанный head после каждого батча
+type stubLog struct {
+	t   *testing.T
+	alg verify.HashAlg
+	key ed25519.PrivateKey
+
+	mu      sync.Mutex
+	hashes  [][]byte
+	heads   []*verify.SignedTreeHead
+	cosigs  map[int64][]*verify.Cosignature
+	witness ed25519.PublicKey
+}
+
+func newStubLog(t *testing.T, witness ed25519.PublicKey) *stubLog {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	return &stubLog{t: t, alg: verify.HashSHA3_256, key: key, cosigs: map[int64][]*verify.Cosignature{}, witness: witness}
+}
+
+// appendBatch добавляет n сообщений одним батчем и подписывает head
+func (l *stubLog) appendBatch(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := 0; i < n; i++ {
+		l.hashes = append(l.hashes, l.alg.Sum([]byte(fmt.Sprintf("message %d", len(l.hashes)))))
+	}
+	l.sign()
+}
+
+func (l *stubLog) sign() {
+	l.heads = append(l.heads, verify.SignTreeHead(l.key, verify.TreeHead{
+		ChatID:    1,
+		BatchID:   int64(len(l.heads) + 1),
+		Size:      uint64(len(l.hashes)),
+		Root:      verify.ChatRoot(l.alg, l.hashes),
+		BatchRoot: l.alg.Sum([]byte(fmt.Sprintf("batch %d", len(l.heads)+1))),
+		Timestamp: time.Now(),
+	}))
+}
+
+func (l *stubLog) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		pub := l.key.Public().(ed25519.PublicKey)
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"key_id": verify.KeyID(pub), "algorithm": "ed25519", "public_key": hex.EncodeToString(pub)},
+		}})
+	})
+	mux.HandleFunc("GET /witness/chats/{id}/head", func(w http.ResponseWriter, r *http.Request) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		head := l.heads[len(l.heads)-1]
+		from, _ := strconv.ParseUint(r.URL.Query().Get("from"), 10, 64)
+		resp := headResponse{
+			ChatID:      head.ChatID,
+			BatchID:     head.BatchID,
+			TreeSize:    head.Size,
+			Root:        hex.EncodeToString(head.Root),
+			BatchRoot:   hex.EncodeToString(head.BatchRoot),
+			TimestampMs: head.Timestamp.UnixMilli(),
+			KeyID:       head.KeyID,
+			Signature:   hex.EncodeToString(head.Signature),
+			HashAlg:     l.alg.String(),
+		}
+		if from > 0 {
+			proof, err := verify.ConsistencyProof(l.alg, l.hashes[:head.Size], from)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			resp.FromSize = from
+			for _, h := range proof {
+				resp.Consistency = append(resp.Consistency, hex.EncodeToString(h))
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("POST /witness/batches/{id}/cosignatures", func(w http.ResponseWriter, r *http.Request) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		batchID, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		var req struct {
+			KeyID       string `json:"key_id"`
+			TimestampMs int64  `json:"timestamp_ms"`
+			Signature   string `json:"signature"`
+		}
+		require.NoError(l.t, json.NewDecoder(r.Body).Decode(&req))
+		sig, _ := hex.DecodeString(req.Signature)
+		cos := &verify.Cosignature{KeyID: req.KeyID, Timestamp: time.UnixMilli(req.TimestampMs).UTC(), Signature: sig}
+		if err := cos.Verify(l.witness, l.heads[batchID-1].TreeHead); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		l.cosigs[batchID] = append(l.cosigs[batchID], cos)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func newTestWitness(t *testing.T) (*Witness, *stubLog) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	log := newStubLog(t, key.Public().(ed25519.PublicKey))
+	srv := httptest.NewServer(log.handler())
+	t.Cleanup(srv.Close)
+	return New(srv.URL, key), log
+}
+
+func TestWitnessCosignsConsistentHeads(t *testing.T) {
+	w, log := newTestWitness(t)
+	ctx := context.Background()
+
+	log.appendBatch(5)
+	sth, err := w.Check(ctx, 1)
+	require.NoError(t, err)
+	require.NotNil(t, sth)
+	assert.Equal(t, int64(1), sth.BatchID)
+	assert.Len(t, log.cosigs[1], 1)
+
+	// head не изменился - повторной подписи нет
+	sth, err = w.Check(ctx, 1)
+	require.NoError(t, err)
+	assert.Nil(t, sth)
+
+	for _, n := range []int{3, 1, 8, 16} {
+		log.appendBatch(n)
+		sth, err = w.Check(ctx, 1)
+		require.NoError(t, err)
+		require.NotNil(t, sth)
+		assert.Len(t, log.cosigs[sth.BatchID], 1)
+	}
+	assert.Equal(t, uint64(33), w.State.Chats[1].Size)
+}
+
+func TestWitnessRejectsRewrittenHistory(t *testing.T) {
+	w, log := newTestWitness(t)
+	ctx := context.Background()
+
+	log.appendBatch(6)
+	_, err := w.Check(ctx, 1)
+	require.NoError(t, err)
+	seen := *w.State.Chats[1]
+
+	// сервис переписал уже подписанное сообщение и показывает новую историю
+	log.mu.Lock()
+	log.hashes[2] = log.alg.Sum([]byte("rewritten"))
+	log.mu.Unlock()
+	log.appendBatch(4)
+
+	_, err = w.Check(ctx, 1)
+	assert.ErrorIs(t, err, ErrInconsistent)
+	assert.Empty(t, log.cosigs[2])
+	assert.Equal(t, seen, *w.State.Chats[1])
+}
+
+func TestWitnessRejectsRollback(t *testing.T) {
+	w, log := newTestWitness(t)
+	ctx := context.Background()
+
+	log.appendBatch(10)
+	_, err := w.Check(ctx, 1)
+	require.NoError(t, err)
+
+	// сервис показывает более короткую историю (другой view)
+	log.mu.Lock()
+	log.hashes = log.hashes[:7]
+	log.heads = nil
+	log.sign()
+	log.mu.Unlock()
+
+	_, err = w.Check(ctx, 1)
+	assert.ErrorIs(t, err, ErrInconsistent)
+}
+
+func TestWitnessRejectsForeignSignature(t *testing.T) {
+	w, log := newTestWitness(t)
+	log.appendBatch(3)
+	_, err := w.Check(context.Background(), 1)
+	require.NoError(t, err)
+
+	// сервис сменил ключ: по умолчанию новый ключ из /keys не принимается
+	_, other, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	log.mu.Lock()
+	log.key = other
+	log.mu.Unlock()
+	log.appendBatch(2)
+
+	_, err = w.Check(context.Background(), 1)
+	assert.ErrorIs(t, err, verify.ErrBadSignature)
+	assert.Empty(t, log.cosigs[2])
+	assert.Len(t, w.State.ServerKeys, 1)
+}
+
+func TestWitnessTrustNewKeys(t *testing.T) {
+	w, log := newTestWitness(t)
+	w.TrustNewKeys = true
+	log.appendBatch(3)
+	_, err := w.Check(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, other, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	log.mu.Lock()
+	log.key = other
+	log.mu.Unlock()
+	log.appendBatch(2)
+
+	_, err = w.Check(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Len(t, log.cosigs[2], 1)
+	assert.Len(t, w.State.ServerKeys, 2)
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	w, log := newTestWitness(t)
+	log.appendBatch(4)
+	_, err := w.Check(context.Background(), 1)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, w.State.Save(path))
+	loaded, err := LoadState(path)
+	require.NoError(t, err)
+	assert.Equal(t, w.State, loaded)
+
+	empty, err := LoadState(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, err)
+	assert.Empty(t, empty.Chats)
+
+	keyPath := filepath.Join(t.TempDir(), "witness.pem")
+	key, err := GenerateKey(keyPath)
+	require.NoError(t, err)
+	again, err := keyfile.LoadEd25519Key(keyPath, "witness key")
+	require.NoError(t, err)
+	assert.Equal(t, key, again)
+	_, err = GenerateKey(keyPath)
+	assert.Error(t, err, "existing key must not be overwritten")
+}
@@ -0,0 +1,61 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// Batch - запись merkle_batches (повторяет db.MerkleBatch)
+type Batch struct {
+	BatchID       int64
+	ChatID        int64
+	RootHash      []byte
+	FromMessageID int64
+	ToMessageID   int64
+	TreeVersion   TreeVersion // 0 для записей без версии - TreeLegacy
+	HashAlg       HashAlg     // 0 для записей без алгоритма - HashSHA256
+	ChatSize      uint64      // сообщений в истории чата после батча (см. MMR)
+	ChatRoot      []byte      // root истории чата после батча, nil для старых записей
+	PrevBatchHash []byte      // BatchHash предыдущего батча чата, nil для первого и старых записей
+	CreatedAt     time.Time
+}
+
+// Message - сообщение батча в порядке, в котором оно попало в дерево
+type Message struct {
+	MessageID int64
+	Payload   []byte
+}
+
+// Params правила дерева, по которым построен батч
+func (b Batch) Params() Params {
+	return Params{Version: b.TreeVersion, Hash: b.HashAlg}
+}
+
+// VerifyBatch пересчитывает root батча по его сообщениям по правилам batch.TreeVersion
+// и batch.HashAlg и сверяет с записью. Первое и последнее сообщение должны совпадать с
+// from_message_id и to_message_id.
+func VerifyBatch(batch Batch, messages []Message) error {
+	if len(messages) == 0 {
+		return ErrEmpty
+	}
+	if first := messages[0].MessageID; first != batch.FromMessageID {
+		return fmt.Errorf("batch %d: first message %d, want %d", batch.BatchID, first, batch.FromMessageID)
+	}
+	if last := messages[len(messages)-1].MessageID; last != batch.ToMessageID {
+		return fmt.Errorf("batch %d: last message %d, want %d", batch.BatchID, last, batch.ToMessageID)
+	}
+
+	payloads := make([][]byte, len(messages))
+	for i, m := range messages {
+		payloads[i] = m.Payload
+	}
+	root, err := batch.Params().Root(payloads)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, batch.RootHash) {
+		return fmt.Errorf("batch %d: %w", batch.BatchID, ErrRootMismatch)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package verify
+
+import "errors"
+
+// Builder считает root, получая сообщения по одному. Из NewBuilder хранит только пики
+// полных поддеревьев (O(log n) хешей), из NewTreeBuilder - все O(n) узлов.
+// Root совпадает с Params.Root для тех же сообщений.
+// Повторяет merkle_builder из clib/engine.cpp.
+type Builder struct {
+	p     Params
+	count uint64
+	peaks [][]byte // peaks[h] - корень полного поддерева из 2^h листьев, если бит h count установлен
+
+	keep   bool
+	levels [][][]byte // при keep: узлы полных поддеревьев по уровням, для Tree
+}
+
+// NewBuilder создает построитель по правилам p
+func (p Params) NewBuilder() (*Builder, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	return &Builder{p: p}, nil
+}
+
+// NewTreeBuilder создает построитель, который дополнительно хранит все узлы
+// (HashSize байт на узел, сами сообщения не хранятся) и умеет отдать Tree
+func (p Params) NewTreeBuilder() (*Builder, error) {
+	b, err := p.NewBuilder()
+	if err != nil {
+		return nil, err
+	}
+	b.keep = true
+	return b, nil
+}
+
+// Append добавляет очередное сообщение
+func (b *Builder) Append(message []byte) {
+	node := b.p.LeafHash(message)
+	b.record(0, node)
+	h := 0
+	for c := b.count; c&1 == 1; c >>= 1 {
+		node = b.p.NodeHash(b.peaks[h], node)
+		h++
+		b.record(h, node)
+	}
+	if h == len(b.peaks) {
+		b.peaks = append(b.peaks, nil)
+	}
+	b.peaks[h] = node
+	b.count++
+}
+
+func (b *Builder) record(h int, node []byte) {
+	if !b.keep {
+		return
+	}
+	if h == len(b.levels) {
+		b.levels = append(b.levels, nil)
+	}
+	b.levels[h] = append(b.levels[h], node)
+}
+
+// Len число добавленных сообщений
+func (b *Builder) Len() uint64 {
+	return b.count
+}
+
+// Root root всех добавленных сообщений. Builder можно продолжать после вызова.
+func (b *Builder) Root() ([]byte, error) {
+	if b.count == 0 {
+		return nil, ErrEmpty
+	}
+	return b.fold(nil), nil
+}
+
+// Tree все уровни дерева добавленных сообщений (только для NewTreeBuilder)
+func (b *Builder) Tree() (*Tree, error) {
+	if !b.keep {
+		return nil, errors.New("builder does not keep levels, use NewTreeBuilder")
+	}
+	if b.count == 0 {
+		return nil, ErrEmpty
+	}
+	t := &Tree{Params: b.p, N: int(b.count), Nodes: make([]byte, 0, TreeNodes(int(b.count))*HashSize)}
+	b.fold(func(h int, carry []byte) {
+		if h < len(b.levels) {
+			for _, node := range b.levels[h] {
+				t.Nodes = append(t.Nodes, node...)
+			}
+		}
+		t.Nodes = append(t.Nodes, carry...)
+	})
+	return t, nil
+}
+
+// fold сворачивает пики снизу вверх и возвращает root. emit (если не nil)
+// получает каждый уровень h и его неполный последний узел (nil, если его нет).
+func (b *Builder) fold(emit func(h int, carry []byte)) []byte {
+	legacy := b.p.version() == TreeLegacy
+	var carry []byte // неполный правый узел текущего уровня
+	for h := 0; ; h++ {
+		if emit != nil {
+			emit(h, carry)
+		}
+		full := b.count >> h
+		if full == 1 && carry == nil {
+			return b.peaks[h]
+		}
+		if full == 0 {
+			return carry
+		}
+		switch {
+		case full&1 == 1 && carry != nil:
+			carry = b.p.NodeHash(b.peaks[h], carry)
+		case full&1 == 1 && legacy:
+			carry = b.p.NodeHash(b.peaks[h], b.peaks[h])
+		case full&1 == 1:
+			carry = b.peaks[h]
+		case carry != nil && legacy:
+			// нечетный уровень: неполный узел в паре сам с собой
+			carry = b.p.NodeHash(carry, carry)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package verify_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilderMatchesRoot(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	messages := randomMessages(rng, 140)
+	for _, p := range []verify.Params{verify.Legacy, {Version: verify.TreeRFC6962, Hash: verify.HashSHA512_256}} {
+		b, err := p.NewBuilder()
+		require.NoError(t, err)
+		_, err = b.Root()
+		assert.ErrorIs(t, err, verify.ErrEmpty)
+
+		for n := 1; n <= len(messages); n++ {
+			b.Append(messages[n-1])
+			got, err := b.Root()
+			require.NoError(t, err)
+			want, err := p.Root(messages[:n])
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "version=%v n=%d", p.Version, n)
+		}
+	}
+
+	_, err := verify.Params{Hash: 42}.NewBuilder()
+	assert.ErrorIs(t, err, verify.ErrHashAlg)
+}
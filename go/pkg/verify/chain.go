@@ -0,0 +1,91 @@
+package verify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrChainBroken - цепочка батчей чата разорвана (батч удален, подменен или переставлен)
+var ErrChainBroken = errors.New("batch chain broken")
+
+// batchHashDomain отделяет хеш записи батча от хешей листьев и узлов
+const batchHashDomain = "veriChat/batch/v1"
+
+// BatchHash хеш записи батча для prev_batch_hash следующего батча чата.
+// Покрывает все поля, которые задает сервис (кроме created_at, его ставит MySQL),
+// включая prev_batch_hash, поэтому батчи чата образуют цепочку хешей.
+// Алгоритм - HashAlg батча.
+func BatchHash(b Batch) []byte {
+	h := b.Params().hash().New()
+	h.Write([]byte(batchHashDomain))
+	var buf [8]byte
+	for _, v := range []uint64{uint64(b.BatchID), uint64(b.ChatID), uint64(b.FromMessageID), uint64(b.ToMessageID),
+		uint64(b.Params().version()), uint64(b.Params().hash()), b.ChatSize} {
+		binary.BigEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	for _, field := range [][]byte{b.RootHash, b.ChatRoot, b.PrevBatchHash} {
+		h.Write([]byte{byte(len(field))})
+		h.Write(field)
+	}
+	return h.Sum(nil)
+}
+
+// GenesisBatchHash prev_batch_hash первого батча чата (32 нулевых байта)
+func GenesisBatchHash() []byte {
+	return make([]byte, HashSize)
+}
+
+// ChainError - первое нарушенное звено цепочки
+type ChainError struct {
+	Index   int   // позиция батча в переданном срезе
+	BatchID int64 // батч, у которого не сходится prev_batch_hash
+	Reason  string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("batch %d: %s", e.BatchID, e.Reason)
+}
+
+func (e *ChainError) Unwrap() error {
+	return ErrChainBroken
+}
+
+// VerifyChain проходит все батчи одного чата по возрастанию batch_id и проверяет, что
+// prev_batch_hash каждого равен BatchHash предыдущего, а у первого - GenesisBatchHash.
+// Батчи без prev_batch_hash допустимы только в начале (записи до появления цепочки).
+// Возвращает *ChainError для первого нарушенного звена.
+func VerifyChain(batches []Batch) error {
+	linked := false
+	for i, b := range batches {
+		if i > 0 {
+			prev := batches[i-1]
+			if b.ChatID != prev.ChatID {
+				return &ChainError{Index: i, BatchID: b.BatchID, Reason: fmt.Sprintf("chat %d, want %d", b.ChatID, prev.ChatID)}
+			}
+			if b.BatchID <= prev.BatchID {
+				return &ChainError{Index: i, BatchID: b.BatchID, Reason: fmt.Sprintf("follows batch %d", prev.BatchID)}
+			}
+		}
+		if b.PrevBatchHash == nil {
+			if linked {
+				return &ChainError{Index: i, BatchID: b.BatchID, Reason: "missing prev_batch_hash"}
+			}
+			continue
+		}
+		if i == 0 {
+			if !bytes.Equal(b.PrevBatchHash, GenesisBatchHash()) {
+				return &ChainError{Index: i, BatchID: b.BatchID, Reason: "links to a missing earlier batch"}
+			}
+			linked = true
+			continue
+		}
+		if !bytes.Equal(b.PrevBatchHash, BatchHash(batches[i-1])) {
+			return &ChainError{Index: i, BatchID: b.BatchID, Reason: fmt.Sprintf("prev_batch_hash does not match batch %d", batches[i-1].BatchID)}
+		}
+		linked = true
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package verify_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chainedBatches(n int) []verify.Batch {
+	batches := make([]verify.Batch, n)
+	prev := verify.GenesisBatchHash()
+	for i := range batches {
+		batches[i] = verify.Batch{
+			BatchID:       int64(10 + i),
+			ChatID:        1,
+			RootHash:      verify.HashSHA256.Sum([]byte(fmt.Sprintf("root %d", i))),
+			FromMessageID: int64(100 * i),
+			ToMessageID:   int64(100*i + 99),
+			TreeVersion:   verify.TreeRFC6962,
+			HashAlg:       verify.HashSHA256,
+			ChatSize:      uint64(100 * (i + 1)),
+			ChatRoot:      verify.HashSHA256.Sum([]byte(fmt.Sprintf("chat root %d", i))),
+			PrevBatchHash: prev,
+		}
+		prev = verify.BatchHash(batches[i])
+	}
+	return batches
+}
+
+func brokenAt(t *testing.T, err error) int64 {
+	t.Helper()
+	require.ErrorIs(t, err, verify.ErrChainBroken)
+	var ce *verify.ChainError
+	require.True(t, errors.As(err, &ce))
+	return ce.BatchID
+}
+
+func TestVerifyChain(t *testing.T) {
+	assert.NoError(t, verify.VerifyChain(chainedBatches(6)))
+	assert.NoError(t, verify.VerifyChain(nil))
+
+	// удаленный батч в середине
+	batches := chainedBatches(6)
+	deleted := append(append([]verify.Batch(nil), batches[:2]...), batches[3:]...)
+	assert.Equal(t, int64(13), brokenAt(t, verify.VerifyChain(deleted)))
+
+	// удаленный первый батч
+	assert.Equal(t, int64(11), brokenAt(t, verify.VerifyChain(batches[1:])))
+
+	// подмененный root
+	batches = chainedBatches(6)
+	batches[2].RootHash = verify.HashSHA256.Sum([]byte("forged"))
+	assert.Equal(t, int64(13), brokenAt(t, verify.VerifyChain(batches)))
+
+	// переставленные батчи
+	batches = chainedBatches(6)
+	batches[2], batches[3] = batches[3], batches[2]
+	assert.Equal(t, int64(13), brokenAt(t, verify.VerifyChain(batches)))
+
+	// после начала цепочки prev_batch_hash обязателен
+	batches = chainedBatches(4)
+	batches[3].PrevBatchHash = nil
+	assert.Equal(t, int64(13), brokenAt(t, verify.VerifyChain(batches)))
+}
+
+func TestVerifyChainLegacyPrefix(t *testing.T) {
+	batches := chainedBatches(5)
+	// первые два батча созданы до цепочки, третий ссылается на второй
+	batches[0].PrevBatchHash = nil
+	batches[1].PrevBatchHash = nil
+	batches[2].PrevBatchHash = verify.BatchHash(batches[1])
+	for i := 3; i < len(batches); i++ {
+		batches[i].PrevBatchHash = verify.BatchHash(batches[i-1])
+	}
+	assert.NoError(t, verify.VerifyChain(batches))
+
+	batches[1].ChatRoot = nil
+	assert.Equal(t, int64(12), brokenAt(t, verify.VerifyChain(batches)))
+}
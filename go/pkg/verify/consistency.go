@@ -0,0 +1,120 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ConsistencyProof строит доказательство согласованности (RFC 6962, 2.1.2) того, что
+// история из первых m сообщений - префикс истории из len(payloadHashes) сообщений.
+// payloadHashes - payload_hash сообщений чата в порядке истории (см. MMR).
+func ConsistencyProof(alg HashAlg, payloadHashes [][]byte, m uint64) ([][]byte, error) {
+	if err := alg.check(); err != nil {
+		return nil, err
+	}
+	n := uint64(len(payloadHashes))
+	if m == 0 || m > n {
+		return nil, fmt.Errorf("%w: consistency %d..%d", ErrIndex, m, n)
+	}
+	p := Params{Version: TreeRFC6962, Hash: alg}
+	return p.subproof(m, p.leafHashes(payloadHashes), true), nil
+}
+
+// subproof - SUBPROOF(m, D[n], b) из RFC 6962
+func (p Params) subproof(m uint64, leaves [][]byte, complete bool) [][]byte {
+	n := uint64(len(leaves))
+	if m == n {
+		if complete {
+			return [][]byte{}
+		}
+		return [][]byte{p.leafHashesRoot(leaves)}
+	}
+	k := splitPoint(n)
+	if m <= k {
+		return append(p.subproof(m, leaves[:k], complete), p.leafHashesRoot(leaves[k:]))
+	}
+	return append(p.subproof(m-k, leaves[k:], false), p.leafHashesRoot(leaves[:k]))
+}
+
+// leafHashesRoot корень дерева RFC 6962 над готовыми хешами листьев
+func (p Params) leafHashesRoot(leaves [][]byte) []byte {
+	m := &MMR{Hash: p.hash()}
+	for _, l := range leaves {
+		m.AppendLeafHash(l)
+	}
+	return m.Root()
+}
+
+// splitPoint наибольшая степень двойки, меньшая n (n > 1)
+func splitPoint(n uint64) uint64 {
+	k := uint64(1)
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// VerifyConsistency проверяет, что oldRoot (история из m сообщений) - префикс
+// newRoot (история из n сообщений). Алгоритм RFC 9162, 2.1.4.2.
+func VerifyConsistency(alg HashAlg, m, n uint64, oldRoot, newRoot []byte, proof [][]byte) error {
+	if err := alg.check(); err != nil {
+		return err
+	}
+	if m == 0 || m > n {
+		return fmt.Errorf("%w: consistency %d..%d", ErrIndex, m, n)
+	}
+	for i, h := range proof {
+		if len(h) != HashSize {
+			return fmt.Errorf("%w: hash %d has %d bytes", ErrBadProof, i, len(h))
+		}
+	}
+	if m == n {
+		if len(proof) != 0 {
+			return fmt.Errorf("%w: equal sizes need an empty proof", ErrBadProof)
+		}
+		if !bytes.Equal(oldRoot, newRoot) {
+			return ErrRootMismatch
+		}
+		return nil
+	}
+
+	p := Params{Version: TreeRFC6962, Hash: alg}
+	if m&(m-1) == 0 {
+		// старое дерево полное - его корень сам является узлом нового
+		proof = append([][]byte{oldRoot}, proof...)
+	}
+	if len(proof) == 0 {
+		return ErrBadProof
+	}
+
+	fn, sn := m-1, n-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return fmt.Errorf("%w: proof too long", ErrBadProof)
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = p.NodeHash(c, fr)
+			sr = p.NodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = p.NodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return fmt.Errorf("%w: proof too short", ErrBadProof)
+	}
+	if !bytes.Equal(fr, oldRoot) || !bytes.Equal(sr, newRoot) {
+		return ErrRootMismatch
+	}
+	return nil
+}
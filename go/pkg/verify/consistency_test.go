@@ -0,0 +1,58 @@
+package verify_test
+
+import (
+	"testing"
+
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistencyProofAllSizes(t *testing.T) {
+	for _, alg := range []verify.HashAlg{verify.HashSHA256, verify.HashSHA512_256} {
+		hashes := payloadHashes(alg, 40)
+		for n := 1; n <= len(hashes); n++ {
+			newRoot := verify.ChatRoot(alg, hashes[:n])
+			for m := 1; m <= n; m++ {
+				oldRoot := verify.ChatRoot(alg, hashes[:m])
+				proof, err := verify.ConsistencyProof(alg, hashes[:n], uint64(m))
+				require.NoError(t, err)
+				assert.NoError(t, verify.VerifyConsistency(alg, uint64(m), uint64(n), oldRoot, newRoot, proof),
+					"alg=%v m=%d n=%d", alg, m, n)
+			}
+		}
+	}
+}
+
+func TestConsistencyProofRejects(t *testing.T) {
+	alg := verify.HashSHA256
+	hashes := payloadHashes(alg, 13)
+	oldRoot := verify.ChatRoot(alg, hashes[:6])
+	newRoot := verify.ChatRoot(alg, hashes)
+
+	proof, err := verify.ConsistencyProof(alg, hashes, 6)
+	require.NoError(t, err)
+	require.NoError(t, verify.VerifyConsistency(alg, 6, 13, oldRoot, newRoot, proof))
+
+	// переписанная история: другое сообщение внутри первых 6
+	rewritten := append([][]byte(nil), hashes...)
+	rewritten[2] = alg.Sum([]byte("rewritten"))
+	assert.ErrorIs(t, verify.VerifyConsistency(alg, 6, 13, oldRoot, verify.ChatRoot(alg, rewritten), proof),
+		verify.ErrRootMismatch)
+	forged, err := verify.ConsistencyProof(alg, rewritten, 6)
+	require.NoError(t, err)
+	assert.ErrorIs(t, verify.VerifyConsistency(alg, 6, 13, oldRoot, verify.ChatRoot(alg, rewritten), forged),
+		verify.ErrRootMismatch)
+
+	assert.Error(t, verify.VerifyConsistency(alg, 5, 13, oldRoot, newRoot, proof))
+	assert.ErrorIs(t, verify.VerifyConsistency(alg, 6, 13, oldRoot, newRoot, proof[:len(proof)-1]), verify.ErrBadProof)
+	assert.ErrorIs(t, verify.VerifyConsistency(alg, 6, 13, oldRoot, newRoot, append(proof, proof[0])), verify.ErrBadProof)
+	assert.ErrorIs(t, verify.VerifyConsistency(alg, 13, 13, newRoot, newRoot, proof), verify.ErrBadProof)
+	assert.NoError(t, verify.VerifyConsistency(alg, 13, 13, newRoot, newRoot, nil))
+
+	_, err = verify.ConsistencyProof(alg, hashes, 0)
+	assert.ErrorIs(t, err, verify.ErrIndex)
+	_, err = verify.ConsistencyProof(alg, hashes, 14)
+	assert.ErrorIs(t, err, verify.ErrIndex)
+}
@@ -0,0 +1,51 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// cosignatureDomain отделяет подпись свидетеля от подписи tree head сервисом
+const cosignatureDomain = "veriChat/cosignature/v1"
+
+// Cosignature - подпись свидетеля (witness) под tree head: свидетель подтверждает,
+// что head согласован со всем, что он видел от сервиса раньше
+type Cosignature struct {
+	KeyID     string    // KeyID ключа свидетеля
+	Timestamp time.Time // когда свидетель проверил head
+	Signature []byte
+}
+
+// CosignatureMessage байты, которые подписывает свидетель: домен, время
+// (миллисекунды Unix, big-endian) и TreeHead.Message()
+func CosignatureMessage(head TreeHead, ts time.Time) []byte {
+	msg := []byte(cosignatureDomain)
+	msg = binary.BigEndian.AppendUint64(msg, uint64(ts.UnixMilli()))
+	return append(msg, head.Message()...)
+}
+
+// CosignTreeHead подписывает head ключом свидетеля. ts усекается до миллисекунд.
+func CosignTreeHead(key ed25519.PrivateKey, head TreeHead, ts time.Time) *Cosignature {
+	ts = time.UnixMilli(ts.UnixMilli()).UTC()
+	return &Cosignature{
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Timestamp: ts,
+		Signature: ed25519.Sign(key, CosignatureMessage(head, ts)),
+	}
+}
+
+// Verify проверяет подпись свидетеля с ключом pub под head
+func (c *Cosignature) Verify(pub ed25519.PublicKey, head TreeHead) error {
+	if len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: public key has %d bytes", ErrBadSignature, len(pub))
+	}
+	if id := KeyID(pub); id != c.KeyID {
+		return fmt.Errorf("%w: cosigned by key %s, got key %s", ErrBadSignature, c.KeyID, id)
+	}
+	if !ed25519.Verify(pub, CosignatureMessage(head, c.Timestamp), c.Signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package verify
+
+import (
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+)
+
+// HashSize размер хеша узла. Все поддерживаемые алгоритмы дают 32 байта.
+const HashSize = 32
+
+// HashAlg алгоритм хеширования (хранится в merkle_batches.hash_alg, см. ENGINE_HASH_* в engine.h)
+type HashAlg int
+
+const (
+	HashSHA256     HashAlg = 1
+	HashSHA512_256 HashAlg = 2
+	HashSHA3_256   HashAlg = 3
+)
+
+var hashNames = map[HashAlg]string{
+	HashSHA256:     "sha256",
+	HashSHA512_256: "sha512_256",
+	HashSHA3_256:   "sha3_256",
+}
+
+func (a HashAlg) String() string {
+	if name, ok := hashNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("HashAlg(%d)", int(a))
+}
+
+// ParseHashAlg разбирает имя алгоритма: sha256, sha512_256, sha3_256
+func ParseHashAlg(name string) (HashAlg, error) {
+	for alg, n := range hashNames {
+		if n == name {
+			return alg, nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q", ErrHashAlg, name)
+}
+
+func (a HashAlg) check() error {
+	if _, ok := hashNames[a]; !ok {
+		return fmt.Errorf("%w %d", ErrHashAlg, int(a))
+	}
+	return nil
+}
+
+// New возвращает новый hash.Hash алгоритма
+func (a HashAlg) New() hash.Hash {
+	switch a {
+	case HashSHA512_256:
+		return sha512.New512_256()
+	case HashSHA3_256:
+		return sha3.New256()
+	}
+	return sha256.New()
+}
+
+// Sum хеш data без префиксов (payload_hash сообщения)
+func (a HashAlg) Sum(data []byte) []byte {
+	h := a.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,40 @@
+package verify_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashAlgSum(t *testing.T) {
+	tests := []struct {
+		alg  verify.HashAlg
+		want string
+	}{
+		{verify.HashSHA256, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{verify.HashSHA512_256, "53048e2681941ef99b2e29b76b4c7dabe4c2d0c634fc6d46e0e2f13107e7af23"},
+		{verify.HashSHA3_256, "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg.String(), func(t *testing.T) {
+			assert.Equal(t, tt.want, hex.EncodeToString(tt.alg.Sum([]byte("abc"))))
+			assert.Equal(t, verify.HashSize, tt.alg.New().Size())
+
+			parsed, err := verify.ParseHashAlg(tt.alg.String())
+			require.NoError(t, err)
+			assert.Equal(t, tt.alg, parsed)
+		})
+	}
+
+	_, err := verify.ParseHashAlg("md5")
+	assert.ErrorIs(t, err, verify.ErrHashAlg)
+}
+
+func TestParamsUnknownHashAlg(t *testing.T) {
+	_, err := verify.Params{Hash: 42}.Root([][]byte{[]byte("a")})
+	assert.ErrorIs(t, err, verify.ErrHashAlg)
+}
@@ -0,0 +1,101 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// MMR - append-only аккумулятор всей истории чата (Merkle Mountain Range).
+//
+// Листья - payload_hash сообщений чата в порядке (batch_id, message_id),
+// лист = H(0x00 || payload_hash), узел = H(0x01 || левый || правый).
+// Пики - корни полных поддеревьев, их высоты соответствуют битам Size.
+// Root сворачивает пики справа налево и совпадает с корнем дерева RFC 6962
+// над всеми листьями, поэтому один root подтверждает весь транскрипт.
+type MMR struct {
+	Hash  HashAlg
+	Size  uint64
+	Peaks [][]byte // от самого высокого (левого) к самому низкому
+}
+
+func (m *MMR) params() Params {
+	return Params{Version: TreeRFC6962, Hash: m.Hash}
+}
+
+// Append добавляет сообщение по его payload_hash
+func (m *MMR) Append(payloadHash []byte) {
+	m.AppendLeafHash(m.params().LeafHash(payloadHash))
+}
+
+// AppendLeafHash добавляет уже посчитанный хеш листа
+func (m *MMR) AppendLeafHash(leaf []byte) {
+	p := m.params()
+	node := leaf
+	// каждый единичный младший бит Size - пик той же высоты, что и node
+	for s := m.Size; s&1 == 1; s >>= 1 {
+		left := m.Peaks[len(m.Peaks)-1]
+		m.Peaks = m.Peaks[:len(m.Peaks)-1]
+		node = p.NodeHash(left, node)
+	}
+	m.Peaks = append(m.Peaks, node)
+	m.Size++
+}
+
+// Root корень всей истории. Для пустого аккумулятора - H() как в RFC 6962.
+func (m *MMR) Root() []byte {
+	if len(m.Peaks) == 0 {
+		return m.params().hash().Sum(nil)
+	}
+	p := m.params()
+	root := m.Peaks[len(m.Peaks)-1]
+	for i := len(m.Peaks) - 2; i >= 0; i-- {
+		root = p.NodeHash(m.Peaks[i], root)
+	}
+	return root
+}
+
+// Check проверяет, что число пиков соответствует Size
+func (m *MMR) Check() error {
+	if err := m.params().check(); err != nil {
+		return err
+	}
+	want := 0
+	for s := m.Size; s > 0; s >>= 1 {
+		want += int(s & 1)
+	}
+	if len(m.Peaks) != want {
+		return fmt.Errorf("mmr: size %d needs %d peaks, got %d", m.Size, want, len(m.Peaks))
+	}
+	for i, p := range m.Peaks {
+		if len(p) != HashSize {
+			return fmt.Errorf("mmr: peak %d has %d bytes", i, len(p))
+		}
+	}
+	return nil
+}
+
+// MarshalPeaks пики одним куском (так они хранятся в MySQL)
+func (m *MMR) MarshalPeaks() []byte {
+	return bytes.Join(m.Peaks, nil)
+}
+
+// UnmarshalPeaks восстанавливает пики из MarshalPeaks
+func (m *MMR) UnmarshalPeaks(data []byte) error {
+	if len(data)%HashSize != 0 {
+		return fmt.Errorf("mmr: peaks blob has %d bytes", len(data))
+	}
+	m.Peaks = make([][]byte, 0, len(data)/HashSize)
+	for i := 0; i < len(data); i += HashSize {
+		m.Peaks = append(m.Peaks, bytes.Clone(data[i:i+HashSize]))
+	}
+	return m.Check()
+}
+
+// ChatRoot считает root истории чата по payload_hash всех его сообщений
+func ChatRoot(alg HashAlg, payloadHashes [][]byte) []byte {
+	m := &MMR{Hash: alg}
+	for _, h := range payloadHashes {
+		m.Append(h)
+	}
+	return m.Root()
+}
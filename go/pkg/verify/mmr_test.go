@@ -0,0 +1,65 @@
+package verify_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func payloadHashes(alg verify.HashAlg, n int) [][]byte {
+	hashes := make([][]byte, n)
+	for i := range hashes {
+		hashes[i] = alg.Sum([]byte(fmt.Sprintf("chat message %d", i)))
+	}
+	return hashes
+}
+
+func TestMMRRootMatchesRFC6962Tree(t *testing.T) {
+	for _, alg := range []verify.HashAlg{verify.HashSHA256, verify.HashSHA3_256} {
+		hashes := payloadHashes(alg, 100)
+		tree := verify.Params{Version: verify.TreeRFC6962, Hash: alg}
+		m := &verify.MMR{Hash: alg}
+
+		for n := 1; n <= len(hashes); n++ {
+			m.Append(hashes[n-1])
+			require.NoError(t, m.Check())
+
+			want, err := tree.Root(hashes[:n])
+			require.NoError(t, err)
+			assert.Equal(t, want, m.Root(), "alg=%v n=%d", alg, n)
+			assert.Equal(t, want, verify.ChatRoot(alg, hashes[:n]), "alg=%v n=%d", alg, n)
+		}
+	}
+}
+
+func TestMMRPeaksRoundTrip(t *testing.T) {
+	m := &verify.MMR{Hash: verify.HashSHA256}
+	for _, h := range payloadHashes(verify.HashSHA256, 13) {
+		m.Append(h)
+	}
+	assert.Len(t, m.Peaks, 3, "13 = 8 + 4 + 1")
+
+	restored := &verify.MMR{Hash: verify.HashSHA256, Size: m.Size}
+	require.NoError(t, restored.UnmarshalPeaks(m.MarshalPeaks()))
+	assert.Equal(t, m.Root(), restored.Root())
+
+	// продолжение после восстановления дает тот же root
+	extra := verify.HashSHA256.Sum([]byte("next"))
+	m.Append(extra)
+	restored.Append(extra)
+	assert.Equal(t, m.Root(), restored.Root())
+
+	wrongSize := &verify.MMR{Hash: verify.HashSHA256, Size: 12}
+	assert.Error(t, wrongSize.UnmarshalPeaks(m.MarshalPeaks()))
+	assert.Error(t, restored.UnmarshalPeaks([]byte("short")))
+}
+
+func TestMMREmptyRoot(t *testing.T) {
+	empty := sha256.Sum256(nil)
+	assert.Equal(t, empty[:], (&verify.MMR{}).Root())
+}
@@ -0,0 +1,152 @@
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+)
+
+// MultiProof - один proof для нескольких сообщений батча. Узлы, общие для путей
+// разных листьев или вычислимые из самих листьев, в Nodes не повторяются.
+type MultiProof struct {
+	N       int      // листьев в батче
+	Indices []int    // позиции сообщений по возрастанию, без повторов
+	Nodes   [][]byte // недостающие узлы: уровни снизу вверх, на уровне слева направо
+}
+
+// normalizeIndices сортирует позиции и убирает повторы
+func normalizeIndices(indices []int, n int) ([]int, error) {
+	if len(indices) == 0 {
+		return nil, ErrEmpty
+	}
+	sorted := slices.Clone(indices)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	if sorted[0] < 0 || sorted[len(sorted)-1] >= n {
+		return nil, ErrIndex
+	}
+	return sorted, nil
+}
+
+// MultiProof строит multiproof для листьев indices по готовому дереву
+func (t *Tree) MultiProof(indices []int) (*MultiProof, error) {
+	known, err := normalizeIndices(indices, t.N)
+	if err != nil {
+		return nil, err
+	}
+	mp := &MultiProof{N: t.N, Indices: slices.Clone(known), Nodes: [][]byte{}}
+
+	start, count := 0, t.N
+	for count > 1 {
+		next := make([]int, 0, len(known))
+		for i := 0; i < len(known); i++ {
+			pos := known[i]
+			switch {
+			case pos%2 == 1:
+				// левый сосед неизвестен, иначе пара обработана бы на нем
+				mp.Nodes = append(mp.Nodes, t.node(start+pos-1))
+			case pos+1 < count && i+1 < len(known) && known[i+1] == pos+1:
+				i++ // оба узла пары известны
+			case pos+1 < count:
+				mp.Nodes = append(mp.Nodes, t.node(start+pos+1))
+			}
+			// последний узел нечетного уровня: пара сам с собой (TreeLegacy) или подъем (TreeRFC6962)
+			next = append(next, pos/2)
+		}
+		known = next
+		start += count
+		count = (count + 1) / 2
+	}
+	return mp, nil
+}
+
+// BuildMultiProof строит multiproof для messages[indices]
+func (p Params) BuildMultiProof(messages [][]byte, indices []int) (*MultiProof, error) {
+	t, err := p.Tree(messages)
+	if err != nil {
+		return nil, err
+	}
+	return t.MultiProof(indices)
+}
+
+// RootFromMultiProof поднимается от сообщений к корню по multiproof.
+// messages[i] - сообщение на позиции mp.Indices[i].
+func (p Params) RootFromMultiProof(messages [][]byte, mp *MultiProof) ([]byte, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	if mp == nil || len(messages) != len(mp.Indices) {
+		return nil, ErrBadProof
+	}
+	known, err := normalizeIndices(mp.Indices, mp.N)
+	if err != nil || !slices.Equal(known, mp.Indices) {
+		return nil, fmt.Errorf("%w: indices must be sorted, unique and below %d", ErrBadProof, mp.N)
+	}
+	for i, node := range mp.Nodes {
+		if len(node) != HashSize {
+			return nil, fmt.Errorf("%w: node %d has %d bytes", ErrBadProof, i, len(node))
+		}
+	}
+
+	hashes := p.leafHashes(messages)
+	nodes := mp.Nodes
+	take := func() ([]byte, error) {
+		if len(nodes) == 0 {
+			return nil, fmt.Errorf("%w: proof too short", ErrBadProof)
+		}
+		node := nodes[0]
+		nodes = nodes[1:]
+		return node, nil
+	}
+
+	count := mp.N
+	for count > 1 {
+		nextKnown := make([]int, 0, len(known))
+		nextHashes := make([][]byte, 0, len(known))
+		for i := 0; i < len(known); i++ {
+			pos, cur := known[i], hashes[i]
+			var parent []byte
+			switch {
+			case pos%2 == 1:
+				sib, err := take()
+				if err != nil {
+					return nil, err
+				}
+				parent = p.NodeHash(sib, cur)
+			case pos+1 < count && i+1 < len(known) && known[i+1] == pos+1:
+				parent = p.NodeHash(cur, hashes[i+1])
+				i++
+			case pos+1 < count:
+				sib, err := take()
+				if err != nil {
+					return nil, err
+				}
+				parent = p.NodeHash(cur, sib)
+			case p.version() == TreeLegacy:
+				parent = p.NodeHash(cur, cur)
+			default:
+				parent = cur
+			}
+			nextKnown = append(nextKnown, pos/2)
+			nextHashes = append(nextHashes, parent)
+		}
+		known, hashes = nextKnown, nextHashes
+		count = (count + 1) / 2
+	}
+	if len(nodes) != 0 {
+		return nil, fmt.Errorf("%w: %d unused nodes", ErrBadProof, len(nodes))
+	}
+	return hashes[0], nil
+}
+
+// VerifyMultiProof проверяет, что все messages входят в дерево с корнем root
+func (p Params) VerifyMultiProof(messages [][]byte, mp *MultiProof, root []byte) error {
+	got, err := p.RootFromMultiProof(messages, mp)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, root) {
+		return ErrRootMismatch
+	}
+	return nil
+}
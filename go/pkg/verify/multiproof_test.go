@@ -0,0 +1,88 @@
+package verify_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiProofVerifies(t *testing.T) {
+	rng := rand.New(rand.NewSource(8))
+	for _, p := range []verify.Params{verify.Legacy, {Version: verify.TreeRFC6962}} {
+		for n := 1; n <= 40; n++ {
+			messages := randomMessages(rng, n)
+			root, err := p.Root(messages)
+			require.NoError(t, err)
+
+			for trial := 0; trial < 10; trial++ {
+				indices := rng.Perm(n)[:1+rng.Intn(n)]
+				mp, err := p.BuildMultiProof(messages, indices)
+				require.NoError(t, err)
+
+				leaves := make([][]byte, len(mp.Indices))
+				singles := 0
+				for i, idx := range mp.Indices {
+					leaves[i] = messages[idx]
+					proof, err := p.BuildProof(messages, idx)
+					require.NoError(t, err)
+					singles += len(proof.Siblings)
+				}
+				assert.NoError(t, p.VerifyMultiProof(leaves, mp, root), "version=%v n=%d indices=%v", p.Version, n, mp.Indices)
+				assert.LessOrEqual(t, len(mp.Nodes), singles)
+			}
+		}
+	}
+}
+
+func TestMultiProofDeduplicates(t *testing.T) {
+	messages := randomMessages(rand.New(rand.NewSource(9)), 8)
+	p := verify.Params{Version: verify.TreeRFC6962}
+
+	// весь батч: узлы не нужны
+	mp, err := p.BuildMultiProof(messages, []int{7, 6, 5, 4, 3, 2, 1, 0, 3})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, mp.Indices)
+	assert.Empty(t, mp.Nodes)
+
+	// соседние листья: один общий путь
+	mp, err = p.BuildMultiProof(messages, []int{2, 3})
+	require.NoError(t, err)
+	assert.Len(t, mp.Nodes, 2)
+}
+
+func TestMultiProofRejects(t *testing.T) {
+	messages := randomMessages(rand.New(rand.NewSource(10)), 13)
+	p := verify.Params{Version: verify.TreeRFC6962}
+	root, err := p.Root(messages)
+	require.NoError(t, err)
+
+	mp, err := p.BuildMultiProof(messages, []int{1, 6, 12})
+	require.NoError(t, err)
+	leaves := [][]byte{messages[1], messages[6], messages[12]}
+	require.NoError(t, p.VerifyMultiProof(leaves, mp, root))
+
+	tampered := [][]byte{messages[1], []byte("x"), messages[12]}
+	assert.ErrorIs(t, p.VerifyMultiProof(tampered, mp, root), verify.ErrRootMismatch)
+
+	short := *mp
+	short.Nodes = mp.Nodes[:len(mp.Nodes)-1]
+	assert.ErrorIs(t, p.VerifyMultiProof(leaves, &short, root), verify.ErrBadProof)
+
+	long := *mp
+	long.Nodes = append(append([][]byte(nil), mp.Nodes...), mp.Nodes[0])
+	assert.ErrorIs(t, p.VerifyMultiProof(leaves, &long, root), verify.ErrBadProof)
+
+	unsorted := *mp
+	unsorted.Indices = []int{6, 1, 12}
+	assert.ErrorIs(t, p.VerifyMultiProof(leaves, &unsorted, root), verify.ErrBadProof)
+	assert.ErrorIs(t, p.VerifyMultiProof(leaves[:2], mp, root), verify.ErrBadProof)
+
+	_, err = p.BuildMultiProof(messages, []int{13})
+	assert.ErrorIs(t, err, verify.ErrIndex)
+	_, err = p.BuildMultiProof(messages, nil)
+	assert.ErrorIs(t, err, verify.ErrEmpty)
+}
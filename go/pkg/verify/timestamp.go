@@ -0,0 +1,138 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// ErrTimestampMismatch - метка времени выдана для других данных
+var ErrTimestampMismatch = errors.New("timestamp does not cover the digest")
+
+var hashOIDs = map[HashAlg]asn1.ObjectIdentifier{
+	HashSHA256:     {2, 16, 840, 1, 101, 3, 4, 2, 1},
+	HashSHA512_256: {2, 16, 840, 1, 101, 3, 4, 2, 6},
+	HashSHA3_256:   {2, 16, 840, 1, 101, 3, 4, 2, 8},
+}
+
+// MessageImprint - хеш данных, на которые выдана метка (RFC 3161, 2.4.1)
+type MessageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// NewMessageImprint imprint для digest, посчитанного алгоритмом alg
+func NewMessageImprint(alg HashAlg, digest []byte) (MessageImprint, error) {
+	oid, ok := hashOIDs[alg]
+	if !ok {
+		return MessageImprint{}, fmt.Errorf("%w %d", ErrHashAlg, int(alg))
+	}
+	return MessageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oid},
+		HashedMessage: bytes.Clone(digest),
+	}, nil
+}
+
+// Covers проверяет, что imprint выдан для digest алгоритма alg
+func (m MessageImprint) Covers(alg HashAlg, digest []byte) bool {
+	oid, ok := hashOIDs[alg]
+	return ok && m.HashAlgorithm.Algorithm.Equal(oid) && bytes.Equal(m.HashedMessage, digest)
+}
+
+// TSTInfo - содержимое метки времени, поля и порядок как в RFC 3161, 2.4.2
+// (без accuracy, ordering и extensions). GenTime кодируется с точностью до секунды.
+type TSTInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint MessageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Nonce          *big.Int  `asn1:"optional"`
+	TSA            string    `asn1:"optional,explicit,tag:0,utf8"` // имя authority
+}
+
+// TimestampToken - подписанный TSTInfo. Вместо CMS SignedData из RFC 3161 - DER
+// SEQUENCE { tstInfo, keyID, signature } с подписью Ed25519 над DER tstInfo.
+type TimestampToken struct {
+	Info      TSTInfo
+	KeyID     string // KeyID ключа authority
+	Signature []byte
+
+	raw []byte // DER tstInfo, под которым стоит подпись
+}
+
+type tokenASN1 struct {
+	TSTInfo   asn1.RawValue
+	KeyID     string `asn1:"utf8"`
+	Signature []byte
+}
+
+// SignTimestamp подписывает info ключом key и возвращает DER токена
+func SignTimestamp(key ed25519.PrivateKey, info TSTInfo) ([]byte, error) {
+	info.GenTime = info.GenTime.UTC().Truncate(time.Second)
+	raw, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("tstinfo: %w", err)
+	}
+	return asn1.Marshal(tokenASN1{
+		TSTInfo:   asn1.RawValue{FullBytes: raw},
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Signature: ed25519.Sign(key, raw),
+	})
+}
+
+// ParseTimestampToken разбирает DER токена без проверки подписи
+func ParseTimestampToken(der []byte) (*TimestampToken, error) {
+	var tok tokenASN1
+	if rest, err := asn1.Unmarshal(der, &tok); err != nil {
+		return nil, fmt.Errorf("timestamp token: %w", err)
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("timestamp token: %d trailing bytes", len(rest))
+	}
+	t := &TimestampToken{KeyID: tok.KeyID, Signature: tok.Signature, raw: tok.TSTInfo.FullBytes}
+	if rest, err := asn1.Unmarshal(t.raw, &t.Info); err != nil {
+		return nil, fmt.Errorf("tstinfo: %w", err)
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("tstinfo: %d trailing bytes", len(rest))
+	}
+	return t, nil
+}
+
+// Verify проверяет подпись токена ключом pub и то, что он выдан для digest
+func (t *TimestampToken) Verify(pub ed25519.PublicKey, alg HashAlg, digest []byte) error {
+	if len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: public key has %d bytes", ErrBadSignature, len(pub))
+	}
+	if id := KeyID(pub); id != t.KeyID {
+		return fmt.Errorf("%w: signed by key %s, got key %s", ErrBadSignature, t.KeyID, id)
+	}
+	if !ed25519.Verify(pub, t.raw, t.Signature) {
+		return ErrBadSignature
+	}
+	if !t.Info.MessageImprint.Covers(alg, digest) {
+		return ErrTimestampMismatch
+	}
+	return nil
+}
+
+// VerifyTimestamp разбирает токен, проверяет его по набору ключей (key_id -> ключ,
+// например, из GET /keys) и возвращает время, когда digest уже существовал
+func VerifyTimestamp(der []byte, alg HashAlg, digest []byte, keys map[string]ed25519.PublicKey) (time.Time, error) {
+	t, err := ParseTimestampToken(der)
+	if err != nil {
+		return time.Time{}, err
+	}
+	pub, ok := keys[t.KeyID]
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: unknown key %s", ErrBadSignature, t.KeyID)
+	}
+	if err := t.Verify(pub, alg, digest); err != nil {
+		return time.Time{}, err
+	}
+	return t.Info.GenTime, nil
+}
@@ -0,0 +1,161 @@
+package verify
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Tree - все уровни дерева батча: хеши по HashSize байт подряд, от листьев к корню.
+// Уровень k содержит ceil(N/2^k) узлов, поднятый без пары узел RFC 6962 тоже
+// хранится на своем уровне. Раскладка совпадает с merkle_tree_buf из clib/engine.h.
+type Tree struct {
+	Params Params
+	N      int    // листьев
+	Nodes  []byte // TreeNodes(N) хешей
+}
+
+// TreeNodes число узлов дерева из n листьев на всех уровнях
+func TreeNodes(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	total := n
+	for n > 1 {
+		n = (n + 1) / 2
+		total += n
+	}
+	return total
+}
+
+// Tree строит все уровни дерева. Root() совпадает с Params.Root.
+func (p Params) Tree(messages [][]byte) (*Tree, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrEmpty
+	}
+	t := &Tree{Params: p, N: len(messages), Nodes: make([]byte, 0, TreeNodes(len(messages))*HashSize)}
+	level := p.leafHashes(messages)
+	for {
+		for _, h := range level {
+			t.Nodes = append(t.Nodes, h...)
+		}
+		if len(level) == 1 {
+			return t, nil
+		}
+		level = p.nextLevel(level)
+	}
+}
+
+// Check проверяет, что размер Nodes соответствует N
+func (t *Tree) Check() error {
+	if err := t.Params.check(); err != nil {
+		return err
+	}
+	if t.N <= 0 {
+		return ErrEmpty
+	}
+	if want := TreeNodes(t.N) * HashSize; len(t.Nodes) != want {
+		return fmt.Errorf("tree: %d leaves need %d bytes, got %d", t.N, want, len(t.Nodes))
+	}
+	return nil
+}
+
+// Depth число уровней, включая листья и корень
+func (t *Tree) Depth() int {
+	depth := 1
+	for n := t.N; n > 1; n = (n + 1) / 2 {
+		depth++
+	}
+	return depth
+}
+
+// levelBounds номер первого узла уровня k и число узлов на нем
+func (t *Tree) levelBounds(k int) (start, count int) {
+	count = t.N
+	for i := 0; i < k; i++ {
+		start += count
+		count = (count + 1) / 2
+	}
+	return start, count
+}
+
+func (t *Tree) node(i int) []byte {
+	return t.Nodes[i*HashSize : (i+1)*HashSize : (i+1)*HashSize]
+}
+
+// Level хеши уровня k (0 - листья, Depth()-1 - корень). nil, если уровня нет.
+func (t *Tree) Level(k int) [][]byte {
+	if k < 0 || k >= t.Depth() {
+		return nil
+	}
+	start, count := t.levelBounds(k)
+	level := make([][]byte, count)
+	for i := range level {
+		level[i] = t.node(start + i)
+	}
+	return level
+}
+
+// Root корень дерева
+func (t *Tree) Root() []byte {
+	return t.node(TreeNodes(t.N) - 1)
+}
+
+// Proof путь включения листа index без пересчета хешей.
+// Совпадает с Params.BuildProof для тех же сообщений.
+func (t *Tree) Proof(index int) (*Proof, error) {
+	if index < 0 || index >= t.N {
+		return nil, ErrIndex
+	}
+	proof := &Proof{Index: index, Siblings: [][]byte{}, Left: []bool{}}
+	start, count := 0, t.N
+	pos := index
+	for count > 1 {
+		switch {
+		case pos%2 == 1:
+			proof.Siblings = append(proof.Siblings, t.node(start+pos-1))
+			proof.Left = append(proof.Left, true)
+		case pos+1 < count:
+			proof.Siblings = append(proof.Siblings, t.node(start+pos+1))
+			proof.Left = append(proof.Left, false)
+		case t.Params.version() == TreeLegacy:
+			// нечетный уровень: узел в паре сам с собой
+			proof.Siblings = append(proof.Siblings, t.node(start+pos))
+			proof.Left = append(proof.Left, false)
+		}
+		start += count
+		count = (count + 1) / 2
+		pos /= 2
+	}
+	return proof, nil
+}
+
+// treeHeaderSize версия, алгоритм и число листьев перед узлами в MarshalBinary
+const treeHeaderSize = 1 + 1 + 4
+
+// MarshalBinary дерево одним куском для кеша: версия, алгоритм, N (uint32) и узлы
+func (t *Tree) MarshalBinary() ([]byte, error) {
+	if err := t.Check(); err != nil {
+		return nil, err
+	}
+	data := make([]byte, treeHeaderSize, treeHeaderSize+len(t.Nodes))
+	data[0] = byte(t.Params.version())
+	data[1] = byte(t.Params.hash())
+	binary.BigEndian.PutUint32(data[2:], uint32(t.N))
+	return append(data, t.Nodes...), nil
+}
+
+// UnmarshalBinary восстанавливает дерево из MarshalBinary
+func (t *Tree) UnmarshalBinary(data []byte) error {
+	if len(data) < treeHeaderSize {
+		return fmt.Errorf("tree: %d bytes is too short", len(data))
+	}
+	*t = Tree{
+		Params: Params{Version: TreeVersion(data[0]), Hash: HashAlg(data[1])},
+		N:      int(binary.BigEndian.Uint32(data[2:])),
+		Nodes:  append([]byte(nil), data[treeHeaderSize:]...),
+	}
+	return t.Check()
+}
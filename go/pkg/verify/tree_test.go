@@ -0,0 +1,70 @@
+package verify_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeMatchesRootAndProofs(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	messages := randomMessages(rng, 70)
+	for _, p := range []verify.Params{verify.Legacy, {Version: verify.TreeRFC6962, Hash: verify.HashSHA3_256}} {
+		b, err := p.NewTreeBuilder()
+		require.NoError(t, err)
+
+		for n := 1; n <= len(messages); n++ {
+			tree, err := p.Tree(messages[:n])
+			require.NoError(t, err)
+			require.NoError(t, tree.Check())
+
+			root, err := p.Root(messages[:n])
+			require.NoError(t, err)
+			assert.Equal(t, root, tree.Root(), "version=%v n=%d", p.Version, n)
+			assert.Len(t, tree.Level(tree.Depth()-1), 1)
+			assert.Len(t, tree.Level(0), n)
+			assert.Nil(t, tree.Level(tree.Depth()))
+
+			for i := 0; i < n; i++ {
+				want, err := p.BuildProof(messages[:n], i)
+				require.NoError(t, err)
+				got, err := tree.Proof(i)
+				require.NoError(t, err)
+				assert.Equal(t, want, got, "version=%v n=%d index=%d", p.Version, n, i)
+			}
+
+			b.Append(messages[n-1])
+			streamed, err := b.Tree()
+			require.NoError(t, err)
+			assert.Equal(t, tree, streamed, "builder tree, version=%v n=%d", p.Version, n)
+		}
+	}
+}
+
+func TestTreeMarshalRoundTrip(t *testing.T) {
+	p := verify.Params{Version: verify.TreeRFC6962, Hash: verify.HashSHA512_256}
+	tree, err := p.Tree(randomMessages(rand.New(rand.NewSource(7)), 11))
+	require.NoError(t, err)
+
+	data, err := tree.MarshalBinary()
+	require.NoError(t, err)
+	var restored verify.Tree
+	require.NoError(t, restored.UnmarshalBinary(data))
+	assert.Equal(t, *tree, restored)
+
+	assert.Error(t, restored.UnmarshalBinary(data[:len(data)-1]))
+	assert.Error(t, restored.UnmarshalBinary(data[:3]))
+
+	_, err = tree.Proof(11)
+	assert.ErrorIs(t, err, verify.ErrIndex)
+
+	plain, err := p.NewBuilder()
+	require.NoError(t, err)
+	plain.Append([]byte("x"))
+	_, err = plain.Tree()
+	assert.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBadSignature - подпись не сходится с ключом или содержимым
+var ErrBadSignature = errors.New("bad signature")
+
+// treeHeadDomain отделяет подпись tree head от других подписей тем же ключом
+const treeHeadDomain = "veriChat/tree-head/v1"
+
+// TreeHead - состояние чата после батча: история из Size сообщений с корнем Root
+// (MMR, см. ChatRoot) и root самого батча
+type TreeHead struct {
+	ChatID    int64
+	BatchID   int64
+	Size      uint64
+	Root      []byte // root истории чата
+	BatchRoot []byte // root_hash батча
+	Timestamp time.Time
+}
+
+// SignedTreeHead - TreeHead, подписанный Ed25519 ключом сервиса
+type SignedTreeHead struct {
+	TreeHead
+	KeyID     string
+	Signature []byte
+}
+
+// Message байты, которые подписываются: домен, поля фиксированной длины big-endian
+// (Timestamp - миллисекунды Unix), затем корни с длиной
+func (h TreeHead) Message() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(treeHeadDomain)
+	for _, v := range []uint64{uint64(h.ChatID), uint64(h.BatchID), h.Size, uint64(h.Timestamp.UnixMilli())} {
+		buf.Write(binary.BigEndian.AppendUint64(nil, v))
+	}
+	for _, field := range [][]byte{h.Root, h.BatchRoot} {
+		buf.WriteByte(byte(len(field)))
+		buf.Write(field)
+	}
+	return buf.Bytes()
+}
+
+// KeyID идентификатор публичного ключа: первые 8 байт SHA-256 в hex
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// SignTreeHead подписывает head. Timestamp усекается до миллисекунд, как в Message.
+func SignTreeHead(key ed25519.PrivateKey, head TreeHead) *SignedTreeHead {
+	head.Timestamp = time.UnixMilli(head.Timestamp.UnixMilli()).UTC()
+	return &SignedTreeHead{
+		TreeHead:  head,
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Signature: ed25519.Sign(key, head.Message()),
+	}
+}
+
+// Verify проверяет подпись ключом pub. KeyID подписи должен совпадать с ключом.
+func (s *SignedTreeHead) Verify(pub ed25519.PublicKey) error {
+	if len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: public key has %d bytes", ErrBadSignature, len(pub))
+	}
+	if id := KeyID(pub); id != s.KeyID {
+		return fmt.Errorf("%w: signed by key %s, got key %s", ErrBadSignature, s.KeyID, id)
+	}
+	if !ed25519.Verify(pub, s.Message(), s.Signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// VerifyTreeHead проверяет подпись по набору ключей сервиса (key_id -> ключ),
+// например, по ответу GET /keys. Подходят и ключи, выведенные из ротации.
+func VerifyTreeHead(sth *SignedTreeHead, keys map[string]ed25519.PublicKey) error {
+	pub, ok := keys[sth.KeyID]
+	if !ok {
+		return fmt.Errorf("%w: unknown key %s", ErrBadSignature, sth.KeyID)
+	}
+	return sth.Verify(pub)
+}
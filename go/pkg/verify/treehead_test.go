@@ -0,0 +1,94 @@
+package verify_test
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTreeHead() verify.TreeHead {
+	return verify.TreeHead{
+		ChatID:    7,
+		BatchID:   42,
+		Size:      1000,
+		Root:      verify.HashSHA256.Sum([]byte("chat root")),
+		BatchRoot: verify.HashSHA256.Sum([]byte("batch root")),
+		Timestamp: time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC),
+	}
+}
+
+func TestSignedTreeHead(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	sth := verify.SignTreeHead(key, testTreeHead())
+	assert.Equal(t, verify.KeyID(pub), sth.KeyID)
+	assert.Len(t, sth.KeyID, 16)
+	assert.Equal(t, 123*time.Millisecond, time.Duration(sth.Timestamp.Nanosecond()))
+	require.NoError(t, sth.Verify(pub))
+	require.NoError(t, verify.VerifyTreeHead(sth, map[string]ed25519.PublicKey{sth.KeyID: pub}))
+
+	for name, mutate := range map[string]func(h *verify.SignedTreeHead){
+		"chat":       func(h *verify.SignedTreeHead) { h.ChatID++ },
+		"batch":      func(h *verify.SignedTreeHead) { h.BatchID++ },
+		"size":       func(h *verify.SignedTreeHead) { h.Size-- },
+		"root":       func(h *verify.SignedTreeHead) { h.Root = verify.HashSHA256.Sum([]byte("other")) },
+		"batch root": func(h *verify.SignedTreeHead) { h.BatchRoot = h.Root },
+		"timestamp":  func(h *verify.SignedTreeHead) { h.Timestamp = h.Timestamp.Add(time.Millisecond) },
+		"signature":  func(h *verify.SignedTreeHead) { h.Signature = append([]byte{h.Signature[0] ^ 1}, h.Signature[1:]...) },
+	} {
+		forged := *sth
+		mutate(&forged)
+		assert.ErrorIs(t, forged.Verify(pub), verify.ErrBadSignature, name)
+	}
+}
+
+func TestSignedTreeHeadRotatedKeys(t *testing.T) {
+	oldPub, oldKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	newPub, newKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	keys := map[string]ed25519.PublicKey{verify.KeyID(oldPub): oldPub, verify.KeyID(newPub): newPub}
+
+	// head, подписанный до ротации, проверяется старым ключом из истории
+	assert.NoError(t, verify.VerifyTreeHead(verify.SignTreeHead(oldKey, testTreeHead()), keys))
+	assert.NoError(t, verify.VerifyTreeHead(verify.SignTreeHead(newKey, testTreeHead()), keys))
+
+	// подпись старым ключом не выдается за подпись новым
+	sth := verify.SignTreeHead(oldKey, testTreeHead())
+	assert.ErrorIs(t, sth.Verify(newPub), verify.ErrBadSignature)
+	sth.KeyID = verify.KeyID(newPub)
+	assert.ErrorIs(t, verify.VerifyTreeHead(sth, keys), verify.ErrBadSignature)
+
+	delete(keys, verify.KeyID(oldPub))
+	assert.ErrorIs(t, verify.VerifyTreeHead(verify.SignTreeHead(oldKey, testTreeHead()), keys), verify.ErrBadSignature)
+}
+
+func TestCosignature(t *testing.T) {
+	_, serverKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	witnessPub, witnessKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	sth := verify.SignTreeHead(serverKey, testTreeHead())
+	at := time.Date(2026, 3, 1, 12, 0, 5, 987654321, time.UTC)
+	cos := verify.CosignTreeHead(witnessKey, sth.TreeHead, at)
+	assert.Equal(t, verify.KeyID(witnessPub), cos.KeyID)
+	assert.True(t, cos.Timestamp.Equal(at.Truncate(time.Millisecond)))
+	require.NoError(t, cos.Verify(witnessPub, sth.TreeHead))
+
+	// подпись свидетеля не подходит к другому head и не выдается за подпись сервиса
+	other := sth.TreeHead
+	other.Size++
+	assert.ErrorIs(t, cos.Verify(witnessPub, other), verify.ErrBadSignature)
+	moved := *cos
+	moved.Timestamp = moved.Timestamp.Add(time.Millisecond)
+	assert.ErrorIs(t, moved.Verify(witnessPub, sth.TreeHead), verify.ErrBadSignature)
+	asHead := &verify.SignedTreeHead{TreeHead: sth.TreeHead, KeyID: cos.KeyID, Signature: cos.Signature}
+	assert.ErrorIs(t, asHead.Verify(witnessPub), verify.ErrBadSignature)
+}
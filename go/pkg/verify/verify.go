@@ -0,0 +1,242 @@
+// Package verify - проверка Merkle root и inclusion proof без cgo.
+// Повторяет правила дерева из clib/engine.cpp байт в байт.
+//
+// TreeLegacy: лист = H(сообщение), узел = H(левый || правый),
+// последний узел нечетного уровня дублируется.
+//
+// TreeRFC6962: лист = H(0x00 || сообщение), узел = H(0x01 || левый || правый),
+// последний узел нечетного уровня поднимается без изменений (дерево RFC 6962).
+//
+// H - алгоритм батча (HashAlg), по умолчанию SHA-256.
+package verify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEmpty        = errors.New("empty messages")
+	ErrIndex        = errors.New("index out of range")
+	ErrRootMismatch = errors.New("root mismatch")
+	ErrBadProof     = errors.New("malformed proof")
+	ErrTreeVersion  = errors.New("unknown tree version")
+	ErrHashAlg      = errors.New("unknown hash algorithm")
+)
+
+// TreeVersion версия правил построения дерева (хранится в merkle_batches.tree_version)
+type TreeVersion int
+
+const (
+	TreeLegacy  TreeVersion = 1
+	TreeRFC6962 TreeVersion = 2
+)
+
+func (v TreeVersion) String() string {
+	switch v {
+	case TreeLegacy:
+		return "legacy"
+	case TreeRFC6962:
+		return "rfc6962"
+	}
+	return fmt.Sprintf("TreeVersion(%d)", int(v))
+}
+
+// Params правила дерева. Нулевое значение - TreeLegacy с SHA-256.
+type Params struct {
+	Version TreeVersion
+	Hash    HashAlg
+}
+
+// Legacy правила дерева, с которыми engine работал изначально
+var Legacy = Params{Version: TreeLegacy}
+
+func (p Params) version() TreeVersion {
+	if p.Version == 0 {
+		return TreeLegacy
+	}
+	return p.Version
+}
+
+func (p Params) hash() HashAlg {
+	if p.Hash == 0 {
+		return HashSHA256
+	}
+	return p.Hash
+}
+
+func (p Params) check() error {
+	switch p.version() {
+	case TreeLegacy, TreeRFC6962:
+	default:
+		return fmt.Errorf("%w %d", ErrTreeVersion, int(p.Version))
+	}
+	return p.hash().check()
+}
+
+// Proof - путь от листа до корня. Совпадает по полям с cgobridge.Proof.
+type Proof struct {
+	Index    int      // позиция сообщения в батче
+	Siblings [][]byte // хеши соседей снизу вверх
+	Left     []bool   // true если сосед стоит слева от узла на пути
+}
+
+// LeafHash хеш листа
+func (p Params) LeafHash(message []byte) []byte {
+	h := p.hash().New()
+	if p.version() == TreeRFC6962 {
+		h.Write([]byte{0x00})
+	}
+	h.Write(message)
+	return h.Sum(nil)
+}
+
+// NodeHash хеш внутреннего узла
+func (p Params) NodeHash(left, right []byte) []byte {
+	h := p.hash().New()
+	if p.version() == TreeRFC6962 {
+		h.Write([]byte{0x01})
+	}
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+func (p Params) leafHashes(messages [][]byte) [][]byte {
+	hashes := make([][]byte, len(messages))
+	for i, m := range messages {
+		hashes[i] = p.LeafHash(m)
+	}
+	return hashes
+}
+
+// nextLevel следующий уровень дерева. Последний узел нечетного уровня
+// дублируется (TreeLegacy) или поднимается как есть (TreeRFC6962).
+func (p Params) nextLevel(hashes [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(hashes)+1)/2)
+	for i := 0; i < len(hashes); i += 2 {
+		switch {
+		case i+1 < len(hashes):
+			next = append(next, p.NodeHash(hashes[i], hashes[i+1]))
+		case p.version() == TreeRFC6962:
+			next = append(next, hashes[i])
+		default:
+			next = append(next, p.NodeHash(hashes[i], hashes[i]))
+		}
+	}
+	return next
+}
+
+// Root считает Merkle root так же, как cgobridge.MerkleRootWithOptions
+func (p Params) Root(messages [][]byte) ([]byte, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrEmpty
+	}
+	hashes := p.leafHashes(messages)
+	for len(hashes) > 1 {
+		hashes = p.nextLevel(hashes)
+	}
+	return hashes[0], nil
+}
+
+// BuildProof строит inclusion proof так же, как cgobridge.MerkleProofWithOptions
+func (p Params) BuildProof(messages [][]byte, index int) (*Proof, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrEmpty
+	}
+	if index < 0 || index >= len(messages) {
+		return nil, ErrIndex
+	}
+
+	proof := &Proof{Index: index, Siblings: [][]byte{}, Left: []bool{}}
+	hashes := p.leafHashes(messages)
+	pos := index
+	for len(hashes) > 1 {
+		switch {
+		case pos%2 == 1:
+			proof.Siblings = append(proof.Siblings, hashes[pos-1])
+			proof.Left = append(proof.Left, true)
+		case pos+1 < len(hashes):
+			proof.Siblings = append(proof.Siblings, hashes[pos+1])
+			proof.Left = append(proof.Left, false)
+		case p.version() == TreeLegacy:
+			// нечетный уровень: узел в паре сам с собой
+			proof.Siblings = append(proof.Siblings, hashes[pos])
+			proof.Left = append(proof.Left, false)
+		}
+		hashes = p.nextLevel(hashes)
+		pos /= 2
+	}
+	return proof, nil
+}
+
+// RootFromProof поднимается от сообщения к корню по пути proof
+func (p Params) RootFromProof(message []byte, proof *Proof) ([]byte, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	if proof == nil || len(proof.Siblings) != len(proof.Left) {
+		return nil, ErrBadProof
+	}
+	cur := p.LeafHash(message)
+	for i, sib := range proof.Siblings {
+		if len(sib) != HashSize {
+			return nil, fmt.Errorf("%w: sibling %d has %d bytes", ErrBadProof, i, len(sib))
+		}
+		if proof.Left[i] {
+			cur = p.NodeHash(sib, cur)
+		} else {
+			cur = p.NodeHash(cur, sib)
+		}
+	}
+	return cur, nil
+}
+
+// VerifyProof проверяет, что message входит в дерево с корнем root
+func (p Params) VerifyProof(message []byte, proof *Proof, root []byte) error {
+	got, err := p.RootFromProof(message, proof)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, root) {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
+// Root считает root по правилам TreeLegacy
+func Root(messages [][]byte) ([]byte, error) {
+	return Legacy.Root(messages)
+}
+
+// BuildProof строит proof по правилам TreeLegacy
+func BuildProof(messages [][]byte, index int) (*Proof, error) {
+	return Legacy.BuildProof(messages, index)
+}
+
+// RootFromProof считает root из proof по правилам TreeLegacy
+func RootFromProof(message []byte, proof *Proof) ([]byte, error) {
+	return Legacy.RootFromProof(message, proof)
+}
+
+// VerifyProof проверяет proof по правилам TreeLegacy
+func VerifyProof(message []byte, proof *Proof, root []byte) error {
+	return Legacy.VerifyProof(message, proof, root)
+}
+
+// LeafHash хеш листа по правилам TreeLegacy
+func LeafHash(message []byte) []byte {
+	return Legacy.LeafHash(message)
+}
+
+// NodeHash хеш узла по правилам TreeLegacy
+func NodeHash(left, right []byte) []byte {
+	return Legacy.NodeHash(left, right)
+}
@@ -0,0 +1,157 @@
+package verify_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"veriChat/go/internal/cgobridge"
+	"veriChat/go/pkg/verify"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomMessages(rng *rand.Rand, n int) [][]byte {
+	messages := make([][]byte, n)
+	for i := range messages {
+		msg := make([]byte, rng.Intn(200))
+		rng.Read(msg)
+		messages[i] = msg
+	}
+	return messages
+}
+
+func TestRootMatchesEngine(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 1; n <= 130; n++ {
+		messages := randomMessages(rng, n)
+
+		want, err := cgobridge.MerkleRoot(messages)
+		require.NoError(t, err)
+
+		got, err := verify.Root(messages)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "n=%d", n)
+	}
+}
+
+func TestRFC6962MatchesEngine(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	rfc := verify.Params{Version: verify.TreeRFC6962}
+	opts := cgobridge.Options{TreeVersion: cgobridge.TreeRFC6962}
+	for n := 1; n <= 70; n++ {
+		messages := randomMessages(rng, n)
+
+		want, err := cgobridge.MerkleRootWithOptions(messages, opts)
+		require.NoError(t, err)
+		got, err := rfc.Root(messages)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "n=%d", n)
+
+		for i := 0; i < n; i += 3 {
+			engineProof, err := cgobridge.MerkleProofWithOptions(messages, i, opts)
+			require.NoError(t, err)
+			proof := verify.Proof(*engineProof)
+			assert.NoError(t, rfc.VerifyProof(messages[i], &proof, got), "n=%d index=%d", n, i)
+			assert.ErrorIs(t, verify.VerifyProof(messages[i], &proof, got), verify.ErrRootMismatch,
+				"rfc6962 proof must not verify under legacy rules")
+		}
+	}
+}
+
+func TestRootMatchesEngineEmptyMessages(t *testing.T) {
+	messages := [][]byte{{}, []byte("x"), {}}
+
+	want, err := cgobridge.MerkleRoot(messages)
+	require.NoError(t, err)
+
+	got, err := verify.Root(messages)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestProofMatchesEngine(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for n := 1; n <= 33; n++ {
+		messages := randomMessages(rng, n)
+		root, err := cgobridge.MerkleRoot(messages)
+		require.NoError(t, err)
+
+		for i := 0; i < n; i++ {
+			engineProof, err := cgobridge.MerkleProof(messages, i)
+			require.NoError(t, err)
+
+			proof, err := verify.BuildProof(messages, i)
+			require.NoError(t, err)
+			assert.Equal(t, verify.Proof(*engineProof), *proof, "n=%d index=%d", n, i)
+
+			assert.NoError(t, verify.VerifyProof(messages[i], proof, root), "n=%d index=%d", n, i)
+		}
+	}
+}
+
+func TestVerifyProofRejects(t *testing.T) {
+	messages := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+	root, err := verify.Root(messages)
+	require.NoError(t, err)
+
+	proof, err := verify.BuildProof(messages, 1)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, verify.VerifyProof([]byte("x"), proof, root), verify.ErrRootMismatch)
+	assert.ErrorIs(t, verify.VerifyProof(messages[0], proof, root), verify.ErrRootMismatch)
+
+	flipped := *proof
+	flipped.Left = append([]bool(nil), proof.Left...)
+	flipped.Left[0] = !flipped.Left[0]
+	assert.ErrorIs(t, verify.VerifyProof(messages[1], &flipped, root), verify.ErrRootMismatch)
+
+	truncated := *proof
+	truncated.Siblings = proof.Siblings[:1]
+	assert.ErrorIs(t, verify.VerifyProof(messages[1], &truncated, root), verify.ErrBadProof)
+
+	short := *proof
+	short.Siblings = append([][]byte{[]byte("short")}, proof.Siblings[1:]...)
+	assert.ErrorIs(t, verify.VerifyProof(messages[1], &short, root), verify.ErrBadProof)
+}
+
+func TestVerifyBatch(t *testing.T) {
+	messages := make([]verify.Message, 5)
+	payloads := make([][]byte, 5)
+	for i := range messages {
+		payloads[i] = []byte(fmt.Sprintf("payload %d", i))
+		messages[i] = verify.Message{MessageID: int64(100 + i), Payload: payloads[i]}
+	}
+	root, err := cgobridge.MerkleRoot(payloads)
+	require.NoError(t, err)
+
+	batch := verify.Batch{
+		BatchID:       7,
+		ChatID:        1,
+		RootHash:      root,
+		FromMessageID: 100,
+		ToMessageID:   104,
+	}
+	assert.NoError(t, verify.VerifyBatch(batch, messages))
+
+	tampered := append([]verify.Message(nil), messages...)
+	tampered[2].Payload = []byte("tampered")
+	assert.ErrorIs(t, verify.VerifyBatch(batch, tampered), verify.ErrRootMismatch)
+
+	assert.Error(t, verify.VerifyBatch(batch, messages[1:]))
+	assert.Error(t, verify.VerifyBatch(batch, messages[:4]))
+	assert.ErrorIs(t, verify.VerifyBatch(batch, nil), verify.ErrEmpty)
+
+	rfcBatch := batch
+	rfcBatch.TreeVersion = verify.TreeRFC6962
+	assert.ErrorIs(t, verify.VerifyBatch(rfcBatch, messages), verify.ErrRootMismatch)
+
+	rfcBatch.RootHash, err = cgobridge.MerkleRootWithOptions(payloads, cgobridge.Options{TreeVersion: cgobridge.TreeRFC6962})
+	require.NoError(t, err)
+	assert.NoError(t, verify.VerifyBatch(rfcBatch, messages))
+
+	legacyBatch := batch
+	legacyBatch.TreeVersion = verify.TreeLegacy
+	assert.NoError(t, verify.VerifyBatch(legacyBatch, messages))
+}
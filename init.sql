@@ -7,7 +7,8 @@ CREATE TABLE messages (
     payload_hash BINARY(32) NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     batch_id BIGINT NULL,
-    INDEX idx_chat_time(chat_id, created_at)
+    INDEX idx_chat_time(chat_id, created_at),
+    INDEX idx_batch(batch_id, message_id)
 );
 
 CREATE TABLE merkle_batches (
@@ -16,7 +17,120 @@ CREATE TABLE merkle_batches (
     root_hash BINARY(32) NOT NULL,
     from_message_id BIGINT NOT NULL,
     to_message_id BIGINT NOT NULL,
+    -- 1: legacy (дублирование последнего узла), 2: RFC 6962 (префиксы 0x00/0x01)
+    tree_version TINYINT NOT NULL DEFAULT 1,
+    -- алгоритм хеширования: 1 sha256, 2 sha512_256, 3 sha3_256
+    hash_alg TINYINT NOT NULL DEFAULT 1,
+    -- размер и root истории чата (MMR) после этого батча
+    chat_size BIGINT NULL,
+    chat_root BINARY(32) NULL,
+    -- хеш предыдущего батча чата (verify.BatchHash), у первого - 32 нулевых байта
+    prev_batch_hash BINARY(32) NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     UNIQUE KEY uk_chat_range(chat_id, from_message_id, to_message_id),
-    INDEX idx_chat_created(chat_id, created_at)
-);
\ No newline at end of file
+    INDEX idx_chat_created(chat_id, created_at),
+    INDEX idx_chat_batch(chat_id, batch_id),
+    INDEX idx_chat_root(chat_id, root_hash)
+);
+
+CREATE TABLE chats (
+    chat_id BIGINT PRIMARY KEY,
+    -- алгоритм хеширования чата, фиксируется при первом сообщении
+    hash_alg TINYINT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Append-only аккумулятор истории чата (Merkle Mountain Range)
+CREATE TABLE chat_accumulators (
+    chat_id BIGINT PRIMARY KEY,
+    size BIGINT NOT NULL,
+    peaks BLOB NOT NULL, -- пики по 32 байта, от высокого к низкому
+    root BINARY(32) NOT NULL,
+    hash_alg TINYINT NOT NULL,
+    last_batch_id BIGINT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+-- Ed25519 ключи подписи tree head. Активный - с retired_at IS NULL,
+-- выведенные из ротации хранятся для проверки старых подписей.
+CREATE TABLE signing_keys (
+    key_id VARCHAR(16) PRIMARY KEY, -- первые 8 байт SHA-256 публичного ключа в hex
+    public_key VARBINARY(32) NOT NULL,
+    activated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP NULL
+);
+
+-- Подписанное состояние чата после каждого батча (verify.SignedTreeHead)
+CREATE TABLE signed_tree_heads (
+    batch_id BIGINT PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    tree_size BIGINT NOT NULL,
+    root BINARY(32) NOT NULL,
+    batch_root BINARY(32) NOT NULL,
+    timestamp_ms BIGINT NOT NULL,
+    key_id VARCHAR(16) NOT NULL,
+    signature BINARY(64) NOT NULL,
+    INDEX idx_chat_batch(chat_id, batch_id)
+);
+
+-- Метки времени root батчей (по образцу RFC 3161, см. go/internal/tsa)
+CREATE TABLE batch_timestamps (
+    batch_id BIGINT PRIMARY KEY,
+    authority VARCHAR(64) NOT NULL,
+    token BLOB NOT NULL, -- DER токена
+    gen_time TIMESTAMP NOT NULL, -- время из токена
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Ожидающие метки времени батчей: строка заводится в транзакции коммита батча
+-- и удаляется вместе с сохранением токена в batch_timestamps
+CREATE TABLE batch_timestamp_jobs (
+    batch_id BIGINT PRIMARY KEY,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_due(next_attempt_at)
+);
+
+-- Ed25519 ключи локальной TSA (tsa.Local), отдельно от ключей подписи tree head:
+-- токены меток времени проверяются только ими. Активный - с retired_at IS NULL.
+CREATE TABLE tsa_keys (
+    key_id VARCHAR(16) PRIMARY KEY, -- первые 8 байт SHA-256 публичного ключа в hex
+    public_key VARBINARY(32) NOT NULL,
+    activated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP NULL
+);
+
+-- Публикации батчей вне MySQL/Redis (go/internal/anchor): квитанция или состояние повторов
+CREATE TABLE batch_anchors (
+    batch_id BIGINT NOT NULL,
+    anchor VARCHAR(255) NOT NULL, -- anchor.Anchor.Name()
+    status ENUM('pending', 'done') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    reference VARCHAR(255) NOT NULL DEFAULT '',
+    receipt BLOB NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    anchored_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (batch_id, anchor),
+    INDEX idx_due(anchor, status, next_attempt_at)
+);
+
+-- Подписи свидетелей под tree head батчей (verify.Cosignature)
+CREATE TABLE batch_cosignatures (
+    batch_id BIGINT NOT NULL,
+    witness_key_id VARCHAR(16) NOT NULL,
+    timestamp_ms BIGINT NOT NULL,
+    signature BINARY(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (batch_id, witness_key_id)
+);
+
+-- Деревья батчей (verify.Tree.MarshalBinary): proof без пересчета хешей после истечения кеша Redis
+CREATE TABLE batch_trees (
+    batch_id BIGINT PRIMARY KEY,
+    tree LONGBLOB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,4 @@
+-- Версия правил дерева для каждого батча.
+-- Старые записи получают 1 (legacy) и продолжают проверяться по старым правилам.
+ALTER TABLE merkle_batches
+    ADD COLUMN tree_version TINYINT NOT NULL DEFAULT 1 AFTER to_message_id;
@@ -0,0 +1,13 @@
+-- Алгоритм хеширования для каждого батча и каждого чата.
+-- Существующие батчи и чаты остаются на SHA-256 (1).
+ALTER TABLE merkle_batches
+    ADD COLUMN hash_alg TINYINT NOT NULL DEFAULT 1 AFTER tree_version;
+
+CREATE TABLE chats (
+    chat_id BIGINT PRIMARY KEY,
+    hash_alg TINYINT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO chats (chat_id, hash_alg)
+SELECT DISTINCT chat_id, 1 FROM messages;
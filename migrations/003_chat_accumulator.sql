@@ -0,0 +1,15 @@
+-- Аккумулятор истории чата. Для чатов без записи он собирается из уже
+-- сбатченных сообщений при следующем flush.
+ALTER TABLE merkle_batches
+    ADD COLUMN chat_size BIGINT NULL AFTER hash_alg,
+    ADD COLUMN chat_root BINARY(32) NULL AFTER chat_size;
+
+CREATE TABLE chat_accumulators (
+    chat_id BIGINT PRIMARY KEY,
+    size BIGINT NOT NULL,
+    peaks BLOB NOT NULL,
+    root BINARY(32) NOT NULL,
+    hash_alg TINYINT NOT NULL,
+    last_batch_id BIGINT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+-- Сообщения батча по порядку листьев: proof по сообщению и пересборка дерева батча.
+ALTER TABLE messages ADD INDEX idx_batch(batch_id, message_id);
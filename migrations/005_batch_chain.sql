@@ -0,0 +1,5 @@
+-- Цепочка батчей чата. Старые записи остаются с NULL, первый новый батч
+-- ссылается на последний старый.
+ALTER TABLE merkle_batches
+    ADD COLUMN prev_batch_hash BINARY(32) NULL AFTER chat_root,
+    ADD INDEX idx_chat_batch(chat_id, batch_id);
@@ -0,0 +1,21 @@
+-- Ed25519 ключи подписи tree head. Активный - с retired_at IS NULL,
+-- выведенные из ротации хранятся для проверки старых подписей.
+CREATE TABLE signing_keys (
+    key_id VARCHAR(16) PRIMARY KEY, -- первые 8 байт SHA-256 публичного ключа в hex
+    public_key VARBINARY(32) NOT NULL,
+    activated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP NULL
+);
+
+-- Подписанное состояние чата после каждого батча (verify.SignedTreeHead)
+CREATE TABLE signed_tree_heads (
+    batch_id BIGINT PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    tree_size BIGINT NOT NULL,
+    root BINARY(32) NOT NULL,
+    batch_root BINARY(32) NOT NULL,
+    timestamp_ms BIGINT NOT NULL,
+    key_id VARCHAR(16) NOT NULL,
+    signature BINARY(64) NOT NULL,
+    INDEX idx_chat_batch(chat_id, batch_id)
+);
@@ -0,0 +1,8 @@
+-- Метки времени root батчей (по образцу RFC 3161, см. go/internal/tsa)
+CREATE TABLE batch_timestamps (
+    batch_id BIGINT PRIMARY KEY,
+    authority VARCHAR(64) NOT NULL,
+    token BLOB NOT NULL, -- DER токена
+    gen_time TIMESTAMP NOT NULL, -- время из токена
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
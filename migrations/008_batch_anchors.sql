@@ -0,0 +1,15 @@
+-- Публикации батчей вне MySQL/Redis (go/internal/anchor): квитанция или состояние повторов
+CREATE TABLE batch_anchors (
+    batch_id BIGINT NOT NULL,
+    anchor VARCHAR(255) NOT NULL, -- anchor.Anchor.Name()
+    status ENUM('pending', 'done') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    reference VARCHAR(255) NOT NULL DEFAULT '',
+    receipt BLOB NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    anchored_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (batch_id, anchor),
+    INDEX idx_due(anchor, status, next_attempt_at)
+);
@@ -0,0 +1,9 @@
+-- Подписи свидетелей под tree head батчей (verify.Cosignature)
+CREATE TABLE batch_cosignatures (
+    batch_id BIGINT NOT NULL,
+    witness_key_id VARCHAR(16) NOT NULL,
+    timestamp_ms BIGINT NOT NULL,
+    signature BINARY(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (batch_id, witness_key_id)
+);
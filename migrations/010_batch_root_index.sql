@@ -0,0 +1,3 @@
+-- Поиск батча по root для POST /verify
+ALTER TABLE merkle_batches
+    ADD INDEX idx_root(root_hash);
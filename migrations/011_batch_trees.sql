@@ -0,0 +1,6 @@
+-- Деревья батчей (verify.Tree.MarshalBinary): proof без пересчета хешей после истечения кеша Redis
+CREATE TABLE batch_trees (
+    batch_id BIGINT PRIMARY KEY,
+    tree LONGBLOB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,4 @@
+-- Поиск батча по root в пределах чата для POST /verify
+ALTER TABLE merkle_batches
+    DROP INDEX idx_root,
+    ADD INDEX idx_chat_root(chat_id, root_hash);
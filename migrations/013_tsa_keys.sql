@@ -0,0 +1,8 @@
+-- Ed25519 ключи локальной TSA (tsa.Local), отдельно от ключей подписи tree head:
+-- токены меток времени проверяются только ими. Активный - с retired_at IS NULL.
+CREATE TABLE tsa_keys (
+    key_id VARCHAR(16) PRIMARY KEY, -- первые 8 байт SHA-256 публичного ключа в hex
+    public_key VARBINARY(32) NOT NULL,
+    activated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP NULL
+);
@@ -0,0 +1,10 @@
+-- Ожидающие метки времени батчей: строка заводится в транзакции коммита батча
+-- и удаляется вместе с сохранением токена в batch_timestamps
+CREATE TABLE batch_timestamp_jobs (
+    batch_id BIGINT PRIMARY KEY,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_due(next_attempt_at)
+);
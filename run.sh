@@ -2,6 +2,12 @@
 
 cd "$(dirname "$0")"
 
+if [ "$CGO_ENABLED" = "0" ]; then
+    echo "=== Сборка без cgo (Go backend) ==="
+    go run ./go/cmd/api/main.go
+    exit $?
+fi
+
 echo "=== Проверка библиотеки ==="
 if [ ! -f "clib/build/libengine.so" ]; then
     echo "Библиотека не найдена! Собираем..."
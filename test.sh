@@ -21,4 +21,7 @@ export CGO_CFLAGS="-I$(pwd)/clib"
 echo "LD_LIBRARY_PATH: $LD_LIBRARY_PATH"
 echo "CGO_LDFLAGS: $CGO_LDFLAGS"
 
-go test ./go/internal/cgobridge 
\ No newline at end of file
+go test ./go/...
+
+echo "=== Тесты без cgo (Go backend) ==="
+CGO_ENABLED=0 go test ./go/...